@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/heyyakash/tickdb/internal/backup"
+	"github.com/heyyakash/tickdb/internal/config"
+	"github.com/heyyakash/tickdb/internal/database"
+)
+
+// runBackup implements `tickdb backup`, which asks a running server for a
+// consistent backup and saves it as a tarball or a directory, or has the
+// server write it to a directory of its own.
+func runBackup(args []string) int {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: tickdb backup [flags] output")
+		fmt.Fprintln(fs.Output(), "Saves a backup as a gzipped tarball when output ends in .tar.gz or .tgz, as a directory otherwise.")
+		fmt.Fprintln(fs.Output(), "With -server-dir the server writes the backup itself under its -backup-dir, hard linking SSTables,")
+		fmt.Fprintln(fs.Output(), "and output is left out.")
+		fs.PrintDefaults()
+	}
+	addr := fs.String("url", "http://localhost:8021", "url of the query rest server")
+	dbs := fs.String("db", "", "comma separated databases to back up, all of them when empty")
+	base := fs.String("base", "", "earlier backup (directory or tarball) to make an incremental backup to")
+	serverDir := fs.String("server-dir", "", "directory to write the backup to on the server, relative to its -backup-dir")
+	token := fs.String("token", os.Getenv("TICKDB_TOKEN"), "API token with the admin scope (env TICKDB_TOKEN)")
+	caFile := fs.String("ca-file", "", "CA certificate to verify the server with")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if (fs.NArg() == 1) == (*serverDir != "") {
+		fs.Usage()
+		return 2
+	}
+
+	if err := backupCommand(fs.Arg(0), *addr, *dbs, *base, *serverDir, *token, *caFile); err != nil {
+		fmt.Fprintln(os.Stderr, "backup failed:", err)
+		return 1
+	}
+	return 0
+}
+
+func backupCommand(output, addr, dbs, base, serverDir, token, caFile string) error {
+	client, err := newClient(caFile)
+	if err != nil {
+		return err
+	}
+	body := struct {
+		Databases []string         `json:"databases,omitempty"`
+		Base      *backup.Manifest `json:"base,omitempty"`
+		Dir       string           `json:"dir,omitempty"`
+	}{Dir: serverDir}
+	if dbs != "" {
+		body.Databases = strings.Split(dbs, ",")
+	}
+	if base != "" {
+		if body.Base, err = backup.ReadManifest(base); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(addr, "/")+"/backup", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("server answered %s: %s", resp.Status, e.Error)
+	}
+
+	var m *backup.Manifest
+	switch {
+	case serverDir != "":
+		m = &backup.Manifest{}
+		if err := json.NewDecoder(resp.Body).Decode(m); err != nil {
+			return fmt.Errorf("couldn't read the manifest : %w", err)
+		}
+		output = serverDir + " on the server"
+	case strings.HasSuffix(output, ".tar.gz") || strings.HasSuffix(output, ".tgz"):
+		if m, err = saveTarball(resp.Body, output); err != nil {
+			return err
+		}
+	default:
+		if m, err = backup.Extract(resp.Body, output); err != nil {
+			os.RemoveAll(output)
+			return err
+		}
+	}
+
+	files, inBase, size := 0, 0, int64(0)
+	for _, db := range m.Databases {
+		for _, f := range db.Files {
+			if f.InBase {
+				inBase++
+				continue
+			}
+			files++
+			size += f.Bytes
+		}
+	}
+	fmt.Fprintf(os.Stderr, "backup %s of %d databases written to %s: %d files, %d bytes", m.ID, len(m.Databases), output, files, size)
+	if m.Base != "" {
+		fmt.Fprintf(os.Stderr, ", %d files in base backup %s", inBase, m.Base)
+	}
+	fmt.Fprintln(os.Stderr)
+	return nil
+}
+
+// saveTarball writes the backup tarball to path, only keeping it when it is
+// complete, and returns its manifest
+func saveTarball(r io.Reader, path string) (*backup.Manifest, error) {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	var m *backup.Manifest
+	if err == nil {
+		m, err = backup.ReadManifest(tmp)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("couldn't save the backup : %w", err)
+	}
+	return m, nil
+}
+
+// runRestore implements `tickdb restore`, which verifies a backup and puts
+// its files where a server with the same configuration reads them. The
+// server must be stopped.
+func runRestore(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: tickdb restore [flags] backup [base...]")
+		fmt.Fprintln(fs.Output(), "Restores a backup directory or tarball into the data directories of a stopped server.")
+		fmt.Fprintln(fs.Output(), "An incremental backup is followed by its base, the base of that and so on.")
+		fs.PrintDefaults()
+	}
+	// the directories are resolved like the server does, so the files end up
+	// where it reads them
+	var serverArgs []string
+	for _, name := range []string{"config", "data-dir", "wal-dir", "sstable-dir"} {
+		fs.Func(name, "same as the server flag", func(v string) error {
+			serverArgs = append(serverArgs, "-"+name, v)
+			return nil
+		})
+	}
+	dbs := fs.String("db", "", "comma separated databases to restore, all of them when empty")
+	check := fs.Bool("check", false, "only verify the checksums of the backup")
+	force := fs.Bool("force", false, "replace the data of databases that already have some")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	cfg, err := config.Load(serverArgs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		return 1
+	}
+	opts := backup.RestoreOptions{
+		Sources:   fs.Args(),
+		CheckOnly: *check,
+		Force:     *force,
+		Dest: func(name string) (string, string, string) {
+			return database.Dirs(database.Options{DataDir: cfg.DataDir, WALDir: cfg.WALDir, SSTableDir: cfg.SSTableDir}, name)
+		},
+	}
+	if *dbs != "" {
+		opts.Databases = strings.Split(*dbs, ",")
+	}
+	for _, name := range opts.Databases {
+		if err := database.ValidName(name); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			return 2
+		}
+	}
+
+	report, err := backup.Restore(opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "restore failed:", err)
+		return 1
+	}
+	verb := "restored to " + filepath.Clean(cfg.DataDir)
+	if *check {
+		verb = "verified"
+	}
+	fmt.Fprintf(os.Stderr, "backup %s %s: databases %s, %d files, %d bytes, %d from base backups\n", report.ID, verb, strings.Join(report.Databases, ", "), report.Files, report.Bytes, report.FromBase)
+	return 0
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/heyyakash/tickdb/internal/importer"
+)
+
+// runImport implements `tickdb import`, which streams line protocol or CSV
+// files to the /ingest/import endpoint of a running server and prints its
+// progress. It returns the exit code.
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: tickdb import [flags] file...")
+		fmt.Fprintln(fs.Output(), "Imports line protocol or CSV files, - reads standard input. Files ending in .gz are sent compressed.")
+		fs.PrintDefaults()
+	}
+	addr := fs.String("url", "http://localhost:8020", "url of the ingest rest server")
+	db := fs.String("db", "", "database to import into, the default database when empty")
+	format := fs.String("format", "", "lp or csv, taken from the file extension when empty")
+	measurement := fs.String("measurement", "", "measurement of every CSV row")
+	columns := fs.String("columns", "", "CSV column mapping, e.g. ts:time,host:tag,usage:field")
+	precision := fs.String("precision", "", "unit of integer timestamps: s, ms, us or ns (default ns)")
+	maxErrors := fs.Int("max-errors", 0, "stop a file after this many rejected lines, 0 never stops")
+	token := fs.String("token", os.Getenv("TICKDB_TOKEN"), "API token with the write scope (env TICKDB_TOKEN)")
+	caFile := fs.String("ca-file", "", "CA certificate to verify the server with")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	client, err := newClient(*caFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	failed := false
+	for _, file := range fs.Args() {
+		params := url.Values{}
+		for name, value := range map[string]string{"measurement": *measurement, "columns": *columns, "precision": *precision} {
+			if value != "" {
+				params.Set(name, value)
+			}
+		}
+		params.Set("format", *format)
+		if *format == "" {
+			params.Set("format", importFormat(file))
+		}
+		if *maxErrors > 0 {
+			params.Set("max_errors", fmt.Sprint(*maxErrors))
+		}
+
+		endpoint := strings.TrimSuffix(*addr, "/")
+		if *db != "" {
+			endpoint += "/db/" + url.PathEscape(*db)
+		}
+		endpoint += "/ingest/import?" + params.Encode()
+
+		report, err := importFile(client, endpoint, file, *token)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+			failed = true
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s: imported %d points from %d lines into %d sstables in %s, %d lines rejected\n", file, report.Points, report.Lines, report.SSTables, report.Elapsed, report.Rejected)
+		for _, e := range report.Errors {
+			fmt.Fprintf(os.Stderr, "%s:%d: %s\n", file, e.Line, e.Error)
+		}
+		if report.Rejected > len(report.Errors) {
+			fmt.Fprintf(os.Stderr, "%s: %d more rejected lines not shown\n", file, report.Rejected-len(report.Errors))
+		}
+		if report.Error != "" {
+			fmt.Fprintf(os.Stderr, "%s: import stopped: %s\n", file, report.Error)
+		}
+		failed = failed || report.Error != "" || report.Rejected > 0
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// importFormat guesses the format of a file from its extension
+func importFormat(file string) string {
+	if strings.HasSuffix(strings.TrimSuffix(file, ".gz"), ".csv") {
+		return importer.CSV
+	}
+	return importer.LineProtocol
+}
+
+// importFile posts file to endpoint, printing the progress reports as they
+// arrive, and returns the final report
+func importFile(client *http.Client, endpoint, file, token string) (*importer.Report, error) {
+	var body io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		body = f
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(file) == ".gz" {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var report importer.Report
+		if err := dec.Decode(&report); err != nil {
+			if resp.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("server answered %s", resp.Status)
+			}
+			return nil, fmt.Errorf("couldn't read the import report : %w", err)
+		}
+		if resp.StatusCode != http.StatusOK && report.Error != "" {
+			return nil, fmt.Errorf("server answered %s: %s", resp.Status, report.Error)
+		}
+		if report.Done {
+			return &report, nil
+		}
+		fmt.Fprintf(os.Stderr, "%s: %d lines, %d points, %d rejected, %d bytes, %s\n", file, report.Lines, report.Points, report.Rejected, report.Bytes, report.Elapsed)
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/heyyakash/tickdb/internal/inspect"
+	"github.com/heyyakash/tickdb/internal/sstable"
+	"github.com/heyyakash/tickdb/internal/wal"
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// inspectCommands are the subcommands of `tickdb inspect`
+var inspectCommands = map[string]struct {
+	usage string
+	run   func(fs *flag.FlagSet, args []string) error
+}{
+	"footer":  {"footer sstable", inspectFooter},
+	"index":   {"index sstable", inspectIndex},
+	"entries": {"entries [-key key] [-points] sstable", inspectEntries},
+	"wal":     {"wal [-limit n] segment", inspectWAL},
+	"verify":  {"verify path...", inspectVerify},
+	"summary": {"summary [-json] path...", inspectSummary},
+}
+
+var (
+	// errUsage makes inspect exit with 2, the usage has been printed
+	errUsage = errors.New("usage")
+	// errFailed makes inspect exit with 1 after printing its output
+	errFailed = errors.New("failed")
+)
+
+// runInspect implements `tickdb inspect`, which reads SSTables and WAL
+// segments straight from disk. It is safe to run next to a server.
+func runInspect(args []string) int {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "Usage: tickdb inspect command [flags] args")
+		fmt.Fprintln(os.Stderr, "  footer   prints the footer of an SSTable and where its index block is")
+		fmt.Fprintln(os.Stderr, "  index    prints the index of an SSTable, one key a line")
+		fmt.Fprintln(os.Stderr, "  entries  prints the entries of an SSTable, one a line")
+		fmt.Fprintln(os.Stderr, "  wal      prints the records of a WAL segment, one a line")
+		fmt.Fprintln(os.Stderr, "  verify   checks the SSTables and WAL segments in files and directories, and the")
+		fmt.Fprintln(os.Stderr, "           checksums of backup directories")
+		fmt.Fprintln(os.Stderr, "  summary  sums up the series, time ranges and point counts of files and directories")
+	}
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		usage()
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+	command, ok := inspectCommands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown inspect command %q\n", args[0])
+		usage()
+		return 2
+	}
+
+	fs := flag.NewFlagSet("inspect "+args[0], flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: tickdb inspect", command.usage)
+		fs.PrintDefaults()
+	}
+	err := command.run(fs, args[1:])
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	case errors.Is(err, errFailed):
+		return 1
+	}
+	fmt.Fprintln(os.Stderr, "inspect failed:", err)
+	return 1
+}
+
+// parseArgs parses the flags of an inspect command and checks it got between
+// min and max arguments, max < 0 for no limit
+func parseArgs(fs *flag.FlagSet, args []string, min, max int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if fs.NArg() < min || (max >= 0 && fs.NArg() > max) {
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+func openTable(path string) (*os.File, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+func inspectFooter(fs *flag.FlagSet, args []string) error {
+	if err := parseArgs(fs, args, 1, 1); err != nil {
+		return err
+	}
+	f, size, err := openTable(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	footer, err := sstable.ReadFooter(f, size)
+	if err != nil {
+		return err
+	}
+	return printJSON(footer)
+}
+
+func inspectIndex(fs *flag.FlagSet, args []string) error {
+	if err := parseArgs(fs, args, 1, 1); err != nil {
+		return err
+	}
+	f, size, err := openTable(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	footer, err := sstable.ReadFooter(f, size)
+	if err != nil {
+		return err
+	}
+	index, err := sstable.ReadIndexBlock(f, footer)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(index))
+	for key := range index {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return index[keys[i]] < index[keys[j]] })
+	for _, key := range keys {
+		if err := printJSON(struct {
+			Key    string `json:"key"`
+			Offset int64  `json:"offset"`
+		}{key, index[key]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func inspectEntries(fs *flag.FlagSet, args []string) error {
+	key := fs.String("key", "", "only print the entry of this series key")
+	points := fs.Bool("points", false, "print the points of every entry")
+	if err := parseArgs(fs, args, 1, 1); err != nil {
+		return err
+	}
+	f, size, err := openTable(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// without a footer the entries are read until they stop making sense
+	end := size
+	footer, footerErr := sstable.ReadFooter(f, size)
+	if footerErr == nil {
+		end = footer.IndexOffset
+	} else {
+		fmt.Fprintln(os.Stderr, footerErr)
+	}
+
+	type entryLine struct {
+		Offset int64             `json:"offset"`
+		Length int32             `json:"length"`
+		Key    string            `json:"key"`
+		Points int               `json:"points"`
+		First  int64             `json:"first,omitempty"`
+		Last   int64             `json:"last,omitempty"`
+		Values []*ingestpb.Point `json:"values,omitempty"`
+	}
+	_, err = sstable.ScanEntries(f, end, func(offset int64, length int32, entry *sstable.SSTableEntry) error {
+		if *key != "" && entry.Key != *key {
+			return nil
+		}
+		line := entryLine{Offset: offset, Length: length, Key: entry.Key, Points: len(entry.Value)}
+		for i, p := range entry.Value {
+			if p == nil {
+				continue
+			}
+			if i == 0 || p.TimestampUnixNano < line.First {
+				line.First = p.TimestampUnixNano
+			}
+			if i == 0 || p.TimestampUnixNano > line.Last {
+				line.Last = p.TimestampUnixNano
+			}
+		}
+		if *points {
+			line.Values = entry.Value
+		}
+		return printJSON(line)
+	})
+	if err != nil {
+		return err
+	}
+	if footerErr != nil {
+		return errFailed
+	}
+	return nil
+}
+
+func inspectWAL(fs *flag.FlagSet, args []string) error {
+	limit := fs.Int("limit", 0, "stop after this many records, 0 for no limit")
+	if err := parseArgs(fs, args, 1, 1); err != nil {
+		return err
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	errLimit := errors.New("limit")
+	_, err = wal.ScanSegment(f, func(line int, offset int64, p *ingestpb.Point) error {
+		if *limit > 0 && line > *limit {
+			return errLimit
+		}
+		return printJSON(struct {
+			Line   int             `json:"line"`
+			Offset int64           `json:"offset"`
+			Point  *ingestpb.Point `json:"point"`
+		}{line, offset, p})
+	})
+	if errors.Is(err, errLimit) {
+		return nil
+	}
+	return err
+}
+
+func inspectVerify(fs *flag.FlagSet, args []string) error {
+	if err := parseArgs(fs, args, 1, -1); err != nil {
+		return err
+	}
+	problems, files, err := inspect.Verify(fs.Args())
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Printf("%s: %s\n", p.Path, p.Message)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%d problems found checking %d files\n", len(problems), files)
+		return errFailed
+	}
+	fmt.Fprintf(os.Stderr, "%d files verified\n", files)
+	return nil
+}
+
+func inspectSummary(fs *flag.FlagSet, args []string) error {
+	asJSON := fs.Bool("json", false, "print the summary as JSON")
+	if err := parseArgs(fs, args, 1, -1); err != nil {
+		return err
+	}
+	summary, err := inspect.Summarize(fs.Args())
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(summary)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tKIND\tDATABASE\tBYTES\tSERIES\tPOINTS\tFIRST\tLAST\t")
+	for _, f := range summary.Files {
+		kind := f.Kind
+		if f.Flushed {
+			kind += " (flushed)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\t\n", f.Path, kind, f.Database, f.Bytes, f.Series, f.Points, formatTime(f.Range, f.First), formatTime(f.Range, f.Last))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "DATABASE\tSERIES\tFILES\tPOINTS\tFIRST\tLAST\t")
+	for _, s := range summary.Series {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\t\n", s.Database, s.Key, s.Files, s.Points, formatTime(s.Range, s.First), formatTime(s.Range, s.Last))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, f := range summary.Files {
+		if f.Error != "" {
+			failed = append(failed, fmt.Sprintf("%s: %s", f.Path, f.Error))
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Fprintln(os.Stderr, "some files could only be read partly:")
+		fmt.Fprintln(os.Stderr, strings.Join(failed, "\n"))
+		return errFailed
+	}
+	return nil
+}
+
+func formatTime(r inspect.Range, ts int64) string {
+	if r.Points == 0 {
+		return "-"
+	}
+	return time.Unix(0, ts).UTC().Format(time.RFC3339Nano)
+}
+
+func printJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Printf("%s\n", data)
+	return err
+}
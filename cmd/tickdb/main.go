@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
@@ -11,138 +14,414 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	ingestpipeline "github.com/heyyakash/tickdb/internal/ingest-pipeline"
-	memtable "github.com/heyyakash/tickdb/internal/mem-table"
+	"github.com/heyyakash/tickdb/internal/auth"
+	"github.com/heyyakash/tickdb/internal/config"
+	"github.com/heyyakash/tickdb/internal/database"
+	"github.com/heyyakash/tickdb/internal/health"
+	"github.com/heyyakash/tickdb/internal/limits"
+	"github.com/heyyakash/tickdb/internal/logging"
 	"github.com/heyyakash/tickdb/internal/server"
-	"github.com/heyyakash/tickdb/internal/sstable"
-	"github.com/heyyakash/tickdb/internal/wal"
+	"github.com/heyyakash/tickdb/internal/tlsconfig"
 	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+	querypb "github.com/heyyakash/tickdb/proto/gen/query"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
 )
 
-var port = "50051"
+// version is reported on /status, set it at build time with
+// -ldflags "-X main.version=..."
+var version = "dev"
 
-func initWAL() *wal.WAL {
-	wal, err := wal.New("wal.log")
+// commands are the subcommands run instead of the server, they return the
+// exit code
+var commands = map[string]func(args []string) int{
+	"import":  runImport,
+	"backup":  runBackup,
+	"restore": runRestore,
+	"inspect": runInspect,
+	"repair":  runRepair,
+}
+
+// fatal logs an error that keeps TickDB from starting and exits.
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+func initLogger(cfg *config.Config) *slog.Logger {
+	level, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		fatal(slog.Default(), "Invalid log level", err)
+	}
+	logger, err := logging.New(os.Stderr, level, cfg.LogFormat)
 	if err != nil {
-		log.Fatalf("Could't create WAL : %v", err.Error())
+		fatal(slog.Default(), "Invalid log format", err)
 	}
-	return wal
+	// requests are logged by server.RequestLogger, keep gin quiet
+	gin.SetMode(gin.ReleaseMode)
+	return logger
 }
 
-func initalizeMemTable() *memtable.MemTableService {
-	MemTable := make(map[string][]*ingestpb.Point)
-	MemTableService := memtable.NewMemTableService(MemTable)
-	return MemTableService
+// newRouter returns a gin engine logging requests through logger instead of
+// gin's own request log.
+func newRouter(logger *slog.Logger) *gin.Engine {
+	r := gin.New()
+	r.Use(gin.Recovery(), server.RequestLogger(logger))
+	return r
 }
 
-func initPipelineService(wal *wal.WAL, MemTableService *memtable.MemTableService, sst *sstable.SSTableService) *ingestpipeline.PipelineService {
-	pipelineService := ingestpipeline.NewPipeline(wal, MemTableService, sst)
-	pipelineService.WALReplay()
-	return pipelineService
+// initAuth opens the token store, or returns nil when auth is disabled. The
+// first start with an empty store creates an admin token and prints it once.
+func initAuth(cfg *config.Config, logger *slog.Logger) *auth.Store {
+	if !cfg.AuthEnabled {
+		return nil
+	}
+	store, err := auth.OpenStore(cfg.TokenFile)
+	if err != nil {
+		fatal(logger, "Couldn't open the token store", err)
+	}
+	if store.Len() == 0 {
+		token, secret, err := store.Create("bootstrap admin", []auth.Scope{auth.ScopeAdmin}, nil, nil)
+		if err != nil {
+			fatal(logger, "Couldn't create the initial admin token", err)
+		}
+		// the secret goes straight to the terminal, log files and collectors
+		// only get the ID
+		fmt.Fprintf(os.Stderr, "Initial admin token (it won't be shown again): %s\n", secret)
+		logger.Warn("Created the initial admin token, its secret was written to stderr", "id", token.ID)
+	}
+	return store
 }
 
-func initSSTableService(MemtableService *memtable.MemTableService) *sstable.SSTableService {
-	SstableService := sstable.NewSSTableService(MemtableService)
-	return SstableService
+// initTLS loads the listener certificates, or returns nil when TLS is off.
+// They are reloaded on SIGHUP and when the files change.
+func initTLS(ctx context.Context, cfg *config.Config, logger *slog.Logger) *tlsconfig.Reloader {
+	if !cfg.TLSEnabled() {
+		return nil
+	}
+	reloader, err := tlsconfig.New(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile, logger)
+	if err != nil {
+		fatal(logger, "Couldn't load TLS certificate", err)
+	}
+
+	go reloader.Watch(ctx, 10*time.Second)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-hup:
+				logger.Info("SIGHUP received, reloading TLS files")
+				if err := reloader.Reload(); err != nil {
+					logger.Error("Couldn't reload TLS files, keeping the previous certificate", "error", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return reloader
+}
+
+// serveHTTP serves srv on lis, over TLS when tlsFiles is set.
+func serveHTTP(srv *http.Server, lis net.Listener, tlsFiles *tlsconfig.Reloader) error {
+	if tlsFiles == nil {
+		return srv.Serve(lis)
+	}
+	srv.TLSConfig = tlsFiles.Config()
+	return srv.ServeTLS(lis, "", "")
+}
+
+// initDatabases opens the default database and every database created at
+// runtime.
+func initDatabases(cfg *config.Config, status *health.Status, logger *slog.Logger) *database.Catalog {
+	catalog, err := database.Open(database.Options{
+		DataDir:           cfg.DataDir,
+		WALDir:            cfg.WALDir,
+		SSTableDir:        cfg.SSTableDir,
+		Retention:         cfg.Retention,
+		QueueSize:         cfg.QueueSize,
+		FlushThreshold:    cfg.FlushThreshold,
+		MaxFuture:         cfg.MaxFuture,
+		MaxPointsPerQuery: cfg.MaxPointsPerQuery,
+		Limits: limits.Limits{
+			PointsPerSecond:         cfg.RateLimitPoints,
+			BytesPerSecond:          cfg.RateLimitBytes,
+			MaxSeries:               cfg.MaxSeries,
+			MaxSeriesPerMeasurement: cfg.MaxSeriesPerMeasurement,
+			MaxStoredBytes:          cfg.MaxStoredBytes,
+		},
+		Tokens: limits.NewRegistry(),
+		Health: status,
+	}, logger)
+	if err != nil {
+		fatal(logger, "Couldn't open databases", err)
+	}
+	return catalog
+}
+
+func servingStatus(serving bool) healthpb.HealthCheckResponse_ServingStatus {
+	if serving {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 	defer stop()
-	cwd, _ := os.Getwd()
-	log.Println("Starting TickDB from : ", cwd)
 
-	//setup wal
-	wal := initWAL()
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fatal(slog.Default(), "Invalid configuration", err)
+	}
+	logger := initLogger(cfg)
+	slog.SetDefault(logger)
+	logger.Info("Starting TickDB", "version", version, "config", cfg)
+
+	// readiness waits for the wal replay and every listener
+	status := health.New("grpc", "ingest_http", "query_http")
+
+	// every database has its own wal, memtable, sstables and ingest pipeline
+	catalog := initDatabases(cfg, status, logger)
+	go catalog.EnforceRetention(ctx, 10*time.Minute)
 
-	//setup memTable service
-	memtableService := initalizeMemTable()
+	// API tokens, nil when auth is disabled
+	tokens := initAuth(cfg, logger)
 
-	//setup SSTable service
-	sstableService := initSSTableService(memtableService)
+	unaryInterceptors := []grpc.UnaryServerInterceptor{server.LoggingUnaryInterceptor(logger), server.DatabaseUnaryInterceptor}
+	streamInterceptors := []grpc.StreamServerInterceptor{server.LoggingStreamInterceptor(logger), server.DatabaseStreamInterceptor}
+	if tokens != nil {
+		unaryInterceptors = append(unaryInterceptors, server.AuthUnaryInterceptor(tokens))
+		streamInterceptors = append(streamInterceptors, server.AuthStreamInterceptor(tokens))
+	}
+	unaryInterceptors = append(unaryInterceptors, server.QueryMetricsUnaryInterceptor)
+	streamInterceptors = append(streamInterceptors, server.QueryMetricsStreamInterceptor)
 
-	//setup pipeline service
-	pipelineService := initPipelineService(wal, memtableService, sstableService)
+	// TLS for every listener, nil when serving plaintext
+	tlsFiles := initTLS(ctx, cfg, logger)
 
 	// setup grpc server
-	grpc_server := grpc.NewServer()
-	ingestpb.RegisterInjestServiceServer(grpc_server, server.NewInjestServer(pipelineService))
+	// keepalive settings let StreamWrite connections stay open for long periods
+	grpcOptions := []grpc.ServerOption{
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    2 * time.Minute,
+			Timeout: 20 * time.Second,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             30 * time.Second,
+			PermitWithoutStream: true,
+		}),
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	}
+	if tlsFiles != nil {
+		grpcOptions = append(grpcOptions, grpc.Creds(credentials.NewTLS(tlsFiles.Config())))
+	}
+	grpc_server := grpc.NewServer(grpcOptions...)
+	ingestpb.RegisterInjestServiceServer(grpc_server, server.NewInjestServer(catalog, logger))
+
+	// OTLP metrics receiver, served over grpc and on the ingest rest server
+	otlpService := server.NewOTLPMetricsServer(catalog, logger)
+	colmetricspb.RegisterMetricsServiceServer(grpc_server, otlpService)
+
+	querypb.RegisterQueryServiceServer(grpc_server, server.NewQueryService(catalog, logger))
 
-	lis, err := net.Listen("tcp", ":"+port)
+	// grpc health protocol, services report SERVING once tickdb is ready. The
+	// write services report NOT_SERVING while storage is degraded.
+	healthServer := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(grpc_server, healthServer)
+	readServices := []string{"", querypb.QueryService_ServiceDesc.ServiceName}
+	writeServices := []string{ingestpb.InjestService_ServiceDesc.ServiceName, colmetricspb.MetricsService_ServiceDesc.ServiceName}
+	updateGRPCHealth := func() {
+		ready, _ := status.Ready()
+		writable := ready && len(status.Degraded()) == 0
+		for _, name := range readServices {
+			healthServer.SetServingStatus(name, servingStatus(ready))
+		}
+		for _, name := range writeServices {
+			healthServer.SetServingStatus(name, servingStatus(writable))
+		}
+	}
+	updateGRPCHealth()
+	status.OnReady(func() {
+		updateGRPCHealth()
+		logger.Info("TickDB is ready")
+	})
+	status.OnDegradedChange(func(map[string]string) { updateGRPCHealth() })
+
+	lis, err := net.Listen("tcp", cfg.GRPCAddr)
 	if err != nil {
-		log.Fatalf("Couldn't start server : %s", err.Error())
+		fatal(logger, "Couldn't start grpc server", err)
 	}
+	status.SetListening("grpc")
 
 	// start grpc server go-routine
 	go func() {
-		log.Printf("TickDB grpc_server is listening at port : %s", port)
+		logger.Info("grpc server listening", "addr", cfg.GRPCAddr)
 		if err := grpc_server.Serve(lis); err != nil {
-			log.Fatalf("grpc couldn't listen to port %s : %v", port, err.Error())
+			fatal(logger, "grpc server failed", err)
 		}
 	}()
 
+	statusService := server.NewStatusServer(status, version, cfg.DataDir, catalog, logger)
+
 	// setup rest server
-	r := gin.Default()
-	ingestRestService := server.NewIngestRestServer(pipelineService)
+	r := newRouter(logger)
+	statusService.SetupProbeHandlers(r)
+	if tokens != nil {
+		r.Use(server.RequireScope(tokens, auth.ScopeWrite))
+	}
+	r.Use(server.SelectDatabase())
+	ingestRestService := server.NewIngestRestServer(catalog, logger)
 
-	// register rest handlers for ingesting data
-	ingestRestService.SetupHandlers(r)
+	// register rest handlers for ingesting data, at the root for the database
+	// named in the header (or the default one) and under /db/<name>
+	for _, router := range []gin.IRouter{r, r.Group("db/:database")} {
+		statusService.SetupHandlers(router)
+		ingestRestService.SetupHandlers(router)
+		otlpService.SetupHandlers(router)
+	}
 
 	httpServer := &http.Server{
-		Addr:    ":8020",
+		Addr:    cfg.IngestHTTPAddr,
 		Handler: r.Handler(),
 	}
 
+	ingestLis, err := net.Listen("tcp", cfg.IngestHTTPAddr)
+	if err != nil {
+		fatal(logger, "Couldn't start ingest rest server", err)
+	}
+	logger.Info("Ingest rest server listening", "addr", cfg.IngestHTTPAddr)
+	status.SetListening("ingest_http")
+
 	//start ingest httpServer goroutine
 	go func() {
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Could'nt start rest api server : %v", err.Error())
+		if err := serveHTTP(httpServer, ingestLis, tlsFiles); err != nil && err != http.ErrServerClosed {
+			fatal(logger, "Ingest rest server failed", err)
 		}
 	}()
 
 	// setup second http server for querying
-	r2 := gin.Default()
-	queryRestService := server.NewQueryServer(memtableService, sstableService)
-	queryRestService.SetupHandlers(r2)
+	r2 := newRouter(logger)
+
+	// prometheus metrics and the probes, registered before authentication
+	// and the query latency middleware so scrapes aren't counted as queries
+	r2.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	statusService.SetupProbeHandlers(r2)
+	if tokens != nil {
+		r2.Use(server.RequireScope(tokens, auth.ScopeRead))
+		server.NewTokenAdminServer(tokens).SetupHandlers(r2)
+	}
+	server.NewDatabaseAdminServer(catalog, tokens).SetupHandlers(r2)
+	server.NewBackupServer(catalog, tokens, cfg.BackupDir, logger).SetupHandlers(r2)
+	r2.Use(server.QueryMetrics(), server.SelectDatabase())
+
+	queryRestService := server.NewQueryServer(catalog, logger)
+	cardinalityService := server.NewCardinalityServer(catalog, logger)
+
+	// prometheus remote_read and query api for grafana
+	promService := server.NewPromServer(catalog, logger)
+
+	for _, router := range []gin.IRouter{r2, r2.Group("db/:database")} {
+		statusService.SetupHandlers(router)
+		queryRestService.SetupHandlers(router)
+		promService.SetupHandlers(router)
+		cardinalityService.SetupHandlers(router)
+	}
 
 	queryHTTPServer := &http.Server{
-		Addr:    ":8021",
+		Addr:    cfg.QueryHTTPAddr,
 		Handler: r2.Handler(),
 	}
 
+	queryLis, err := net.Listen("tcp", cfg.QueryHTTPAddr)
+	if err != nil {
+		fatal(logger, "Couldn't start query rest server", err)
+	}
+	logger.Info("Query rest server listening", "addr", cfg.QueryHTTPAddr)
+	status.SetListening("query_http")
+
+	go func() {
+		if err := serveHTTP(queryHTTPServer, queryLis, tlsFiles); err != nil && err != http.ErrServerClosed {
+			fatal(logger, "Query rest server failed", err)
+		}
+	}()
+
+	// replay the wal once the listeners are up so /health answers while a
+	// large wal is loading, /ready reports the replay as pending until then
 	go func() {
-		if err := queryHTTPServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Couldn't start query rest server : %v", err.Error())
+		if err := catalog.Replay(); err != nil {
+			logger.Error("WAL replay failed, serving reads only", "error", err)
 		}
+		status.SetReplayed()
 	}()
 
 	<-ctx.Done()
-	log.Println("Signal to Shutdown received! Shutting down gracefully...")
+	logger.Info("Signal to Shutdown received! Shutting down gracefully...")
 
 	stop()
 
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
+	// the servers get half of the deadline so the rest is left for draining
+	// the pipeline
+	serversCtx, cancelServers := context.WithTimeout(shutdownCtx, cfg.ShutdownTimeout/2)
+	defer cancelServers()
+
+	healthServer.Shutdown()
+
 	//Stopping Query Server
-	log.Println("Stopping Query REST Server...")
-	if err := queryHTTPServer.Shutdown(shutdownCtx); err != nil {
-		log.Fatalf("Error in stopping the Qyery rest api server : %v", err.Error())
+	logger.Info("Stopping query rest server...")
+	if err := queryHTTPServer.Shutdown(serversCtx); err != nil {
+		logger.Error("Error in stopping the query rest server", "error", err)
 	}
 
 	//Stopping rest api server
-	log.Println("Stopping rest api server...")
-	if err := httpServer.Shutdown(shutdownCtx); err != nil {
-		log.Fatalf("Error in stopping the rest api server : %v", err.Error())
+	logger.Info("Stopping ingest rest server...")
+	if err := httpServer.Shutdown(serversCtx); err != nil {
+		logger.Error("Error in stopping the ingest rest server", "error", err)
 	}
 
-	//Stopping grpc server
-	log.Println("Stopping grpc server...")
-	grpc_server.GracefulStop()
-
-	//Stopping ingest channel
-	pipelineService.Close()
-	log.Println("TickDB Stopped gracefully")
+	//Stopping grpc server, long lived streams are cut off at the deadline
+	logger.Info("Stopping grpc server...")
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpc_server.GracefulStop()
+		close(grpcStopped)
+	}()
+	select {
+	case <-grpcStopped:
+	case <-serversCtx.Done():
+		logger.Warn("grpc server didn't stop in time, closing open streams")
+		grpc_server.Stop()
+	}
 
+	//Draining ingest channels and closing the WALs
+	logger.Info("Draining ingest pipelines...")
+	reports, err := catalog.Shutdown(shutdownCtx, cfg.FlushOnShutdown)
+	for name, report := range reports {
+		logger.Info("Shutdown report", "database", name, "report", report)
+	}
+	if err != nil {
+		fatal(logger, "TickDB stopped with errors", err)
+	}
+	logger.Info("TickDB Stopped gracefully")
 }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/heyyakash/tickdb/internal/config"
+	"github.com/heyyakash/tickdb/internal/database"
+	"github.com/heyyakash/tickdb/internal/repair"
+)
+
+// runRepair implements `tickdb repair`, which fixes the WAL segments and
+// SSTables a crash left damaged so a server can start again. The server must
+// be stopped.
+func runRepair(args []string) int {
+	fs := flag.NewFlagSet("repair", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: tickdb repair [flags]")
+		fmt.Fprintln(fs.Output(), "Cuts off WAL records that can't be parsed and rebuilds the index of damaged SSTables")
+		fmt.Fprintln(fs.Output(), "in the data directories of a stopped server. What can't be kept is moved to lost+found.")
+		fs.PrintDefaults()
+	}
+	// the directories are resolved like the server does
+	var serverArgs []string
+	for _, name := range []string{"config", "data-dir", "wal-dir", "sstable-dir"} {
+		fs.Func(name, "same as the server flag", func(v string) error {
+			serverArgs = append(serverArgs, "-"+name, v)
+			return nil
+		})
+	}
+	dryRun := fs.Bool("dry-run", false, "only report what would be repaired")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	cfg, err := config.Load(serverArgs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		return 1
+	}
+	dbOpts := database.Options{DataDir: cfg.DataDir, WALDir: cfg.WALDir, SSTableDir: cfg.SSTableDir}
+	names, err := database.Names(dbOpts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "couldn't list the databases:", err)
+		return 1
+	}
+	opts := repair.Options{LostFound: filepath.Join(cfg.DataDir, repair.LostFound), DryRun: *dryRun}
+	for _, name := range names {
+		walDir, sstableDir, _ := database.Dirs(dbOpts, name)
+		opts.Databases = append(opts.Databases, repair.Database{Name: name, WALDir: walDir, SSTableDir: sstableDir})
+	}
+
+	report, err := repair.Run(opts)
+	for _, a := range report.Actions {
+		fmt.Printf("%s %s: %s\n", a.Action, a.Path, a.Reason)
+		if a.Saved != "" {
+			fmt.Printf("  saved to %s\n", a.Saved)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "repair failed:", err)
+		return 1
+	}
+	switch {
+	case len(report.Actions) == 0:
+		fmt.Fprintf(os.Stderr, "%d files checked in %d databases, nothing to repair\n", report.Files, len(names))
+	case *dryRun:
+		fmt.Fprintf(os.Stderr, "%d files checked in %d databases, %d would be repaired\n", report.Files, len(names), len(report.Actions))
+	default:
+		fmt.Fprintf(os.Stderr, "%d files checked in %d databases, %d repaired\n", report.Files, len(names), len(report.Actions))
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+// Package auth implements API-token authentication. Tokens carry scopes and
+// can be limited to a set of measurements. Only a hash of each token is
+// stored.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var (
+	ErrMissingToken = errors.New("no API token provided")
+	ErrInvalidToken = errors.New("invalid API token")
+)
+
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	// admin manages tokens and implies read and write
+	ScopeAdmin Scope = "admin"
+)
+
+// ParseScope checks that s names a known scope.
+func ParseScope(s string) (Scope, error) {
+	switch scope := Scope(s); scope {
+	case ScopeRead, ScopeWrite, ScopeAdmin:
+		return scope, nil
+	}
+	return "", fmt.Errorf("unknown scope %q, expected read, write or admin", s)
+}
+
+// Token is a stored API token. The secret itself is never stored.
+type Token struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	Hash   string  `json:"hash"`
+	Scopes []Scope `json:"scopes"`
+	// measurements the token may read and write, empty allows all of them
+	Measurements []string `json:"measurements,omitempty"`
+	// optional limit on the writes made with the token, across databases
+	RateLimit *RateLimit `json:"rate_limit,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RateLimit caps the points and bytes per second written with a token. Zero
+// disables a limit.
+type RateLimit struct {
+	PointsPerSecond int `json:"points_per_second,omitempty"`
+	BytesPerSecond  int `json:"bytes_per_second,omitempty"`
+}
+
+// Allows reports whether the token grants scope.
+func (t *Token) Allows(scope Scope) bool {
+	return slices.Contains(t.Scopes, scope) || slices.Contains(t.Scopes, ScopeAdmin)
+}
+
+// AllowsMeasurement reports whether the token may access measurement.
+func (t *Token) AllowsMeasurement(measurement string) bool {
+	return len(t.Measurements) == 0 || slices.Contains(t.Measurements, measurement)
+}
+
+type tokenKey struct{}
+
+// WithToken returns a context carrying the authenticated token.
+func WithToken(ctx context.Context, t *Token) context.Context {
+	return context.WithValue(ctx, tokenKey{}, t)
+}
+
+// FromContext returns the authenticated token, nil when auth is disabled.
+func FromContext(ctx context.Context) *Token {
+	t, _ := ctx.Value(tokenKey{}).(*Token)
+	return t
+}
+
+// AllowsMeasurement reports whether the caller may access measurement. It is
+// true when the request isn't authenticated, i.e. auth is disabled.
+func AllowsMeasurement(ctx context.Context, measurement string) bool {
+	t := FromContext(ctx)
+	return t == nil || t.AllowsMeasurement(measurement)
+}
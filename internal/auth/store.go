@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// prefix of every token secret, it makes leaked tokens easy to grep for
+const secretPrefix = "tdb_"
+
+var ErrTokenNotFound = errors.New("token not found")
+
+// Store keeps the tokens in a JSON file.
+type Store struct {
+	mu     sync.RWMutex
+	path   string
+	tokens map[string]*Token // by hash
+}
+
+type storeFile struct {
+	Tokens []*Token `json:"tokens"`
+}
+
+// OpenStore loads the tokens in path. A missing file is an empty store.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, tokens: make(map[string]*Token)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+
+	var file storeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("couldn't parse token file %s : %w", path, err)
+	}
+	for _, t := range file.Tokens {
+		s.tokens[t.Hash] = t
+	}
+	return s, nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Authenticate returns the token for secret.
+func (s *Store) Authenticate(secret string) (*Token, error) {
+	if secret == "" {
+		return nil, ErrMissingToken
+	}
+	if !strings.HasPrefix(secret, secretPrefix) {
+		return nil, ErrInvalidToken
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.tokens[hashSecret(secret)]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return t, nil
+}
+
+// Create adds a token and returns it with its secret. The secret can't be
+// recovered later.
+func (s *Store) Create(name string, scopes []Scope, measurements []string, rateLimit *RateLimit) (*Token, string, error) {
+	if len(scopes) == 0 {
+		return nil, "", errors.New("a token needs at least one scope")
+	}
+	for _, scope := range scopes {
+		if _, err := ParseScope(string(scope)); err != nil {
+			return nil, "", err
+		}
+	}
+	for _, m := range measurements {
+		if m == "" {
+			return nil, "", errors.New("measurement names must not be empty")
+		}
+	}
+	if rateLimit != nil && (rateLimit.PointsPerSecond < 0 || rateLimit.BytesPerSecond < 0) {
+		return nil, "", errors.New("rate limits must not be negative")
+	}
+
+	secret := secretPrefix + randomHex(32)
+	t := &Token{
+		ID:           randomHex(8),
+		Name:         name,
+		Hash:         hashSecret(secret),
+		Scopes:       scopes,
+		Measurements: measurements,
+		RateLimit:    rateLimit,
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[t.Hash] = t
+	if err := s.save(); err != nil {
+		delete(s.tokens, t.Hash)
+		return nil, "", err
+	}
+	return t, secret, nil
+}
+
+// Revoke deletes the token with the given id.
+func (s *Store) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, t := range s.tokens {
+		if t.ID != id {
+			continue
+		}
+		delete(s.tokens, hash)
+		if err := s.save(); err != nil {
+			s.tokens[hash] = t
+			return err
+		}
+		return nil
+	}
+	return ErrTokenNotFound
+}
+
+// List returns the tokens ordered by creation time.
+func (s *Store) List() []Token {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := make([]Token, 0, len(s.tokens))
+	for _, t := range s.tokens {
+		tokens = append(tokens, *t)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	return tokens
+}
+
+// Len returns the number of tokens.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.tokens)
+}
+
+// save writes the store to a temporary file and renames it over the token
+// file, so a crash never leaves a half written file. The caller holds the lock.
+func (s *Store) save() error {
+	file := storeFile{Tokens: make([]*Token, 0, len(s.tokens))}
+	for _, t := range s.tokens {
+		file.Tokens = append(file.Tokens, t)
+	}
+	sort.Slice(file.Tokens, func(i, j int) bool { return file.Tokens[i].ID < file.Tokens[j].ID })
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,98 @@
+// Package backup writes consistent snapshots of TickDB databases into a
+// directory or a gzipped tarball, described by a manifest with the checksum
+// of every file, and restores them. Incremental backups leave out the
+// SSTables already stored in a base backup.
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+)
+
+// ManifestFile is the name of the manifest in a backup. It is written last,
+// a backup without one is incomplete.
+const ManifestFile = "manifest.json"
+
+// formatVersion is the version of the backup layout
+const formatVersion = 1
+
+// Kinds of files in a backup, the directory they are in below the database
+// directory.
+const (
+	KindSSTable = "sstable"
+	KindWAL     = "wal"
+	// settings of a database created at runtime, stored as database.json
+	KindMeta = "meta"
+)
+
+// Manifest lists the databases and files of a backup.
+type Manifest struct {
+	Version   int       `json:"version"`
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	// ID of the backup this one is incremental to
+	Base      string     `json:"base,omitempty"`
+	Databases []Database `json:"databases"`
+}
+
+// Database lists the files backed up for a database.
+type Database struct {
+	Name  string `json:"name"`
+	Files []File `json:"files"`
+}
+
+// File is a file of a backup.
+type File struct {
+	// slash separated path in the backup, <database>/<kind dir>/<name>
+	Path   string `json:"path"`
+	Kind   string `json:"kind"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+	// the file is stored in the base backup, or one of its bases
+	InBase bool `json:"in_base,omitempty"`
+}
+
+// NewManifest starts the manifest of a backup, incremental to base when it
+// isn't nil.
+func NewManifest(base *Manifest) *Manifest {
+	now := time.Now().UTC()
+	m := &Manifest{Version: formatVersion, ID: now.Format("20060102T150405.000000000Z"), CreatedAt: now}
+	if base != nil {
+		m.Base = base.ID
+	}
+	return m
+}
+
+// FilePath returns the path of a file named name of a database in a backup.
+func FilePath(database, kind, name string) string {
+	if kind == KindMeta {
+		return path.Join(database, "database.json")
+	}
+	return path.Join(database, kind, name)
+}
+
+// Lookup returns the file stored at p, as listed by the manifest.
+func (m *Manifest) Lookup(p string) (File, bool) {
+	for _, db := range m.Databases {
+		for _, f := range db.Files {
+			if f.Path == p {
+				return f, true
+			}
+		}
+	}
+	return File{}, false
+}
+
+// ParseManifest decodes a manifest and checks its version.
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid backup manifest : %w", err)
+	}
+	if m.Version != formatVersion {
+		return nil, fmt.Errorf("unsupported backup version %d", m.Version)
+	}
+	return &m, nil
+}
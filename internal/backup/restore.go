@@ -0,0 +1,441 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrIncomplete is returned for a backup without a manifest.
+var ErrIncomplete = errors.New("The backup is incomplete, it has no manifest")
+
+// restoreSuffix marks files written by a restore that isn't complete yet
+const restoreSuffix = ".restoring"
+
+// Destination returns where the WAL, the SSTables and the settings of a
+// database are restored to. meta is empty when the database has no settings
+// file.
+type Destination func(database string) (walDir, sstableDir, meta string)
+
+type RestoreOptions struct {
+	// the backup to restore followed by its base, the base of that and so on.
+	// Each is a backup directory or a gzipped tarball.
+	Sources []string
+	Dest    Destination
+	// databases to restore, all of them when empty
+	Databases []string
+	// only verify the backup, nothing is written
+	CheckOnly bool
+	// replace the WAL and SSTables of databases that already have data
+	Force bool
+}
+
+// RestoreReport describes a restore.
+type RestoreReport struct {
+	ID        string   `json:"id"`
+	Databases []string `json:"databases"`
+	Files     int      `json:"files"`
+	Bytes     int64    `json:"bytes"`
+	// files read from a base backup
+	FromBase int `json:"from_base"`
+}
+
+// staged is a file read from a backup, written under a temporary name next to
+// where it is restored to
+type staged struct {
+	tmp    string
+	sha256 string
+	bytes  int64
+}
+
+type restore struct {
+	opts   RestoreOptions
+	staged map[string]staged
+}
+
+// Restore reads a backup and its bases, verifies the size and checksum of
+// every file against the manifest and only then moves the files in place.
+// TickDB must not be running on the destination.
+func Restore(opts RestoreOptions) (*RestoreReport, error) {
+	if len(opts.Sources) == 0 {
+		return nil, fmt.Errorf("no backup given")
+	}
+	r := &restore{opts: opts, staged: make(map[string]staged)}
+	ok := false
+	defer func() {
+		if !ok {
+			r.cleanup()
+		}
+	}()
+
+	wanted := make(map[string]bool)
+	for _, name := range opts.Databases {
+		wanted[name] = true
+	}
+	wantDatabase := func(db string) bool { return len(wanted) == 0 || wanted[db] }
+
+	m, err := r.read(opts.Sources[0], func(p string) bool {
+		db, _, _, ok := parsePath(p)
+		return ok && wantDatabase(db)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RestoreReport{ID: m.ID}
+	var files []File
+	for _, db := range m.Databases {
+		if !wantDatabase(db.Name) {
+			continue
+		}
+		delete(wanted, db.Name)
+		report.Databases = append(report.Databases, db.Name)
+		files = append(files, db.Files...)
+	}
+	if len(wanted) > 0 {
+		missing := make([]string, 0, len(wanted))
+		for name := range wanted {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return nil, fmt.Errorf("the backup has no database %s", strings.Join(missing, ", "))
+	}
+
+	// files of an incremental backup are read from its bases, newest first
+	needed := make(map[string]bool)
+	for _, f := range files {
+		if f.InBase {
+			needed[f.Path] = true
+		}
+	}
+	report.FromBase = len(needed)
+	baseID := m.Base
+	for _, source := range opts.Sources[1:] {
+		if baseID == "" {
+			return nil, fmt.Errorf("%s isn't needed, the backups before it aren't incremental", source)
+		}
+		base, err := r.read(source, func(p string) bool { return needed[p] && r.staged[p].tmp == "" })
+		if err != nil {
+			return nil, err
+		}
+		if base.ID != baseID {
+			return nil, fmt.Errorf("%s is backup %s, but the base needed is %s", source, base.ID, baseID)
+		}
+		baseID = base.Base
+	}
+
+	// every file has to be there and match before anything is replaced
+	var errs []error
+	for _, f := range files {
+		s, ok := r.staged[f.Path]
+		switch {
+		case !ok && f.InBase:
+			errs = append(errs, fmt.Errorf("%s is in base backup %s, which wasn't given", f.Path, m.Base))
+		case !ok:
+			errs = append(errs, fmt.Errorf("%s is missing", f.Path))
+		case s.bytes != f.Bytes:
+			errs = append(errs, fmt.Errorf("%s has %d bytes, the manifest says %d", f.Path, s.bytes, f.Bytes))
+		case s.sha256 != f.SHA256:
+			errs = append(errs, fmt.Errorf("%s has checksum %s, the manifest says %s", f.Path, s.sha256, f.SHA256))
+		}
+		report.Files++
+		report.Bytes += f.Bytes
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("the backup failed verification : %w", err)
+	}
+	if opts.CheckOnly {
+		ok = true
+		return report, nil
+	}
+
+	for _, db := range report.Databases {
+		if err := r.clear(db); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range files {
+		s := r.staged[f.Path]
+		if err := os.Rename(s.tmp, strings.TrimSuffix(s.tmp, restoreSuffix)); err != nil {
+			return nil, fmt.Errorf("couldn't move %s in place : %w", f.Path, err)
+		}
+		delete(r.staged, f.Path)
+	}
+	ok = true
+	r.cleanup()
+	return report, nil
+}
+
+// read reads the files of a backup for which want returns true into their
+// destination and returns its manifest
+func (r *restore) read(source string, want func(path string) bool) (*Manifest, error) {
+	var m *Manifest
+	visit := func(p string, rd io.Reader) error {
+		if p == ManifestFile {
+			data, err := io.ReadAll(rd)
+			if err != nil {
+				return err
+			}
+			m, err = ParseManifest(data)
+			return err
+		}
+		if !want(p) {
+			return nil
+		}
+		return r.stage(p, rd)
+	}
+
+	if err := walk(source, visit); err != nil {
+		return nil, fmt.Errorf("couldn't read backup %s : %w", source, err)
+	}
+	if m == nil {
+		return nil, fmt.Errorf("%s : %w", source, ErrIncomplete)
+	}
+	return m, nil
+}
+
+// ReadManifest returns the manifest of the backup directory or tarball at
+// source.
+func ReadManifest(source string) (*Manifest, error) {
+	r := &restore{opts: RestoreOptions{CheckOnly: true}}
+	return r.read(source, func(string) bool { return false })
+}
+
+// Extract writes the backup tarball read from rd into dir, which must not
+// exist yet or be empty, and returns its manifest. Paths are checked like on
+// restore.
+func Extract(rd io.Reader, dir string) (*Manifest, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(entries) > 0 {
+		return nil, fmt.Errorf("backup directory %s is not empty", dir)
+	}
+
+	var m *Manifest
+	err = readTar(rd, func(p string, rd io.Reader) error {
+		if p == ManifestFile {
+			data, err := io.ReadAll(rd)
+			if err != nil {
+				return err
+			}
+			if m, err = ParseManifest(data); err != nil {
+				return err
+			}
+			return os.WriteFile(filepath.Join(dir, ManifestFile), data, 0644)
+		}
+		if _, _, _, ok := parsePath(p); !ok {
+			return fmt.Errorf("unexpected file %s in the backup", p)
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, rd)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrIncomplete
+	}
+	return m, nil
+}
+
+// stage writes a file of the backup next to its destination, or only
+// checksums it when checking
+func (r *restore) stage(p string, rd io.Reader) error {
+	db, kind, name, ok := parsePath(p)
+	if !ok {
+		return fmt.Errorf("unexpected file %s in the backup", p)
+	}
+
+	h := sha256.New()
+	if r.opts.CheckOnly {
+		n, err := io.Copy(h, rd)
+		if err != nil {
+			return fmt.Errorf("couldn't read %s : %w", p, err)
+		}
+		// the path only marks the file as read
+		r.staged[p] = staged{tmp: "-", bytes: n, sha256: hex.EncodeToString(h.Sum(nil))}
+		return nil
+	}
+
+	dst, err := r.destination(db, kind, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp := dst + restoreSuffix
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(io.MultiWriter(f, h), rd)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("couldn't restore %s : %w", p, err)
+	}
+	r.staged[p] = staged{tmp: tmp, bytes: n, sha256: hex.EncodeToString(h.Sum(nil))}
+	return nil
+}
+
+// destination is where a file of a database is restored to
+func (r *restore) destination(db, kind, name string) (string, error) {
+	walDir, sstableDir, meta := r.opts.Dest(db)
+	switch kind {
+	case KindSSTable:
+		return filepath.Join(sstableDir, name), nil
+	case KindWAL:
+		return filepath.Join(walDir, name), nil
+	}
+	if meta == "" {
+		return "", fmt.Errorf("database %s can't have a settings file", db)
+	}
+	return meta, nil
+}
+
+// clear makes sure the destination of a database holds no data, removing it
+// when Force is set
+func (r *restore) clear(db string) error {
+	walDir, sstableDir, _ := r.opts.Dest(db)
+	for _, dir := range []string{walDir, sstableDir} {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !(strings.HasSuffix(name, ".sst") || strings.HasSuffix(name, ".log")) {
+				continue
+			}
+			if !r.opts.Force {
+				return fmt.Errorf("database %s already has data in %s, use force to replace it", db, dir)
+			}
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cleanup removes the staged files that weren't moved in place
+func (r *restore) cleanup() {
+	for _, s := range r.staged {
+		if strings.HasSuffix(s.tmp, restoreSuffix) {
+			os.Remove(s.tmp)
+		}
+	}
+}
+
+// parsePath splits the path of a database file in a backup, rejecting paths
+// that could point outside of the restore destination
+func parsePath(p string) (db, kind, name string, ok bool) {
+	if p != path.Clean(p) || path.IsAbs(p) || strings.Contains(p, "\\") {
+		return "", "", "", false
+	}
+	parts := strings.Split(p, "/")
+	for _, part := range parts {
+		if part == "" || part == "." || part == ".." {
+			return "", "", "", false
+		}
+	}
+	switch {
+	case len(parts) == 2 && parts[1] == "database.json":
+		return parts[0], KindMeta, parts[1], true
+	case len(parts) == 3 && (parts[1] == KindSSTable || parts[1] == KindWAL):
+		return parts[0], parts[1], parts[2], true
+	}
+	return "", "", "", false
+}
+
+// walkDir calls fn with the slash separated path and content of every file
+// of a backup directory
+func walkDir(dir string, fn func(p string, r io.Reader) error) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return fn(filepath.ToSlash(rel), f)
+	})
+}
+
+// walk calls fn with the slash separated path and content of every file of
+// the backup directory or tarball at source
+func walk(source string, fn func(p string, r io.Reader) error) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return walkDir(source, fn)
+	}
+	f, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return readTar(f, fn)
+}
+
+// readTar calls fn with the path and content of every file of a gzipped
+// tarball
+func readTar(r io.Reader, fn func(p string, r io.Reader) error) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
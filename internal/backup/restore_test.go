@@ -0,0 +1,256 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// files of a database named db, by path in a backup
+var files = map[string][]byte{
+	"db/sstable/1-2.sst": []byte("first sstable"),
+	"db/sstable/4-5.sst": []byte("second sstable"),
+	"db/wal/3.new.log":   []byte("{\"measurement\":\"cpu\"}\n"),
+	"db/database.json":   []byte(`{"name":"db"}`),
+}
+
+// pick returns the files at the paths
+func pick(paths ...string) map[string][]byte {
+	picked := make(map[string][]byte)
+	for _, p := range paths {
+		picked[p] = files[p]
+	}
+	return picked
+}
+
+// writeBackup writes a backup of the files at the paths to target, the
+// SSTables in base are only listed
+func writeBackup(t *testing.T, target Target, base *Manifest, paths ...string) *Manifest {
+	t.Helper()
+	src := t.TempDir()
+	m := NewManifest(base)
+	db := Database{Name: "db"}
+	for _, p := range paths {
+		_, kind, name, ok := parsePath(p)
+		if !ok {
+			t.Fatalf("invalid path %s", p)
+		}
+		if base != nil && kind == KindSSTable {
+			if f, ok := base.Lookup(p); ok {
+				f.InBase = true
+				db.Files = append(db.Files, f)
+				continue
+			}
+		}
+		f := openFile(t, src, name, files[p])
+		entry, err := target.Add(p, kind, f, int64(len(files[p])), kind == KindSSTable)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Files = append(db.Files, entry)
+	}
+	m.Databases = append(m.Databases, db)
+	if err := target.Close(m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func dirBackup(t *testing.T, base *Manifest, paths ...string) (string, *Manifest) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "backup")
+	target, err := NewDirTarget(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, writeBackup(t, target, base, paths...)
+}
+
+func tarBackup(t *testing.T, base *Manifest, paths ...string) (string, *Manifest) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "backup.tar.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return path, writeBackup(t, NewTarTarget(f), base, paths...)
+}
+
+// destination restores every database below root
+func destination(root string) Destination {
+	return func(db string) (string, string, string) {
+		dir := filepath.Join(root, db)
+		return filepath.Join(dir, "wal"), filepath.Join(dir, "sstable"), filepath.Join(dir, "database.json")
+	}
+}
+
+// restored returns the files below root, by slash separated path
+func restored(t *testing.T, root string) map[string][]byte {
+	t.Helper()
+	got := make(map[string][]byte)
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		got[filepath.ToSlash(rel)] = data
+		return err
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func checkRestored(t *testing.T, root string, want map[string][]byte) {
+	t.Helper()
+	got := restored(t, root)
+	if len(got) != len(want) {
+		t.Errorf("restored %d files, want %d", len(got), len(want))
+	}
+	for p, data := range want {
+		if !bytes.Equal(got[p], data) {
+			t.Errorf("%s holds %q, want %q", p, got[p], data)
+		}
+	}
+}
+
+func TestRestore(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		backup func(*testing.T, *Manifest, ...string) (string, *Manifest)
+	}{
+		{"dir", dirBackup},
+		{"tar", tarBackup},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			full, m := tt.backup(t, nil, "db/sstable/1-2.sst", "db/wal/3.new.log", "db/database.json")
+			root := t.TempDir()
+			report, err := Restore(RestoreOptions{Sources: []string{full}, Dest: destination(root)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.ID != m.ID || report.Files != 3 || report.FromBase != 0 || len(report.Databases) != 1 {
+				t.Errorf("report is %+v", report)
+			}
+			checkRestored(t, root, pick("db/sstable/1-2.sst", "db/wal/3.new.log", "db/database.json"))
+
+			// the incremental backup doesn't store the sstable of the base
+			incremental, im := tt.backup(t, m, "db/sstable/1-2.sst", "db/sstable/4-5.sst", "db/wal/3.new.log", "db/database.json")
+			if im.Base != m.ID {
+				t.Errorf("incremental backup has base %s, want %s", im.Base, m.ID)
+			}
+			if f, _ := im.Lookup("db/sstable/1-2.sst"); !f.InBase {
+				t.Error("the sstable of the base isn't marked as in the base")
+			}
+
+			root = t.TempDir()
+			report, err = Restore(RestoreOptions{Sources: []string{incremental, full}, Dest: destination(root)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.ID != im.ID || report.Files != 4 || report.FromBase != 1 {
+				t.Errorf("report is %+v", report)
+			}
+			checkRestored(t, root, files)
+
+			// the base is needed
+			_, err = Restore(RestoreOptions{Sources: []string{incremental}, Dest: destination(t.TempDir())})
+			if err == nil || !strings.Contains(err.Error(), "which wasn't given") {
+				t.Errorf("restore without the base: error = %v", err)
+			}
+		})
+	}
+}
+
+func TestRestoreChecksumMismatch(t *testing.T) {
+	dir, _ := dirBackup(t, nil, "db/sstable/1-2.sst", "db/wal/3.new.log")
+	// same size, other content
+	damaged := bytes.ToUpper(files["db/wal/3.new.log"])
+	if err := os.WriteFile(filepath.Join(dir, "db", "wal", "3.new.log"), damaged, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, checkOnly := range []bool{true, false} {
+		root := t.TempDir()
+		_, err := Restore(RestoreOptions{Sources: []string{dir}, Dest: destination(root), CheckOnly: checkOnly})
+		if err == nil || !strings.Contains(err.Error(), "db/wal/3.new.log has checksum") {
+			t.Errorf("check only %v: error = %v, want a checksum mismatch", checkOnly, err)
+		}
+		// nothing is left behind, not even the file that matched
+		if got := restored(t, root); len(got) != 0 {
+			t.Errorf("check only %v: failed restore left %v", checkOnly, got)
+		}
+	}
+
+	// a truncated file is reported by its size
+	if err := os.WriteFile(filepath.Join(dir, "db", "wal", "3.new.log"), damaged[:4], 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Restore(RestoreOptions{Sources: []string{dir}, Dest: destination(t.TempDir())})
+	if err == nil || !strings.Contains(err.Error(), "has 4 bytes") {
+		t.Errorf("error = %v, want a size mismatch", err)
+	}
+}
+
+func TestRestoreMissingManifest(t *testing.T) {
+	dir, _ := dirBackup(t, nil, "db/sstable/1-2.sst")
+	if err := os.Remove(filepath.Join(dir, ManifestFile)); err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	_, err := Restore(RestoreOptions{Sources: []string{dir}, Dest: destination(root)})
+	if !errors.Is(err, ErrIncomplete) {
+		t.Errorf("error = %v, want %v", err, ErrIncomplete)
+	}
+	if got := restored(t, root); len(got) != 0 {
+		t.Errorf("failed restore left %v", got)
+	}
+	if _, err := ReadManifest(dir); !errors.Is(err, ErrIncomplete) {
+		t.Errorf("ReadManifest error = %v, want %v", err, ErrIncomplete)
+	}
+}
+
+func TestRestoreExistingData(t *testing.T) {
+	dir, _ := dirBackup(t, nil, "db/sstable/1-2.sst", "db/wal/3.new.log")
+	root := t.TempDir()
+	old := filepath.Join(root, "db", "sstable", "9-9.sst")
+	if err := os.MkdirAll(filepath.Dir(old), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(old, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Restore(RestoreOptions{Sources: []string{dir}, Dest: destination(root)})
+	if err == nil || !strings.Contains(err.Error(), "already has data") {
+		t.Errorf("error = %v, want existing data", err)
+	}
+	checkRestored(t, root, map[string][]byte{"db/sstable/9-9.sst": []byte("old")})
+
+	if _, err := Restore(RestoreOptions{Sources: []string{dir}, Dest: destination(root), Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	checkRestored(t, root, pick("db/sstable/1-2.sst", "db/wal/3.new.log"))
+}
+
+func TestParsePath(t *testing.T) {
+	for _, p := range []string{"db/sstable/1.sst", "db/wal/1.log", "db/database.json"} {
+		if _, _, _, ok := parsePath(p); !ok {
+			t.Errorf("parsePath(%q) failed", p)
+		}
+	}
+	for _, p := range []string{"../db/sstable/1.sst", "db/sstable/../../x", "/db/wal/1.log", "db/other/1", "db\\wal\\1.log", "db/sstable/", "db"} {
+		if _, _, _, ok := parsePath(p); ok {
+			t.Errorf("parsePath(%q) succeeded", p)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Target is where a backup is written to.
+type Target interface {
+	// Add stores the first size bytes of f at path and returns the file
+	// entry for the manifest. Immutable files may be hard linked.
+	Add(path, kind string, f *os.File, size int64, immutable bool) (File, error)
+	// Close writes the manifest and completes the backup.
+	Close(m *Manifest) error
+	// Abort removes what was written of an incomplete backup, if it can.
+	Abort()
+}
+
+// dirTarget writes a backup as a directory tree
+type dirTarget struct {
+	dir string
+}
+
+// NewDirTarget writes a backup into dir, which must not exist yet or be
+// empty. SSTables are hard linked when dir is on the same file system,
+// copied otherwise.
+func NewDirTarget(dir string) (Target, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("couldn't read backup directory : %w", err)
+	}
+	if len(entries) > 0 {
+		return nil, fmt.Errorf("backup directory %s is not empty", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("couldn't create backup directory : %w", err)
+	}
+	return &dirTarget{dir: dir}, nil
+}
+
+func (t *dirTarget) Add(path, kind string, f *os.File, size int64, immutable bool) (File, error) {
+	entry := File{Path: path, Kind: kind, Bytes: size}
+	dst := filepath.Join(t.dir, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return entry, fmt.Errorf("couldn't create backup directory : %w", err)
+	}
+
+	if immutable {
+		if err := os.Link(f.Name(), dst); err == nil {
+			sum, n, err := checksumFile(dst)
+			if err != nil {
+				return entry, err
+			}
+			if n == size {
+				entry.SHA256 = sum
+				return entry, nil
+			}
+			// replaced since it was opened, copy from the open file
+			os.Remove(dst)
+		}
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return entry, fmt.Errorf("couldn't create %s : %w", path, err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, h), io.NewSectionReader(f, 0, size)); err != nil {
+		out.Close()
+		return entry, fmt.Errorf("couldn't copy %s : %w", path, err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return entry, fmt.Errorf("couldn't sync %s : %w", path, err)
+	}
+	if err := out.Close(); err != nil {
+		return entry, fmt.Errorf("couldn't close %s : %w", path, err)
+	}
+	entry.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return entry, nil
+}
+
+func (t *dirTarget) Close(m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(t.dir, ManifestFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("couldn't write the manifest : %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func (t *dirTarget) Abort() {
+	os.RemoveAll(t.dir)
+}
+
+// tarTarget writes a backup as a gzipped tarball
+type tarTarget struct {
+	gz  *gzip.Writer
+	tw  *tar.Writer
+	now time.Time
+}
+
+// NewTarTarget writes a backup to w as a gzipped tarball. The manifest is
+// its last entry.
+func NewTarTarget(w io.Writer) Target {
+	gz := gzip.NewWriter(w)
+	return &tarTarget{gz: gz, tw: tar.NewWriter(gz), now: time.Now()}
+}
+
+func (t *tarTarget) Add(path, kind string, f *os.File, size int64, immutable bool) (File, error) {
+	entry := File{Path: path, Kind: kind, Bytes: size}
+	if err := t.tw.WriteHeader(&tar.Header{Name: path, Mode: 0644, Size: size, ModTime: t.now, Typeflag: tar.TypeReg}); err != nil {
+		return entry, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(t.tw, h), io.NewSectionReader(f, 0, size)); err != nil {
+		return entry, fmt.Errorf("couldn't copy %s : %w", path, err)
+	}
+	entry.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return entry, nil
+}
+
+func (t *tarTarget) Close(m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := t.tw.WriteHeader(&tar.Header{Name: ManifestFile, Mode: 0644, Size: int64(len(data)), ModTime: t.now, Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	if _, err := t.tw.Write(data); err != nil {
+		return err
+	}
+	if err := t.tw.Close(); err != nil {
+		return err
+	}
+	return t.gz.Close()
+}
+
+// Abort leaves the tarball without a manifest, restore refuses it
+func (t *tarTarget) Abort() {}
+
+// checksumFile returns the hex sha256 and size of the file at path
+func checksumFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, fmt.Errorf("couldn't read %s : %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
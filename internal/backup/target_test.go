@@ -0,0 +1,168 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// openFile writes data to a new file named name in dir and opens it
+func openFile(t *testing.T, dir, name string, data []byte) *os.File {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// sameFile reports whether the files at a and b are hard links of each other
+func sameFile(t *testing.T, a, b string) bool {
+	t.Helper()
+	ai, err := os.Stat(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return os.SameFile(ai, bi)
+}
+
+// checkAdded checks the file added at p holds data and is listed as such
+func checkAdded(t *testing.T, dir, p string, entry File, data []byte) {
+	t.Helper()
+	want := File{Path: p, Kind: KindSSTable, Bytes: int64(len(data)), SHA256: checksum(data)}
+	if entry != want {
+		t.Errorf("entry is %+v, want %+v", entry, want)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(p)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("%s holds %q, want %q", p, got, data)
+	}
+}
+
+func TestDirTargetLink(t *testing.T) {
+	src, dir := t.TempDir(), filepath.Join(t.TempDir(), "backup")
+	target, err := NewDirTarget(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("sstable content")
+
+	// immutable files are hard linked
+	f := openFile(t, src, "1.sst", data)
+	entry, err := target.Add("db/sstable/1.sst", KindSSTable, f, int64(len(data)), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAdded(t, dir, "db/sstable/1.sst", entry, data)
+	if !sameFile(t, f.Name(), filepath.Join(dir, "db", "sstable", "1.sst")) {
+		t.Error("the sstable was copied instead of linked")
+	}
+
+	// other files are copied
+	f = openFile(t, src, "2.sst", data)
+	entry, err = target.Add("db/sstable/2.sst", KindSSTable, f, int64(len(data)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAdded(t, dir, "db/sstable/2.sst", entry, data)
+	if sameFile(t, f.Name(), filepath.Join(dir, "db", "sstable", "2.sst")) {
+		t.Error("a mutable file was linked")
+	}
+}
+
+func TestDirTargetLinkReplaced(t *testing.T) {
+	src, dir := t.TempDir(), filepath.Join(t.TempDir(), "backup")
+	target, err := NewDirTarget(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("sstable content")
+
+	// a file replaced since it was opened would link the new content, the
+	// size differs so the open file is copied
+	f := openFile(t, src, "1.sst", data)
+	if err := os.WriteFile(filepath.Join(src, "1.sst.tmp"), []byte("a longer replacement"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(src, "1.sst.tmp"), f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := target.Add("db/sstable/1.sst", KindSSTable, f, int64(len(data)), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAdded(t, dir, "db/sstable/1.sst", entry, data)
+	if sameFile(t, f.Name(), filepath.Join(dir, "db", "sstable", "1.sst")) {
+		t.Error("the replacement was linked")
+	}
+
+	// a removed file can't be linked, the open file is copied
+	f = openFile(t, src, "2.sst", data)
+	if err := os.Remove(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	entry, err = target.Add("db/sstable/2.sst", KindSSTable, f, int64(len(data)), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAdded(t, dir, "db/sstable/2.sst", entry, data)
+}
+
+func TestDirTargetPartialFile(t *testing.T) {
+	src, dir := t.TempDir(), filepath.Join(t.TempDir(), "backup")
+	target, err := NewDirTarget(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the first size bytes of a growing file are kept, whether it may
+	// be linked or not
+	data := []byte("record 1\nrecord 2\n")
+	for i, immutable := range []bool{true, false} {
+		f := openFile(t, src, "1.log", data)
+		p := []string{"db/sstable/a", "db/sstable/b"}[i]
+		entry, err := target.Add(p, KindSSTable, f, 9, immutable)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkAdded(t, dir, p, entry, data[:9])
+	}
+}
+
+func TestNewDirTarget(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewDirTarget(dir); err == nil {
+		t.Error("a backup was started in a directory that isn't empty")
+	}
+
+	target, err := NewDirTarget(filepath.Join(dir, "backup"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target.Abort()
+	if _, err := os.Stat(filepath.Join(dir, "backup")); !os.IsNotExist(err) {
+		t.Errorf("aborted backup wasn't removed: %v", err)
+	}
+}
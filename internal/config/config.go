@@ -0,0 +1,341 @@
+// Package config loads the server configuration. Values are resolved in this
+// order, later ones win: built-in defaults, the YAML config file, TICKDB_*
+// environment variables and command line flags.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heyyakash/tickdb/internal/logging"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	GRPCAddr       string `yaml:"grpc_addr"`
+	IngestHTTPAddr string `yaml:"ingest_http_addr"`
+	QueryHTTPAddr  string `yaml:"query_http_addr"`
+
+	DataDir    string `yaml:"data_dir"`
+	WALDir     string `yaml:"wal_dir"`
+	SSTableDir string `yaml:"sstable_dir"`
+
+	// number of points in the memtable that triggers a flush to an SSTable
+	FlushThreshold int `yaml:"flush_threshold"`
+	// capacity of the ingest pipeline channel
+	QueueSize int `yaml:"queue_size"`
+	// how long data is kept, 0 keeps it forever
+	Retention time.Duration `yaml:"retention"`
+	// how far in the future a point timestamp may be
+	MaxFuture time.Duration `yaml:"max_future"`
+
+	// write limits of the default database and of new databases that don't
+	// set their own, 0 disables a limit
+	RateLimitPoints         int   `yaml:"rate_limit_points"`
+	RateLimitBytes          int   `yaml:"rate_limit_bytes"`
+	MaxSeries               int   `yaml:"max_series"`
+	MaxSeriesPerMeasurement int   `yaml:"max_series_per_measurement"`
+	MaxStoredBytes          int64 `yaml:"max_stored_bytes"`
+
+	// most points or rows a single query may return, 0 is unlimited
+	MaxPointsPerQuery int `yaml:"max_points_per_query"`
+
+	// how long a graceful shutdown may take before giving up
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// flush the memtable to an SSTable on shutdown instead of leaving it in
+	// the WAL for replay
+	FlushOnShutdown bool `yaml:"flush_on_shutdown"`
+
+	// directory POST /backup may write backups under, server side backups
+	// are refused when empty
+	BackupDir string `yaml:"backup_dir"`
+
+	// certificate and key for TLS on every listener, plaintext when unset
+	TLSCertFile string `yaml:"tls_cert_file"`
+	TLSKeyFile  string `yaml:"tls_key_file"`
+	// CA bundle client certificates are checked against, enables mTLS
+	TLSClientCAFile string `yaml:"tls_client_ca_file"`
+
+	// require API tokens on every listener
+	AuthEnabled bool `yaml:"auth_enabled"`
+	// file holding the hashed API tokens
+	TokenFile string `yaml:"token_file"`
+
+	// debug, info, warn or error
+	LogLevel string `yaml:"log_level"`
+	// text or json
+	LogFormat string `yaml:"log_format"`
+}
+
+func Default() *Config {
+	return &Config{
+		GRPCAddr:       ":50051",
+		IngestHTTPAddr: ":8020",
+		QueryHTTPAddr:  ":8021",
+		DataDir:        ".",
+		FlushThreshold: 200,
+		QueueSize:      100,
+		Retention:      0,
+		MaxFuture:      time.Hour,
+
+		MaxPointsPerQuery: 1000000,
+
+		ShutdownTimeout: time.Minute,
+		FlushOnShutdown: false,
+
+		LogLevel:  "info",
+		LogFormat: logging.FormatText,
+	}
+}
+
+// setting describes one configuration value with its flag and env var names
+type setting struct {
+	key   string
+	flag  string
+	env   string
+	usage string
+	value flag.Value
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"grpc_addr", "grpc-addr", "TICKDB_GRPC_ADDR", "listen address of the grpc server", (*stringValue)(&c.GRPCAddr)},
+		{"ingest_http_addr", "ingest-addr", "TICKDB_INGEST_HTTP_ADDR", "listen address of the ingest rest server", (*stringValue)(&c.IngestHTTPAddr)},
+		{"query_http_addr", "query-addr", "TICKDB_QUERY_HTTP_ADDR", "listen address of the query rest server", (*stringValue)(&c.QueryHTTPAddr)},
+		{"data_dir", "data-dir", "TICKDB_DATA_DIR", "base directory for data files", (*stringValue)(&c.DataDir)},
+		{"wal_dir", "wal-dir", "TICKDB_WAL_DIR", "directory for WAL files (default <data-dir>/wal)", (*stringValue)(&c.WALDir)},
+		{"sstable_dir", "sstable-dir", "TICKDB_SSTABLE_DIR", "directory for SSTables (default <data-dir>/sstable)", (*stringValue)(&c.SSTableDir)},
+		{"flush_threshold", "flush-threshold", "TICKDB_FLUSH_THRESHOLD", "memtable points that trigger a flush", (*intValue)(&c.FlushThreshold)},
+		{"queue_size", "queue-size", "TICKDB_QUEUE_SIZE", "capacity of the ingest pipeline", (*intValue)(&c.QueueSize)},
+		{"retention", "retention", "TICKDB_RETENTION", "how long data is kept, 0 keeps it forever", (*durationValue)(&c.Retention)},
+		{"max_future", "max-future", "TICKDB_MAX_FUTURE", "how far in the future a point timestamp may be", (*durationValue)(&c.MaxFuture)},
+		{"rate_limit_points", "rate-limit-points", "TICKDB_RATE_LIMIT_POINTS", "points per second a database accepts, 0 is unlimited", (*intValue)(&c.RateLimitPoints)},
+		{"rate_limit_bytes", "rate-limit-bytes", "TICKDB_RATE_LIMIT_BYTES", "bytes per second a database accepts, 0 is unlimited", (*intValue)(&c.RateLimitBytes)},
+		{"max_series", "max-series", "TICKDB_MAX_SERIES", "series a database may hold, 0 is unlimited", (*intValue)(&c.MaxSeries)},
+		{"max_series_per_measurement", "max-series-per-measurement", "TICKDB_MAX_SERIES_PER_MEASUREMENT", "series each measurement of a database may hold, 0 is unlimited", (*intValue)(&c.MaxSeriesPerMeasurement)},
+		{"max_stored_bytes", "max-stored-bytes", "TICKDB_MAX_STORED_BYTES", "SSTable bytes a database may hold, 0 is unlimited", (*int64Value)(&c.MaxStoredBytes)},
+		{"max_points_per_query", "max-points-per-query", "TICKDB_MAX_POINTS_PER_QUERY", "points or rows a single query may return, 0 is unlimited", (*intValue)(&c.MaxPointsPerQuery)},
+		{"shutdown_timeout", "shutdown-timeout", "TICKDB_SHUTDOWN_TIMEOUT", "how long a graceful shutdown may take", (*durationValue)(&c.ShutdownTimeout)},
+		{"flush_on_shutdown", "flush-on-shutdown", "TICKDB_FLUSH_ON_SHUTDOWN", "flush the memtable to an SSTable on shutdown", (*boolValue)(&c.FlushOnShutdown)},
+		{"backup_dir", "backup-dir", "TICKDB_BACKUP_DIR", "directory server side backups are written under, refused when empty", (*stringValue)(&c.BackupDir)},
+		{"tls_cert_file", "tls-cert", "TICKDB_TLS_CERT_FILE", "TLS certificate for every listener", (*stringValue)(&c.TLSCertFile)},
+		{"tls_key_file", "tls-key", "TICKDB_TLS_KEY_FILE", "TLS private key for every listener", (*stringValue)(&c.TLSKeyFile)},
+		{"tls_client_ca_file", "tls-client-ca", "TICKDB_TLS_CLIENT_CA_FILE", "CA bundle for verifying client certificates (mTLS)", (*stringValue)(&c.TLSClientCAFile)},
+		{"auth_enabled", "auth", "TICKDB_AUTH_ENABLED", "require API tokens on every listener", (*boolValue)(&c.AuthEnabled)},
+		{"token_file", "token-file", "TICKDB_TOKEN_FILE", "file holding the hashed API tokens (default <data-dir>/tokens.json)", (*stringValue)(&c.TokenFile)},
+		{"log_level", "log-level", "TICKDB_LOG_LEVEL", "log level: debug, info, warn or error", (*stringValue)(&c.LogLevel)},
+		{"log_format", "log-format", "TICKDB_LOG_FORMAT", "log output format: text or json", (*stringValue)(&c.LogFormat)},
+	}
+}
+
+// Load builds the configuration from the command line arguments (without the
+// program name), the environment and the config file given by -config or
+// TICKDB_CONFIG.
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("tickdb", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("TICKDB_CONFIG"), "path to a YAML config file")
+
+	// flags are parsed into a scratch config first so only the flags that
+	// were actually set override the file and environment
+	flagCfg := Default()
+	flagSettings := flagCfg.settings()
+	for _, s := range flagSettings {
+		fs.Var(s.value, s.flag, s.usage+" (env "+s.env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configPath != "" {
+		f, err := os.Open(*configPath)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read config file : %w", err)
+		}
+		defer f.Close()
+		dec := yaml.NewDecoder(f)
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("couldn't parse config file %s : %w", *configPath, err)
+		}
+	}
+
+	settings := cfg.settings()
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(s.env); ok {
+			if err := s.value.Set(v); err != nil {
+				return nil, fmt.Errorf("invalid value for %s : %w", s.env, err)
+			}
+		}
+	}
+
+	setFlags := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+	for i, s := range flagSettings {
+		if setFlags[s.flag] {
+			settings[i].value.Set(s.value.String())
+		}
+	}
+
+	if cfg.WALDir == "" {
+		cfg.WALDir = filepath.Join(cfg.DataDir, "wal")
+	}
+	if cfg.SSTableDir == "" {
+		cfg.SSTableDir = filepath.Join(cfg.DataDir, "sstable")
+	}
+	if cfg.TokenFile == "" {
+		cfg.TokenFile = filepath.Join(cfg.DataDir, "tokens.json")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks the configuration and makes sure the data directories exist.
+func (c *Config) Validate() error {
+	addrs := map[string]string{
+		"grpc_addr":        c.GRPCAddr,
+		"ingest_http_addr": c.IngestHTTPAddr,
+		"query_http_addr":  c.QueryHTTPAddr,
+	}
+	seen := make(map[string]string)
+	for name, addr := range addrs {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid %s %q : %w", name, addr, err)
+		}
+		if other, ok := seen[addr]; ok {
+			return fmt.Errorf("%s and %s both listen on %q", name, other, addr)
+		}
+		seen[addr] = name
+	}
+
+	if c.FlushThreshold <= 0 {
+		return fmt.Errorf("flush_threshold must be positive, got %d", c.FlushThreshold)
+	}
+	if c.QueueSize <= 0 {
+		return fmt.Errorf("queue_size must be positive, got %d", c.QueueSize)
+	}
+	if c.Retention < 0 {
+		return fmt.Errorf("retention must not be negative, got %s", c.Retention)
+	}
+	if c.MaxFuture < 0 {
+		return fmt.Errorf("max_future must not be negative, got %s", c.MaxFuture)
+	}
+	for name, v := range map[string]int64{"rate_limit_points": int64(c.RateLimitPoints), "rate_limit_bytes": int64(c.RateLimitBytes), "max_series": int64(c.MaxSeries), "max_series_per_measurement": int64(c.MaxSeriesPerMeasurement), "max_stored_bytes": c.MaxStoredBytes, "max_points_per_query": int64(c.MaxPointsPerQuery)} {
+		if v < 0 {
+			return fmt.Errorf("%s must not be negative, got %d", name, v)
+		}
+	}
+	if c.ShutdownTimeout <= 0 {
+		return fmt.Errorf("shutdown_timeout must be positive, got %s", c.ShutdownTimeout)
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return errors.New("tls_cert_file and tls_key_file must be set together")
+	}
+	if c.TLSClientCAFile != "" && c.TLSCertFile == "" {
+		return errors.New("tls_client_ca_file needs tls_cert_file and tls_key_file")
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		return err
+	}
+	if c.LogFormat != logging.FormatText && c.LogFormat != logging.FormatJSON {
+		return fmt.Errorf("log_format must be %s or %s, got %q", logging.FormatText, logging.FormatJSON, c.LogFormat)
+	}
+
+	for name, dir := range map[string]string{"data_dir": c.DataDir, "wal_dir": c.WALDir, "sstable_dir": c.SSTableDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("couldn't create %s %q : %w", name, dir, err)
+		}
+	}
+	return nil
+}
+
+// TLSEnabled reports whether the listeners serve TLS.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != ""
+}
+
+// String renders the effective configuration in the config file format.
+func (c *Config) String() string {
+	var b strings.Builder
+	for _, s := range c.settings() {
+		fmt.Fprintf(&b, "%s: %s\n", s.key, s.value.String())
+	}
+	return b.String()
+}
+
+// LogValue logs the effective configuration as a group of settings.
+func (c *Config) LogValue() slog.Value {
+	settings := c.settings()
+	attrs := make([]slog.Attr, 0, len(settings))
+	for _, s := range settings {
+		attrs = append(attrs, slog.String(s.key, s.value.String()))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+type stringValue string
+
+func (s *stringValue) Set(v string) error { *s = stringValue(v); return nil }
+func (s *stringValue) String() string     { return string(*s) }
+
+type intValue int
+
+func (i *intValue) Set(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	*i = intValue(n)
+	return nil
+}
+func (i *intValue) String() string { return strconv.Itoa(int(*i)) }
+
+type int64Value int64
+
+func (i *int64Value) Set(v string) error {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = int64Value(n)
+	return nil
+}
+func (i *int64Value) String() string { return strconv.FormatInt(int64(*i), 10) }
+
+type durationValue time.Duration
+
+func (d *durationValue) Set(v string) error {
+	n, err := time.ParseDuration(v)
+	if err != nil {
+		return err
+	}
+	*d = durationValue(n)
+	return nil
+}
+func (d *durationValue) String() string { return time.Duration(*d).String() }
+
+type boolValue bool
+
+func (b *boolValue) Set(v string) error {
+	n, err := strconv.ParseBool(v)
+	if err != nil {
+		return err
+	}
+	*b = boolValue(n)
+	return nil
+}
+func (b *boolValue) String() string   { return strconv.FormatBool(bool(*b)) }
+func (b *boolValue) IsBoolFlag() bool { return true }
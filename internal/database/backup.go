@@ -0,0 +1,182 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/heyyakash/tickdb/internal/backup"
+)
+
+// snapshotFile is a file of a database opened while its pipeline was frozen
+type snapshotFile struct {
+	kind string
+	name string
+	f    *os.File
+	size int64
+}
+
+// snapshot opens the SSTables, the active WAL segment and the settings of db
+// while its pipeline is frozen. The open files keep their content if they
+// are removed afterwards, the WAL segment is only read up to the returned
+// size.
+func (db *Database) snapshot() ([]snapshotFile, error) {
+	var files []snapshotFile
+	closeAll := func() {
+		for _, file := range files {
+			file.f.Close()
+		}
+	}
+	open := func(kind, path string) error {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		files = append(files, snapshotFile{kind: kind, name: filepath.Base(path), f: f, size: info.Size()})
+		return nil
+	}
+
+	err := db.Pipeline.Freeze(func() error {
+		tables, err := db.SSTables.ListTables()
+		if err != nil {
+			return err
+		}
+		for _, path := range tables {
+			if err := open(backup.KindSSTable, path); err != nil {
+				return fmt.Errorf("couldn't open %s : %w", filepath.Base(path), err)
+			}
+		}
+		f, size, err := db.WAL.Tail()
+		if err != nil {
+			return err
+		}
+		files = append(files, snapshotFile{kind: backup.KindWAL, name: filepath.Base(f.Name()), f: f, size: size})
+		return nil
+	})
+	if err == nil && db.dir != "" {
+		if err = open(backup.KindMeta, filepath.Join(db.dir, metaFile)); err != nil {
+			err = fmt.Errorf("couldn't open %s : %w", metaFile, err)
+		}
+	}
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	return files, nil
+}
+
+// Backup writes a consistent snapshot of the databases called names, or of
+// every database when names is empty, to t. With a base manifest SSTables
+// already in the base backup are only listed. On error the incomplete backup
+// is aborted.
+func (c *Catalog) Backup(names []string, base *backup.Manifest, t backup.Target) (*backup.Manifest, error) {
+	dbs := c.List()
+	if len(names) > 0 {
+		dbs = nil
+		seen := make(map[string]bool)
+		for _, name := range names {
+			db, err := c.Get(name)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[name] {
+				seen[name] = true
+				dbs = append(dbs, db)
+			}
+		}
+	}
+
+	inBase := make(map[string]backup.File)
+	if base != nil {
+		for _, db := range base.Databases {
+			for _, f := range db.Files {
+				if f.Kind == backup.KindSSTable {
+					inBase[f.Path] = f
+				}
+			}
+		}
+	}
+
+	m := backup.NewManifest(base)
+	for _, db := range dbs {
+		entry, err := c.backupDatabase(db, inBase, t)
+		if err != nil {
+			t.Abort()
+			return nil, fmt.Errorf("couldn't back up database %s : %w", db.Name, err)
+		}
+		m.Databases = append(m.Databases, entry)
+	}
+	if err := t.Close(m); err != nil {
+		t.Abort()
+		return nil, fmt.Errorf("couldn't complete the backup : %w", err)
+	}
+	c.logger.Info("Backup written", "id", m.ID, "base", m.Base, "databases", len(m.Databases))
+	return m, nil
+}
+
+func (c *Catalog) backupDatabase(db *Database, inBase map[string]backup.File, t backup.Target) (backup.Database, error) {
+	entry := backup.Database{Name: db.Name}
+	files, err := db.snapshot()
+	if err != nil {
+		return entry, err
+	}
+	defer func() {
+		for _, file := range files {
+			file.f.Close()
+		}
+	}()
+
+	for _, file := range files {
+		path := backup.FilePath(db.Name, file.kind, file.name)
+		// SSTables are never changed once written, the same name and size
+		// is the same table
+		if prev, ok := inBase[path]; ok && prev.Bytes == file.size {
+			prev.InBase = true
+			entry.Files = append(entry.Files, prev)
+			continue
+		}
+		f, err := t.Add(path, file.kind, file.f, file.size, file.kind == backup.KindSSTable)
+		if err != nil {
+			return entry, err
+		}
+		entry.Files = append(entry.Files, f)
+	}
+	return entry, nil
+}
+
+// Dirs returns where the WAL, the SSTables and the settings of the database
+// called name are stored. The default database has no settings file.
+func Dirs(opts Options, name string) (walDir, sstableDir, meta string) {
+	if name == DefaultName {
+		return opts.WALDir, opts.SSTableDir, ""
+	}
+	dir := filepath.Join(opts.DataDir, "databases", name)
+	return filepath.Join(dir, "wal"), filepath.Join(dir, "sstable"), filepath.Join(dir, metaFile)
+}
+
+// Names lists the default database and the databases created at runtime in
+// the data directory, for tools that work on the files of a stopped server.
+func Names(opts Options) ([]string, error) {
+	names := []string{DefaultName}
+	root := filepath.Join(opts.DataDir, "databases")
+	entries, err := os.ReadDir(root)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		// left behind by a drop that didn't finish
+		if _, err := os.Stat(filepath.Join(root, entry.Name(), metaFile)); err != nil {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
@@ -0,0 +1,209 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/heyyakash/tickdb/internal/backup"
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// openCatalog opens a catalog with its data in dir and replays it
+func openCatalog(t *testing.T, dir string) *Catalog {
+	t.Helper()
+	opts := Options{
+		DataDir:        dir,
+		WALDir:         filepath.Join(dir, "wal"),
+		SSTableDir:     filepath.Join(dir, "sstable"),
+		QueueSize:      100,
+		FlushThreshold: 1000,
+	}
+	c, err := Open(opts, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Shutdown(context.Background(), false) })
+	if err := c.Replay(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func point(measurement string) *ingestpb.Point {
+	return &ingestpb.Point{Measurement: measurement, TimestampUnixNano: time.Now().UnixNano(), Fields: map[string]string{"v": "1"}}
+}
+
+// writeTable writes an SSTable holding a series of measurement to db
+func writeTable(t *testing.T, db *Database, measurement string) string {
+	t.Helper()
+	p := point(measurement)
+	path, err := db.SSTables.WriteTable(map[string][]*ingestpb.Point{measurement: {p}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeWAL queues a point of measurement and waits until it is in the WAL
+func writeWAL(t *testing.T, db *Database, measurement string) {
+	t.Helper()
+	n, _ := db.MemTable.Stats()
+	if err := db.Pipeline.AddDataPoint(point(measurement)); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if points, _ := db.MemTable.Stats(); points > n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the point wasn't written")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// files returns the files of a manifest by path
+func files(m *backup.Manifest) map[string]backup.File {
+	byPath := make(map[string]backup.File)
+	for _, db := range m.Databases {
+		for _, f := range db.Files {
+			byPath[f.Path] = f
+		}
+	}
+	return byPath
+}
+
+func sameFile(t *testing.T, a, b string) bool {
+	t.Helper()
+	ai, err := os.Stat(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return os.SameFile(ai, bi)
+}
+
+func TestBackupRestore(t *testing.T) {
+	c := openCatalog(t, t.TempDir())
+	db, err := c.Create("metrics", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, err := c.Get(DefaultName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := writeTable(t, db, "cpu")
+	writeTable(t, def, "disk")
+	writeWAL(t, db, "mem")
+
+	// SSTables are hard linked into a backup directory on the same file
+	// system, the WAL segment is copied
+	dir := filepath.Join(t.TempDir(), "full")
+	target, err := backup.NewDirTarget(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := c.Backup([]string{"metrics"}, nil, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(full.Databases) != 1 || full.Databases[0].Name != "metrics" {
+		t.Fatalf("backup has databases %+v, want metrics", full.Databases)
+	}
+	var kinds []string
+	for p, f := range files(full) {
+		kinds = append(kinds, f.Kind)
+		if f.Kind == backup.KindSSTable && !sameFile(t, filepath.Join(dir, filepath.FromSlash(p)), first) {
+			t.Errorf("%s wasn't linked", p)
+		}
+	}
+	sort.Strings(kinds)
+	if want := []string{backup.KindMeta, backup.KindSSTable, backup.KindWAL}; !reflect.DeepEqual(kinds, want) {
+		t.Errorf("backup has files of kinds %v, want %v", kinds, want)
+	}
+
+	// the incremental backup only stores what changed since
+	second := writeTable(t, db, "net")
+	writeWAL(t, db, "swap")
+	tarball := filepath.Join(t.TempDir(), "incremental.tar.gz")
+	f, err := os.Create(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	incremental, err := c.Backup([]string{"metrics"}, full, backup.NewTarTarget(f))
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if incremental.Base != full.ID {
+		t.Errorf("incremental backup has base %s, want %s", incremental.Base, full.ID)
+	}
+	for p, f := range files(incremental) {
+		if want := filepath.Base(p) == filepath.Base(first); f.InBase != want {
+			t.Errorf("%s is in the base = %v, want %v", p, f.InBase, want)
+		}
+	}
+
+	// the restored files are the ones of the database
+	restoreDir := t.TempDir()
+	report, err := backup.Restore(backup.RestoreOptions{
+		Sources: []string{tarball, dir},
+		Dest: func(name string) (string, string, string) {
+			return Dirs(Options{DataDir: restoreDir, WALDir: filepath.Join(restoreDir, "wal"), SSTableDir: filepath.Join(restoreDir, "sstable")}, name)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Files != 4 || report.FromBase != 1 {
+		t.Errorf("restore report is %+v", report)
+	}
+	for _, path := range []string{first, second} {
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(filepath.Join(restoreDir, "databases", "metrics", "sstable", filepath.Base(path)))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("restored %s differs: %v", filepath.Base(path), err)
+		}
+	}
+
+	// and open as the database that was backed up
+	restored := openCatalog(t, restoreDir)
+	rdb, err := restored.Get("metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := rdb.SSTables.SeriesKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	if want := []string{"cpu", "net"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("restored sstables hold series %v, want [cpu net]", keys)
+	}
+	if n, _ := rdb.MemTable.Stats(); n != 2 {
+		t.Errorf("restored WAL holds %d points, want 2", n)
+	}
+	rdef, err := restored.Get(DefaultName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := rdef.SSTables.SeriesKeys(); len(keys) != 0 {
+		t.Errorf("the default database wasn't backed up, but has series %v", keys)
+	}
+}
@@ -0,0 +1,306 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/heyyakash/tickdb/internal/health"
+	ingestpipeline "github.com/heyyakash/tickdb/internal/ingest-pipeline"
+	"github.com/heyyakash/tickdb/internal/limits"
+)
+
+// Options configures the databases of a Catalog.
+type Options struct {
+	// databases other than the default one live in <DataDir>/databases/<name>
+	DataDir string
+	// storage and retention of the default database
+	WALDir     string
+	SSTableDir string
+	Retention  time.Duration
+	// limits of the default database, and of new databases that don't set
+	// their own
+	Limits limits.Limits
+	// rate limiters of the API tokens
+	Tokens *limits.Registry
+
+	QueueSize      int
+	FlushThreshold int
+	MaxFuture      time.Duration
+	// most points or rows a query may return, 0 is unlimited
+	MaxPointsPerQuery int
+	// optional, storage failures are reported to it
+	Health *health.Status
+}
+
+// Catalog holds the open databases.
+type Catalog struct {
+	opts   Options
+	logger *slog.Logger
+
+	mu  sync.RWMutex
+	dbs map[string]*Database
+
+	// serializes Create and Drop, so a name isn't reused while its directory
+	// is being removed
+	adminMu sync.Mutex
+}
+
+// Open opens the default database and every database created before.
+func Open(opts Options, logger *slog.Logger) (*Catalog, error) {
+	c := &Catalog{
+		opts:   opts,
+		logger: logger,
+		dbs:    make(map[string]*Database),
+	}
+
+	db, err := open(DefaultName, opts.WALDir, opts.SSTableDir, opts.Retention, opts.Limits, opts, logger)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open database %s : %w", DefaultName, err)
+	}
+	c.dbs[DefaultName] = db
+
+	entries, err := os.ReadDir(c.root())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(c.root(), entry.Name())
+		md, retention, err := readMeta(dir)
+		if os.IsNotExist(err) {
+			// left behind by a drop that didn't finish
+			logger.Warn("Skipping database directory without settings", "dir", dir)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("couldn't open database %s : %w", entry.Name(), err)
+		}
+		l := c.opts.Limits
+		if md.Limits != nil {
+			l = *md.Limits
+		}
+		db, err := c.openDir(md.Name, dir, retention, l)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't open database %s : %w", md.Name, err)
+		}
+		db.CreatedAt = md.CreatedAt
+		c.dbs[db.Name] = db
+	}
+	return c, nil
+}
+
+// root is the directory holding the databases created at runtime
+func (c *Catalog) root() string {
+	return filepath.Join(c.opts.DataDir, "databases")
+}
+
+func (c *Catalog) openDir(name, dir string, retention time.Duration, l limits.Limits) (*Database, error) {
+	db, err := open(name, filepath.Join(dir, "wal"), filepath.Join(dir, "sstable"), retention, l, c.opts, c.logger.With("database", name))
+	if err != nil {
+		return nil, err
+	}
+	db.dir = dir
+	return db, nil
+}
+
+// Get returns the database called name.
+func (c *Catalog) Get(name string) (*Database, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	db, ok := c.dbs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w : %s", ErrNotFound, name)
+	}
+	return db, nil
+}
+
+// Resolve returns the database a request is for: name when it's set, else the
+// database selected by ctx, else the default database.
+func (c *Catalog) Resolve(ctx context.Context, name string) (*Database, error) {
+	if name == "" {
+		name = NameFromContext(ctx)
+	}
+	if name == "" {
+		name = DefaultName
+	}
+	return c.Get(name)
+}
+
+// List returns the open databases sorted by name.
+func (c *Catalog) List() []*Database {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	dbs := make([]*Database, 0, len(c.dbs))
+	for _, db := range c.dbs {
+		dbs = append(dbs, db)
+	}
+	sort.Slice(dbs, func(i, j int) bool { return dbs[i].Name < dbs[j].Name })
+	return dbs
+}
+
+// Create makes a new empty database that is ready for writes. A retention of
+// 0 keeps its data forever, nil limits use the configured defaults.
+func (c *Catalog) Create(name string, retention time.Duration, l *limits.Limits) (*Database, error) {
+	if err := ValidName(name); err != nil {
+		return nil, err
+	}
+	if retention < 0 {
+		return nil, fmt.Errorf("retention must not be negative")
+	}
+	if l == nil {
+		l = &c.opts.Limits
+	}
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+
+	c.adminMu.Lock()
+	defer c.adminMu.Unlock()
+
+	if _, err := c.Get(name); err == nil {
+		return nil, fmt.Errorf("%w : %s", ErrExists, name)
+	}
+
+	// anything left there by an unfinished drop is removed
+	dir := filepath.Join(c.root(), name)
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("couldn't clear database directory : %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("couldn't create database directory : %w", err)
+	}
+	db, err := c.openDir(name, dir, retention, *l)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	db.CreatedAt = time.Now().UTC()
+	if err := db.writeMeta(); err != nil {
+		db.Pipeline.Close()
+		db.WAL.Close()
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("couldn't write database settings : %w", err)
+	}
+	// the wal is empty, this only lets the pipeline start
+	if err := db.Pipeline.WALReplay(); err != nil {
+		c.logger.Error("WAL replay failed for a new database", "database", name, "error", err)
+	}
+
+	c.mu.Lock()
+	c.dbs[name] = db
+	c.mu.Unlock()
+	c.logger.Info("Created database", "database", name, "retention", retention)
+	return db, nil
+}
+
+// Drop stops the database called name and deletes its data. Queued points are
+// discarded.
+func (c *Catalog) Drop(ctx context.Context, name string) error {
+	if name == DefaultName {
+		return ErrDropDefault
+	}
+
+	c.adminMu.Lock()
+	defer c.adminMu.Unlock()
+
+	c.mu.Lock()
+	db, ok := c.dbs[name]
+	delete(c.dbs, name)
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w : %s", ErrNotFound, name)
+	}
+
+	if _, err := db.Pipeline.Shutdown(ctx, false); err != nil {
+		c.logger.Warn("Database didn't stop cleanly, dropping it anyway", "database", name, "error", err)
+	}
+	db.MemTable.FlushMemTable()
+	if err := os.RemoveAll(db.dir); err != nil {
+		return fmt.Errorf("couldn't remove database directory : %w", err)
+	}
+	db.SSTables.UpdateStats()
+	c.logger.Info("Dropped database", "database", name)
+	return nil
+}
+
+// Replay replays the WAL of every database. A database whose WAL can't be
+// replayed stays read only, the others are unaffected.
+func (c *Catalog) Replay() error {
+	var errs []error
+	for _, db := range c.List() {
+		if err := db.Pipeline.WALReplay(); err != nil {
+			errs = append(errs, fmt.Errorf("database %s : %w", db.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// EnforceRetention periodically deletes SSTables that only hold data older
+// than the retention period of their database.
+func (c *Catalog) EnforceRetention(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, db := range c.List() {
+			if db.Retention <= 0 {
+				continue
+			}
+			var deleted int
+			err := db.Pipeline.Freeze(func() (err error) {
+				deleted, err = db.SSTables.DeleteExpired(time.Now().Add(-db.Retention).UnixNano())
+				return err
+			})
+			if err != nil {
+				c.logger.Error("Couldn't enforce retention", "database", db.Name, "error", err)
+			} else if deleted > 0 {
+				c.logger.Info("Retention removed SSTables", "database", db.Name, "deleted", deleted)
+				if err := db.rebuildIndex(); err != nil {
+					c.logger.Error("Couldn't rebuild the series index", "database", db.Name, "error", err)
+				}
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Shutdown drains and closes every database, see PipelineService.Shutdown.
+// The reports are keyed by database name.
+func (c *Catalog) Shutdown(ctx context.Context, flushMemTable bool) (map[string]*ingestpipeline.ShutdownReport, error) {
+	dbs := c.List()
+	reports := make(map[string]*ingestpipeline.ShutdownReport, len(dbs))
+	errs := make([]error, len(dbs))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for i, db := range dbs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report, err := db.Pipeline.Shutdown(ctx, flushMemTable)
+			mu.Lock()
+			reports[db.Name] = report
+			mu.Unlock()
+			if err != nil {
+				errs[i] = fmt.Errorf("database %s : %w", db.Name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return reports, errors.Join(errs...)
+}
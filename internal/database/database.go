@@ -0,0 +1,187 @@
+// Package database keeps the named databases of a TickDB server. Every
+// database has its own WAL, memtable, SSTables, ingest pipeline and retention,
+// stored in its own directory.
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	ingestpipeline "github.com/heyyakash/tickdb/internal/ingest-pipeline"
+	"github.com/heyyakash/tickdb/internal/limits"
+	memtable "github.com/heyyakash/tickdb/internal/mem-table"
+	"github.com/heyyakash/tickdb/internal/promql"
+	"github.com/heyyakash/tickdb/internal/query"
+	seriesindex "github.com/heyyakash/tickdb/internal/series-index"
+	"github.com/heyyakash/tickdb/internal/sstable"
+	"github.com/heyyakash/tickdb/internal/wal"
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// DefaultName is the database used when a request doesn't name one. It is
+// stored in the configured wal and sstable directories and can't be dropped.
+const DefaultName = "default"
+
+// Header selects the database of an HTTP request, the grpc metadata key is
+// the lower case form.
+const Header = "X-TickDB-Database"
+
+var (
+	ErrNotFound     = errors.New("Database not found")
+	ErrExists       = errors.New("Database already exists")
+	ErrInvalidName  = errors.New("Invalid database name, use 1 to 64 letters, digits, '_' or '-'")
+	ErrDropDefault  = errors.New("The default database can't be dropped")
+	validNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+// metaFile holds the settings of a database created at runtime
+const metaFile = "database.json"
+
+type Database struct {
+	Name string
+	// 0 keeps data forever
+	Retention time.Duration
+	CreatedAt time.Time
+
+	WAL      *wal.WAL
+	MemTable *memtable.MemTableService
+	SSTables *sstable.SSTableService
+	Pipeline *ingestpipeline.PipelineService
+	Series   *seriesindex.Index
+	Query    *query.Engine
+	PromQL   *promql.Engine
+
+	// directory holding the wal and sstables, empty for the default database
+	dir string
+}
+
+type meta struct {
+	Name      string         `json:"name"`
+	Retention string         `json:"retention"`
+	Limits    *limits.Limits `json:"limits,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// ValidName reports whether name can be used for a database.
+func ValidName(name string) error {
+	if !validNameRegexp.MatchString(name) {
+		return ErrInvalidName
+	}
+	return nil
+}
+
+// open starts the storage and ingest pipeline of a database.
+func open(name, walDir, sstableDir string, retention time.Duration, l limits.Limits, opts Options, logger *slog.Logger) (*Database, error) {
+	m := memtable.NewMemTableService(make(map[string][]*ingestpb.Point), logger)
+	s := sstable.NewSSTableService(m, sstableDir, logger)
+
+	// series on disk, the ones in the wal are added by the replay
+	series := seriesindex.New()
+	keys, err := s.SeriesKeys()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load the series index : %w", err)
+	}
+	series.Reset(keys)
+
+	w, err := wal.New(walDir, logger)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open the WAL : %w", err)
+	}
+
+	p := ingestpipeline.NewPipeline(w, m, s, opts.QueueSize, opts.FlushThreshold, logger)
+	p.Validator.MaxAge = retention
+	p.Validator.MaxFuture = opts.MaxFuture
+	p.Health = opts.Health
+	if name != DefaultName {
+		p.Database = name
+	}
+	p.Series = series
+	p.Tokens = opts.Tokens
+	p.SetLimits(l)
+
+	q := query.NewEngine(m, s, logger)
+	q.MaxPoints = opts.MaxPointsPerQuery
+	return &Database{
+		Name:      name,
+		Retention: retention,
+		WAL:       w,
+		MemTable:  m,
+		SSTables:  s,
+		Pipeline:  p,
+		Series:    series,
+		Query:     q,
+		PromQL:    promql.NewEngine(q, series),
+	}, nil
+}
+
+// Limits returns the rate limits and quotas of the database.
+func (db *Database) Limits() limits.Limits {
+	return db.Pipeline.Limits()
+}
+
+// rebuildIndex reloads the series index after SSTables were deleted.
+func (db *Database) rebuildIndex() error {
+	keys, err := db.SSTables.SeriesKeys()
+	if err != nil {
+		return err
+	}
+	for key := range db.MemTable.Snapshot() {
+		keys = append(keys, key)
+	}
+	db.Series.Reset(keys)
+	return nil
+}
+
+// readMeta loads the settings stored in a database directory.
+func readMeta(dir string) (*meta, time.Duration, error) {
+	data, err := os.ReadFile(filepath.Join(dir, metaFile))
+	if err != nil {
+		return nil, 0, err
+	}
+	var md meta
+	if err := json.Unmarshal(data, &md); err != nil {
+		return nil, 0, fmt.Errorf("invalid %s : %w", metaFile, err)
+	}
+	var retention time.Duration
+	if md.Retention != "" {
+		if retention, err = time.ParseDuration(md.Retention); err != nil {
+			return nil, 0, fmt.Errorf("invalid retention in %s : %w", metaFile, err)
+		}
+	}
+	return &md, retention, nil
+}
+
+// writeMeta stores the settings of db in its directory.
+func (db *Database) writeMeta() error {
+	l := db.Limits()
+	data, err := json.MarshalIndent(meta{Name: db.Name, Retention: db.Retention.String(), Limits: &l, CreatedAt: db.CreatedAt}, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(db.dir, metaFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+type nameKey struct{}
+
+// WithName returns a context selecting the database called name.
+func WithName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, nameKey{}, name)
+}
+
+// NameFromContext returns the database selected by ctx, empty if none is.
+func NameFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(nameKey{}).(string)
+	return name
+}
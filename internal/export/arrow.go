@@ -0,0 +1,112 @@
+package export
+
+import (
+	"io"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+const (
+	// rows per record batch of an Arrow stream
+	arrowBatchSize = 4096
+	// rows per row group of a Parquet file, a row group is held in memory
+	// until it is written
+	parquetRowGroupSize = 65536
+)
+
+// timestampType is the type of the time column, a Parquet TIMESTAMP(NANOS)
+// adjusted to UTC
+var timestampType = &arrow.TimestampType{Unit: arrow.Nanosecond, TimeZone: "UTC"}
+
+// recordWriter is the Arrow IPC stream or Parquet file writer
+type recordWriter interface {
+	Write(rec arrow.Record) error
+	Close() error
+}
+
+// arrowWriter builds record batches of up to size rows and writes them as an
+// Arrow IPC stream or as the row groups of a Parquet file
+type arrowWriter struct {
+	out     recordWriter
+	builder *array.RecordBuilder
+	rows    *rowMapper
+	size    int
+	pending int
+}
+
+// arrowSchema returns the Arrow schema of the columns of rows, every column
+// but the time is nullable
+func arrowSchema(rows *rowMapper) *arrow.Schema {
+	fields := make([]arrow.Field, 0, len(rows.columns)+1)
+	fields = append(fields, arrow.Field{Name: TimeColumn, Type: timestampType})
+	for _, column := range rows.columns {
+		var t arrow.DataType = arrow.BinaryTypes.String
+		if column.Type == Float {
+			t = arrow.PrimitiveTypes.Float64
+		}
+		fields = append(fields, arrow.Field{Name: column.Name, Type: t, Nullable: true})
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+func newArrowWriter(w io.Writer, rows *rowMapper, size int, asParquet bool) (*arrowWriter, error) {
+	schema := arrowSchema(rows)
+	var out recordWriter
+	if asParquet {
+		props := parquet.NewWriterProperties(
+			parquet.WithCompression(compress.Codecs.Snappy),
+			parquet.WithMaxRowGroupLength(int64(size)),
+		)
+		fw, err := pqarrow.NewFileWriter(schema, w, props, pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema()))
+		if err != nil {
+			return nil, err
+		}
+		out = fw
+	} else {
+		out = ipc.NewWriter(w, ipc.WithSchema(schema))
+	}
+	return &arrowWriter{out: out, builder: array.NewRecordBuilder(memory.DefaultAllocator, schema), rows: rows, size: size}, nil
+}
+
+func (a *arrowWriter) Write(ts int64, tags map[string]string, columns []string, values []any) error {
+	a.builder.Field(0).(*array.TimestampBuilder).Append(arrow.Timestamp(ts))
+	for i, v := range a.rows.mapRow(tags, columns, values) {
+		field := a.builder.Field(i + 1)
+		switch v := v.(type) {
+		case float64:
+			field.(*array.Float64Builder).Append(v)
+		case string:
+			field.(*array.StringBuilder).Append(v)
+		default:
+			field.AppendNull()
+		}
+	}
+	if a.pending++; a.pending >= a.size {
+		return a.flush()
+	}
+	return nil
+}
+
+// flush writes the rows built so far as a record batch
+func (a *arrowWriter) flush() error {
+	a.pending = 0
+	rec := a.builder.NewRecord()
+	defer rec.Release()
+	return a.out.Write(rec)
+}
+
+func (a *arrowWriter) Close() error {
+	defer a.builder.Release()
+	if a.pending > 0 {
+		if err := a.flush(); err != nil {
+			return err
+		}
+	}
+	return a.out.Close()
+}
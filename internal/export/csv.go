@@ -0,0 +1,48 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// csvWriter writes a header row and then a record per row, with the time in
+// RFC3339 with nanoseconds, UTC. Missing values are empty.
+type csvWriter struct {
+	w      *csv.Writer
+	rows   *rowMapper
+	record []string
+}
+
+func newCSVWriter(w io.Writer, rows *rowMapper) (*csvWriter, error) {
+	c := &csvWriter{w: csv.NewWriter(w), rows: rows, record: make([]string, len(rows.columns)+1)}
+	header := append(make([]string, 0, len(c.record)), TimeColumn)
+	for _, column := range rows.columns {
+		header = append(header, column.Name)
+	}
+	if err := c.w.Write(header); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *csvWriter) Write(ts int64, tags map[string]string, columns []string, values []any) error {
+	c.record[0] = time.Unix(0, ts).UTC().Format(time.RFC3339Nano)
+	for i, v := range c.rows.mapRow(tags, columns, values) {
+		switch v := v.(type) {
+		case float64:
+			c.record[i+1] = strconv.FormatFloat(v, 'g', -1, 64)
+		case string:
+			c.record[i+1] = v
+		default:
+			c.record[i+1] = ""
+		}
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
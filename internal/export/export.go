@@ -0,0 +1,203 @@
+// Package export writes query results as tables in CSV, Apache Arrow IPC
+// stream and Parquet format. A table has a time column followed by a column
+// per tag and per field or aggregate. The columns are found by a first read
+// of the result with a Schema, then a Writer writes the rows of a second read.
+package export
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Formats a table can be written in.
+const (
+	CSV     = "csv"
+	Arrow   = "arrow"
+	Parquet = "parquet"
+)
+
+// TimeColumn is the name of the first column of every table
+const TimeColumn = "time"
+
+// ContentType returns the media type of format, empty when format is unknown.
+func ContentType(format string) string {
+	switch format {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case Arrow:
+		return "application/vnd.apache.arrow.stream"
+	case Parquet:
+		return "application/vnd.apache.parquet"
+	}
+	return ""
+}
+
+// Type is the type of the values of a column.
+type Type int
+
+const (
+	Float Type = iota
+	String
+)
+
+// Column is a tag or value column of a table.
+type Column struct {
+	Name string
+	Tag  bool
+	Type Type
+}
+
+// Schema collects the columns of the rows added to it. Tag columns come
+// first, sorted by name, then the value columns in the order they were seen.
+// A value column is a Float when every value added to it is a float64.
+type Schema struct {
+	tags   []string
+	values []Column
+	// position of a value column in values
+	index map[string]int
+	rows  int
+}
+
+func NewSchema() *Schema {
+	return &Schema{index: make(map[string]int)}
+}
+
+// Add records the columns of a row, values holds the value of each of columns.
+// nil values don't decide the type of a column.
+func (s *Schema) Add(tags map[string]string, columns []string, values []any) {
+	s.rows++
+	for tag := range tags {
+		i := sort.SearchStrings(s.tags, tag)
+		if i == len(s.tags) || s.tags[i] != tag {
+			s.tags = append(s.tags, "")
+			copy(s.tags[i+1:], s.tags[i:])
+			s.tags[i] = tag
+		}
+	}
+	for i, name := range columns {
+		pos, ok := s.index[name]
+		if !ok {
+			pos = len(s.values)
+			s.index[name] = pos
+			s.values = append(s.values, Column{Name: name, Type: Float})
+		}
+		if v := values[i]; v != nil {
+			if _, ok := v.(float64); !ok {
+				s.values[pos].Type = String
+			}
+		}
+	}
+}
+
+// Rows returns the number of rows added.
+func (s *Schema) Rows() int {
+	return s.rows
+}
+
+// Columns returns the tag and value columns, without the time column. A tag
+// named like the time column gets a _tag suffix, a value column named like a
+// tag or the time column a _field suffix.
+func (s *Schema) Columns() []Column {
+	taken := map[string]bool{TimeColumn: true}
+	columns := make([]Column, 0, len(s.tags)+len(s.values))
+	for _, tag := range s.tags {
+		name := tag
+		for taken[name] {
+			name += "_tag"
+		}
+		taken[name] = true
+		columns = append(columns, Column{Name: name, Tag: true, Type: String})
+	}
+	for _, c := range s.values {
+		for taken[c.Name] {
+			c.Name += "_field"
+		}
+		taken[c.Name] = true
+		columns = append(columns, c)
+	}
+	return columns
+}
+
+// Writer writes the rows of a table. Values that don't fit the type of their
+// column are converted, a string that isn't a number becomes null in a Float
+// column. Columns the schema didn't see are left out.
+type Writer interface {
+	Write(ts int64, tags map[string]string, columns []string, values []any) error
+	// Close writes what is buffered and the end of the table
+	Close() error
+}
+
+// NewWriter starts writing a table with the columns of schema in format to w.
+func NewWriter(format string, w io.Writer, schema *Schema) (Writer, error) {
+	r := newRowMapper(schema)
+	switch format {
+	case CSV:
+		return newCSVWriter(w, r)
+	case Arrow:
+		return newArrowWriter(w, r, arrowBatchSize, false)
+	case Parquet:
+		return newArrowWriter(w, r, parquetRowGroupSize, true)
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// rowMapper puts the tags and values of a row in the order of the columns
+// of a schema
+type rowMapper struct {
+	columns []Column
+	tags    []string
+	// position of a value column of the schema in columns
+	values map[string]int
+	row    []any
+}
+
+func newRowMapper(schema *Schema) *rowMapper {
+	columns := schema.Columns()
+	r := &rowMapper{columns: columns, tags: schema.tags, values: make(map[string]int, len(schema.values)), row: make([]any, len(columns))}
+	for i, c := range schema.values {
+		r.values[c.Name] = len(schema.tags) + i
+	}
+	return r
+}
+
+// mapRow returns the cells of a row, nil where it has no value. The slice is
+// reused by the next call.
+func (r *rowMapper) mapRow(tags map[string]string, columns []string, values []any) []any {
+	clear(r.row)
+	for i, tag := range r.tags {
+		if v, ok := tags[tag]; ok {
+			r.row[i] = v
+		}
+	}
+	for i, name := range columns {
+		if pos, ok := r.values[name]; ok {
+			r.row[pos] = cell(r.columns[pos].Type, values[i])
+		}
+	}
+	return r.row
+}
+
+// cell converts v to the type of its column
+func cell(t Type, v any) any {
+	switch v := v.(type) {
+	case float64:
+		if t == String {
+			return strconv.FormatFloat(v, 'g', -1, 64)
+		}
+		return v
+	case string:
+		if t == Float {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil
+			}
+			return f
+		}
+		return v
+	case nil:
+		return nil
+	}
+	return fmt.Sprint(v)
+}
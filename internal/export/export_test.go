@@ -0,0 +1,238 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+// row is a row of a query result given to a Writer
+type row struct {
+	ts      int64
+	tags    map[string]string
+	columns []string
+	values  []any
+}
+
+var rows = []row{
+	{1700000000123456789, map[string]string{"host": "a", "dc": "eu"}, []string{"usage", "status"}, []any{1.5, "ok"}},
+	// null fields, and tags and columns the row doesn't have
+	{1700000001000000000, map[string]string{"host": "b"}, []string{"usage"}, []any{nil}},
+	{1700000002000000001, map[string]string{"host": "a", "dc": "us"}, []string{"status", "usage"}, []any{nil, -3.25}},
+}
+
+var wantColumns = []string{TimeColumn, "dc", "host", "usage", "status"}
+
+// wantCells are the cells of rows in the order of wantColumns, without the
+// time
+var wantCells = [][]any{
+	{"eu", "a", 1.5, "ok"},
+	{nil, "b", nil, nil},
+	{"us", "a", -3.25, nil},
+}
+
+func schemaOf(rows []row) *Schema {
+	schema := NewSchema()
+	for _, r := range rows {
+		schema.Add(r.tags, r.columns, r.values)
+	}
+	return schema
+}
+
+func writeRows(t *testing.T, w Writer) {
+	t.Helper()
+	for _, r := range rows {
+		if err := w.Write(r.ts, r.tags, r.columns, r.values); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkCells(t *testing.T, ts []int64, cells [][]any) {
+	t.Helper()
+	if len(cells) != len(rows) {
+		t.Fatalf("read %d rows, want %d", len(cells), len(rows))
+	}
+	for i, r := range rows {
+		if ts[i] != r.ts {
+			t.Errorf("row %d has time %d, want %d", i, ts[i], r.ts)
+		}
+		if !reflect.DeepEqual(cells[i], wantCells[i]) {
+			t.Errorf("row %d is %v, want %v", i, cells[i], wantCells[i])
+		}
+	}
+}
+
+func TestCSV(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(CSV, &buf, schemaOf(rows))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeRows(t, w)
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records[0], wantColumns) {
+		t.Fatalf("header is %v, want %v", records[0], wantColumns)
+	}
+	var ts []int64
+	var cells [][]any
+	for _, record := range records[1:] {
+		at, err := time.Parse(time.RFC3339Nano, record[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts = append(ts, at.UnixNano())
+		row := make([]any, 0, len(record)-1)
+		for i, v := range record[1:] {
+			switch {
+			case v == "":
+				row = append(row, nil)
+			case wantColumns[i+1] == "usage":
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					t.Fatal(err)
+				}
+				row = append(row, f)
+			default:
+				row = append(row, v)
+			}
+		}
+		cells = append(cells, row)
+	}
+	checkCells(t, ts, cells)
+}
+
+// checkSchema checks the columns and their types read back
+func checkSchema(t *testing.T, schema *arrow.Schema) {
+	t.Helper()
+	var names []string
+	for _, f := range schema.Fields() {
+		names = append(names, f.Name)
+	}
+	if !reflect.DeepEqual(names, wantColumns) {
+		t.Fatalf("columns are %v, want %v", names, wantColumns)
+	}
+	if ts, ok := schema.Field(0).Type.(*arrow.TimestampType); !ok || ts.Unit != arrow.Nanosecond || ts.TimeZone != "UTC" {
+		t.Errorf("time column is %v, want timestamp[ns, tz=UTC]", schema.Field(0).Type)
+	}
+	want := []arrow.DataType{arrow.BinaryTypes.String, arrow.BinaryTypes.String, arrow.PrimitiveTypes.Float64, arrow.BinaryTypes.String}
+	for i, typ := range want {
+		f := schema.Field(i + 1)
+		if !arrow.TypeEqual(f.Type, typ) || !f.Nullable {
+			t.Errorf("column %s is %v nullable=%v, want nullable %v", f.Name, f.Type, f.Nullable, typ)
+		}
+	}
+}
+
+// readRecord appends the times and cells of a record batch
+func readRecord(t *testing.T, rec arrow.Record, ts []int64, cells [][]any) ([]int64, [][]any) {
+	t.Helper()
+	times := rec.Column(0).(*array.Timestamp)
+	for i := 0; i < int(rec.NumRows()); i++ {
+		ts = append(ts, int64(times.Value(i)))
+		row := make([]any, 0, rec.NumCols()-1)
+		for c := 1; c < int(rec.NumCols()); c++ {
+			col := rec.Column(c)
+			switch {
+			case col.IsNull(i):
+				row = append(row, nil)
+			case col.DataType().ID() == arrow.FLOAT64:
+				row = append(row, col.(*array.Float64).Value(i))
+			default:
+				row = append(row, col.(*array.String).Value(i))
+			}
+		}
+		cells = append(cells, row)
+	}
+	return ts, cells
+}
+
+func TestArrow(t *testing.T) {
+	for _, size := range []int{arrowBatchSize, 2} {
+		var buf bytes.Buffer
+		schema := schemaOf(rows)
+		w, err := newArrowWriter(&buf, newRowMapper(schema), size, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeRows(t, w)
+
+		r, err := ipc.NewReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSchema(t, r.Schema())
+		var ts []int64
+		var cells [][]any
+		batches := 0
+		for r.Next() {
+			batches++
+			ts, cells = readRecord(t, r.Record(), ts, cells)
+		}
+		if err := r.Err(); err != nil {
+			t.Fatal(err)
+		}
+		r.Release()
+		if want := (len(rows) + size - 1) / size; batches != want {
+			t.Errorf("read %d record batches of %d rows, want %d", batches, size, want)
+		}
+		checkCells(t, ts, cells)
+	}
+}
+
+func TestParquet(t *testing.T) {
+	for _, size := range []int{parquetRowGroupSize, 2} {
+		var buf bytes.Buffer
+		schema := schemaOf(rows)
+		w, err := newArrowWriter(&buf, newRowMapper(schema), size, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeRows(t, w)
+
+		pf, err := file.NewParquetReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (len(rows) + size - 1) / size; pf.NumRowGroups() != want {
+			t.Errorf("file has %d row groups of %d rows, want %d", pf.NumRowGroups(), size, want)
+		}
+		fr, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+		if err != nil {
+			t.Fatal(err)
+		}
+		table, err := fr.ReadTable(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSchema(t, table.Schema())
+		tr := array.NewTableReader(table, 0)
+		var ts []int64
+		var cells [][]any
+		for tr.Next() {
+			ts, cells = readRecord(t, tr.Record(), ts, cells)
+		}
+		tr.Release()
+		table.Release()
+		pf.Close()
+		checkCells(t, ts, cells)
+	}
+}
@@ -0,0 +1,163 @@
+// Package health tracks whether the server is ready to take traffic.
+package health
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status is ready once the WAL replay has finished and every expected
+// listener is accepting connections.
+type Status struct {
+	mu        sync.RWMutex
+	started   time.Time
+	replayed  bool
+	listeners map[string]bool
+	onReady   []func()
+	ready     bool
+
+	// failing components with the reason, reads are still served
+	degraded   map[string]string
+	onDegraded []func(map[string]string)
+}
+
+// New creates a Status that waits for the named listeners.
+func New(listeners ...string) *Status {
+	s := &Status{
+		started:   time.Now(),
+		listeners: make(map[string]bool),
+		degraded:  make(map[string]string),
+	}
+	for _, name := range listeners {
+		s.listeners[name] = false
+	}
+	return s
+}
+
+func (s *Status) Started() time.Time {
+	return s.started
+}
+
+// SetReplayed marks the WAL replay as finished.
+func (s *Status) SetReplayed() {
+	s.mu.Lock()
+	s.replayed = true
+	s.mu.Unlock()
+	s.check()
+}
+
+// SetListening marks a listener as up.
+func (s *Status) SetListening(name string) {
+	s.mu.Lock()
+	s.listeners[name] = true
+	s.mu.Unlock()
+	s.check()
+}
+
+// OnReady registers fn to be called once the server becomes ready. If it is
+// ready already fn is called right away.
+func (s *Status) OnReady(fn func()) {
+	s.mu.Lock()
+	if !s.ready {
+		s.onReady = append(s.onReady, fn)
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	fn()
+}
+
+// Ready reports readiness and, when not ready, what is still missing.
+func (s *Status) Ready() (bool, []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var pending []string
+	if !s.replayed {
+		pending = append(pending, "wal replay")
+	}
+	for name, up := range s.listeners {
+		if !up {
+			pending = append(pending, name+" listener")
+		}
+	}
+	sort.Strings(pending)
+	return len(pending) == 0, pending
+}
+
+func (s *Status) check() {
+	ready, _ := s.Ready()
+
+	s.mu.Lock()
+	if !ready || s.ready {
+		s.mu.Unlock()
+		return
+	}
+	s.ready = true
+	callbacks := s.onReady
+	s.onReady = nil
+	s.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn()
+	}
+}
+
+// SetDegraded records that component is failing. Degraded doesn't affect
+// readiness, the server keeps answering reads.
+func (s *Status) SetDegraded(component, reason string) {
+	s.mu.Lock()
+	if s.degraded[component] == reason {
+		s.mu.Unlock()
+		return
+	}
+	s.degraded[component] = reason
+	s.notifyDegraded()
+}
+
+// ClearDegraded records that component has recovered.
+func (s *Status) ClearDegraded(component string) {
+	s.mu.Lock()
+	if _, ok := s.degraded[component]; !ok {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.degraded, component)
+	s.notifyDegraded()
+}
+
+// Degraded returns the failing components and why they fail.
+func (s *Status) Degraded() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.degradedCopy()
+}
+
+// OnDegradedChange registers fn to be called with the failing components
+// whenever a component fails or recovers.
+func (s *Status) OnDegradedChange(fn func(degraded map[string]string)) {
+	s.mu.Lock()
+	s.onDegraded = append(s.onDegraded, fn)
+	s.mu.Unlock()
+}
+
+func (s *Status) degradedCopy() map[string]string {
+	degraded := make(map[string]string, len(s.degraded))
+	for k, v := range s.degraded {
+		degraded[k] = v
+	}
+	return degraded
+}
+
+// notifyDegraded is called with the lock held and releases it before running
+// the callbacks.
+func (s *Status) notifyDegraded() {
+	degraded := s.degradedCopy()
+	callbacks := append([]func(map[string]string){}, s.onDegraded...)
+	s.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(degraded)
+	}
+}
@@ -0,0 +1,217 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// Roles a CSV column can have.
+const (
+	RoleTime        = "time"
+	RoleMeasurement = "measurement"
+	RoleTag         = "tag"
+	RoleField       = "field"
+	RoleIgnore      = "ignore"
+)
+
+// ParseColumns parses a CSV column mapping such as
+//
+//	ts:time,host:tag,region:tag,usage:field,comment:ignore
+//
+// into the role of each named column. Columns of the header that aren't
+// mapped are fields, except a column named time when no column is mapped to
+// the time role.
+func ParseColumns(spec string) (map[string]string, error) {
+	columns := make(map[string]string)
+	if strings.TrimSpace(spec) == "" {
+		return columns, nil
+	}
+	times, measurements := 0, 0
+	for _, part := range strings.Split(spec, ",") {
+		name, role, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid column mapping %q, expected name:role", part)
+		}
+		switch role {
+		case RoleTime:
+			times++
+		case RoleMeasurement:
+			measurements++
+		case RoleTag, RoleField, RoleIgnore:
+		default:
+			return nil, fmt.Errorf("invalid role %q of column %s, must be one of time, measurement, tag, field or ignore", role, name)
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("column %s is mapped twice", name)
+		}
+		columns[name] = role
+	}
+	if times > 1 || measurements > 1 {
+		return nil, fmt.Errorf("only one column can be mapped to the time and the measurement roles")
+	}
+	return columns, nil
+}
+
+// csvReader reads CSV with a header row, one point per row
+type csvReader struct {
+	r           *csv.Reader
+	columns     map[string]string
+	measurement string
+	precision   ingestpb.Precision
+	// role of each column of the header, read with the first row
+	header []string
+	roles  []string
+	line   int
+}
+
+func newCSVReader(r io.Reader, opts Options) (*csvReader, error) {
+	columns, err := ParseColumns(opts.Columns)
+	if err != nil {
+		return nil, err
+	}
+	hasMeasurement := false
+	for _, role := range columns {
+		hasMeasurement = hasMeasurement || role == RoleMeasurement
+	}
+	if !hasMeasurement && opts.Measurement == "" {
+		return nil, fmt.Errorf("CSV imports need a measurement or a column mapped to the measurement role")
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	return &csvReader{r: cr, columns: columns, measurement: opts.Measurement, precision: opts.Precision}, nil
+}
+
+func (c *csvReader) next() (*ingestpb.Point, int, error) {
+	if c.roles == nil {
+		if err := c.readHeader(); err != nil {
+			return nil, 1, err
+		}
+	}
+	record, err := c.r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, c.line, nil
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		c.line = parseErr.StartLine
+		return nil, c.line, &lineError{parseErr.Err}
+	}
+	if err != nil {
+		return nil, c.line + 1, fmt.Errorf("couldn't read line %d : %w", c.line+1, err)
+	}
+	c.line, _ = c.r.FieldPos(0)
+	point, err := c.point(record)
+	if err != nil {
+		return nil, c.line, &lineError{err}
+	}
+	return point, c.line, nil
+}
+
+// readHeader reads the first row and works out the role of every column
+func (c *csvReader) readHeader() error {
+	header, err := c.r.Read()
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("the CSV file is empty, it needs a header row")
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't read the CSV header : %w", err)
+	}
+	c.line = 1
+	c.header = append([]string(nil), header...)
+
+	hasTime := false
+	for _, role := range c.columns {
+		hasTime = hasTime || role == RoleTime
+	}
+	c.roles = make([]string, len(c.header))
+	seen := make(map[string]bool, len(c.header))
+	for i, name := range c.header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		c.header[i] = name
+		if seen[name] {
+			return fmt.Errorf("column %s appears twice in the CSV header", name)
+		}
+		seen[name] = true
+		role, ok := c.columns[name]
+		switch {
+		case ok:
+		case name == RoleTime && !hasTime:
+			role = RoleTime
+		default:
+			role = RoleField
+		}
+		c.roles[i] = role
+	}
+	for name := range c.columns {
+		if !seen[name] {
+			return fmt.Errorf("mapped column %s is missing from the CSV header", name)
+		}
+	}
+	return nil
+}
+
+// point builds the point of a row, empty cells are left out
+func (c *csvReader) point(record []string) (*ingestpb.Point, error) {
+	if len(record) != len(c.roles) {
+		return nil, fmt.Errorf("row has %d columns but the header has %d", len(record), len(c.roles))
+	}
+	point := &ingestpb.Point{Measurement: c.measurement, Fields: make(map[string]string)}
+	for i, value := range record {
+		if value == "" {
+			continue
+		}
+		name := c.header[i]
+		switch c.roles[i] {
+		case RoleTime:
+			ts, err := c.timestamp(value)
+			if err != nil {
+				return nil, err
+			}
+			point.TimestampUnixNano = ts
+		case RoleMeasurement:
+			point.Measurement = value
+		case RoleTag:
+			if point.Tag == nil {
+				point.Tag = make(map[string]string)
+			}
+			point.Tag[name] = value
+		case RoleField:
+			point.Fields[name] = value
+		}
+	}
+	if point.Measurement == "" {
+		return nil, fmt.Errorf("row has no measurement")
+	}
+	return point, nil
+}
+
+// timestamp parses an integer timestamp in the import precision or an
+// RFC3339 time, which is converted to the import precision
+func (c *csvReader) timestamp(value string) (int64, error) {
+	if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return ts, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, must be an integer or RFC3339", value)
+	}
+	ts := t.UnixNano()
+	switch c.precision {
+	case ingestpb.Precision_PRECISION_SECONDS:
+		ts /= int64(time.Second)
+	case ingestpb.Precision_PRECISION_MILLISECONDS:
+		ts /= int64(time.Millisecond)
+	case ingestpb.Precision_PRECISION_MICROSECONDS:
+		ts /= int64(time.Microsecond)
+	}
+	return ts, nil
+}
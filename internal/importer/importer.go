@@ -0,0 +1,223 @@
+// Package importer reads points from large line protocol and CSV files and
+// writes them through an ingest pipeline BulkWriter, reporting progress and
+// the lines it had to reject as it goes.
+package importer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	ingestpipeline "github.com/heyyakash/tickdb/internal/ingest-pipeline"
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// Formats a file can be imported from.
+const (
+	LineProtocol = "lp"
+	CSV          = "csv"
+)
+
+// maxReportedErrors is how many rejected lines a Report lists, the rest are
+// only counted
+const maxReportedErrors = 100
+
+// maxLineSize is the longest line protocol line accepted
+const maxLineSize = 1 << 20
+
+// Options describe how to read an import.
+type Options struct {
+	// LineProtocol or CSV
+	Format string
+	// measurement of every CSV row, unless the columns have a measurement column
+	Measurement string
+	// column mapping of a CSV file, see ParseColumns
+	Columns string
+	// unit of integer timestamps
+	Precision ingestpb.Precision
+	// the import stops once more lines than this were rejected, 0 never stops
+	MaxErrors int
+}
+
+// LineError is a line that couldn't be imported.
+type LineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// Report is the progress of an import, sent while it runs and once at the end.
+type Report struct {
+	Lines int `json:"lines"`
+	// points accepted, they are stored once their SSTable is written
+	Points   int         `json:"points"`
+	Rejected int         `json:"rejected"`
+	Bytes    int64       `json:"bytes"`
+	SSTables int         `json:"sstables"`
+	Elapsed  string      `json:"elapsed"`
+	Errors   []LineError `json:"errors,omitempty"`
+	// why the import stopped early
+	Error string `json:"error,omitempty"`
+	Done  bool   `json:"done"`
+}
+
+// reader returns the points of an import one line at a time
+type reader interface {
+	// next returns the next point and its line number, nil at the end.
+	// Errors about a single line are returned as a *lineError.
+	next() (*ingestpb.Point, int, error)
+}
+
+// newReader checks opts and returns a reader of r in opts.Format.
+func newReader(r io.Reader, opts Options) (reader, error) {
+	switch opts.Format {
+	case LineProtocol, "":
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+		return &lineReader{scanner: scanner}, nil
+	case CSV:
+		return newCSVReader(r, opts)
+	}
+	return nil, fmt.Errorf("unknown import format %q, must be lp or csv", opts.Format)
+}
+
+// Check returns the error Run would stop with right away because of opts.
+func Check(opts Options) error {
+	_, err := newReader(strings.NewReader(""), opts)
+	return err
+}
+
+// lineReader reads line protocol
+type lineReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (l *lineReader) next() (*ingestpb.Point, int, error) {
+	for l.scanner.Scan() {
+		l.line++
+		text := strings.TrimSpace(l.scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		point, err := ParseLine(text)
+		if err != nil {
+			return nil, l.line, &lineError{err}
+		}
+		return point, l.line, nil
+	}
+	if err := l.scanner.Err(); err != nil {
+		return nil, l.line + 1, fmt.Errorf("couldn't read line %d : %w", l.line+1, err)
+	}
+	return nil, l.line, nil
+}
+
+// lineError is an error about a single line, the import goes on without it
+type lineError struct {
+	err error
+}
+
+func (e *lineError) Error() string {
+	return e.err.Error()
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Run imports the points read from r into w, calling progress about once a
+// second and once more with the final report. Rejected lines are reported and
+// skipped until there are more than opts.MaxErrors of them. Points accepted
+// before the import stopped are kept unless ctx was cancelled. The returned
+// report is the final one.
+func Run(ctx context.Context, r io.Reader, w *ingestpipeline.BulkWriter, opts Options, progress func(Report)) Report {
+	start := time.Now()
+	counter := &countingReader{r: r}
+	var report Report
+	update := func() {
+		report.Bytes = counter.n
+		report.SSTables = len(w.Tables)
+		report.Elapsed = time.Since(start).Round(time.Millisecond).String()
+	}
+	finish := func(err error) Report {
+		if ctx.Err() == nil {
+			if flushErr := w.Flush(); err == nil {
+				err = flushErr
+			}
+		}
+		if err != nil {
+			report.Error = err.Error()
+		}
+		update()
+		report.Done = true
+		if progress != nil {
+			progress(report)
+		}
+		return report
+	}
+
+	points, err := newReader(counter, opts)
+	if err != nil {
+		return finish(err)
+	}
+	reject := func(line int, err error) error {
+		report.Rejected++
+		if len(report.Errors) < maxReportedErrors {
+			report.Errors = append(report.Errors, LineError{Line: line, Error: err.Error()})
+		}
+		if opts.MaxErrors > 0 && report.Rejected > opts.MaxErrors {
+			return fmt.Errorf("stopped after %d rejected lines", report.Rejected)
+		}
+		return nil
+	}
+
+	lastProgress := start
+	for {
+		if err := ctx.Err(); err != nil {
+			return finish(err)
+		}
+		point, line, err := points.next()
+		report.Lines = line
+		var lineErr *lineError
+		switch {
+		case errors.As(err, &lineErr):
+			if err := reject(line, lineErr.err); err != nil {
+				return finish(err)
+			}
+			continue
+		case err != nil:
+			return finish(err)
+		case point == nil:
+			return finish(nil)
+		}
+
+		err = w.Add(ctx, point)
+		var pe *ingestpipeline.PointError
+		if errors.As(err, &pe) {
+			err = reject(line, pe)
+		}
+		if err != nil {
+			return finish(err)
+		}
+		if pe == nil {
+			report.Points++
+		}
+
+		if progress != nil && time.Since(lastProgress) >= time.Second {
+			lastProgress = time.Now()
+			update()
+			progress(report)
+		}
+	}
+}
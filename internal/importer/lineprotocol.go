@@ -0,0 +1,146 @@
+package importer
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// ParseLine parses a line of InfluxDB line protocol:
+//
+//	measurement[,tag=value...] field=value[,field=value...] [timestamp]
+//
+// Commas, spaces and equal signs in names and tag values are escaped with a
+// backslash. Field values are kept as strings: floats as written, integers
+// (10i) and unsigned integers (10u) without the suffix, booleans as true or
+// false and double quoted strings unquoted. The timestamp is left as written,
+// 0 when there is none.
+func ParseLine(line string) (*ingestpb.Point, error) {
+	sections := split(line, ' ', true)
+	if len(sections) < 2 || len(sections) > 3 {
+		return nil, fmt.Errorf("expected measurement and tags, fields and an optional timestamp separated by spaces")
+	}
+
+	series := split(sections[0], ',', false)
+	if len(series) == 0 || sections[0][0] == ',' {
+		return nil, fmt.Errorf("missing measurement")
+	}
+	point := &ingestpb.Point{Measurement: unescape(series[0]), Fields: make(map[string]string)}
+	if point.Measurement == "" {
+		return nil, fmt.Errorf("missing measurement")
+	}
+	for _, tag := range series[1:] {
+		k, v, ok := cut(tag)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid tag %q, expected key=value", tag)
+		}
+		if point.Tag == nil {
+			point.Tag = make(map[string]string)
+		}
+		point.Tag[unescape(k)] = unescape(v)
+	}
+
+	for _, field := range split(sections[1], ',', true) {
+		k, raw, ok := cut(field)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid field %q, expected key=value", field)
+		}
+		v, err := fieldValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", unescape(k), err)
+		}
+		point.Fields[unescape(k)] = v
+	}
+
+	if len(sections) == 3 {
+		ts, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q", sections[2])
+		}
+		point.TimestampUnixNano = ts
+	}
+	return point, nil
+}
+
+// fieldValue turns a line protocol field value into the string stored for it
+func fieldValue(raw string) (string, error) {
+	switch {
+	case len(raw) >= 2 && raw[0] == '"' && raw[len(raw)-1] == '"':
+		return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(raw[1 : len(raw)-1]), nil
+	case strings.HasSuffix(raw, "i"):
+		v, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid integer %q", raw)
+		}
+		return strconv.FormatInt(v, 10), nil
+	case strings.HasSuffix(raw, "u"):
+		v, err := strconv.ParseUint(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid unsigned integer %q", raw)
+		}
+		return strconv.FormatUint(v, 10), nil
+	}
+	switch raw {
+	case "t", "T", "true", "True", "TRUE":
+		return "true", nil
+	case "f", "F", "false", "False", "FALSE":
+		return "false", nil
+	}
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid value %q, strings have to be double quoted", raw)
+	}
+	// NaN and infinities can't be queried or exported as JSON
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("invalid value %q, floats must be finite", raw)
+	}
+	return raw, nil
+}
+
+// split splits s at sep when it isn't escaped with a backslash or, if quotes
+// is set, inside a double quoted string. Empty parts are left out.
+func split(s string, sep byte, quotes bool) []string {
+	var parts []string
+	start, quoted := 0, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case c == '"' && quotes:
+			quoted = !quoted
+		case c == sep && !quoted:
+			if i > start {
+				parts = append(parts, s[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
+
+// cut splits s at the first = that isn't escaped
+func cut(s string) (string, string, bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '=':
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+// unescape removes the backslashes escaping commas, spaces and equal signs
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	return strings.NewReplacer(`\,`, ",", `\ `, " ", `\=`, "=", `\\`, `\`).Replace(s)
+}
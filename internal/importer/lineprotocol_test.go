@@ -0,0 +1,74 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want *ingestpb.Point
+		err  string
+	}{
+		{
+			name: "tags fields and timestamp",
+			line: "cpu,host=a,dc=eu usage=1.5,count=3i 1700000000000000000",
+			want: &ingestpb.Point{Measurement: "cpu", Tag: map[string]string{"host": "a", "dc": "eu"}, Fields: map[string]string{"usage": "1.5", "count": "3"}, TimestampUnixNano: 1700000000000000000},
+		},
+		{
+			name: "no tags or timestamp",
+			line: "cpu usage=2",
+			want: &ingestpb.Point{Measurement: "cpu", Fields: map[string]string{"usage": "2"}},
+		},
+		{
+			name: "escapes",
+			line: `disk\ io,path=C:\\data,label=a\,b\=c\ d read\ bytes=1`,
+			want: &ingestpb.Point{Measurement: "disk io", Tag: map[string]string{"path": `C:\data`, "label": "a,b=c d"}, Fields: map[string]string{"read bytes": "1"}},
+		},
+		{
+			name: "quoted strings",
+			line: `log msg="hello, world = \"quoted\" \\ end",level="warn" 5`,
+			want: &ingestpb.Point{Measurement: "log", Fields: map[string]string{"msg": `hello, world = "quoted" \ end`, "level": "warn"}, TimestampUnixNano: 5},
+		},
+		{
+			name: "integers, unsigned integers and booleans",
+			line: "m a=-10i,b=10u,c=t,d=FALSE",
+			want: &ingestpb.Point{Measurement: "m", Fields: map[string]string{"a": "-10", "b": "10", "c": "true", "d": "false"}},
+		},
+		{name: "negative unsigned integer", line: "m a=-1u", err: "invalid unsigned integer"},
+		{name: "bad integer", line: "m a=1.5i", err: "invalid integer"},
+		{name: "unquoted string", line: "m a=hello", err: "strings have to be double quoted"},
+		{name: "NaN", line: "m a=NaN", err: "floats must be finite"},
+		{name: "infinity", line: "m a=-Inf", err: "floats must be finite"},
+		{name: "bad timestamp", line: "m a=1 yesterday", err: "invalid timestamp"},
+		{name: "timestamp out of range", line: "m a=1 99999999999999999999", err: "invalid timestamp"},
+		{name: "only commas", line: ",,, v=1", err: "missing measurement"},
+		{name: "leading comma", line: ",host=a v=1", err: "missing measurement"},
+		{name: "no fields", line: "cpu", err: "expected measurement"},
+		{name: "too many sections", line: "cpu v=1 1 2", err: "expected measurement"},
+		{name: "bad tag", line: "cpu,host v=1", err: "invalid tag"},
+		{name: "bad field", line: "cpu v", err: "invalid field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLine(tt.line)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("ParseLine(%q) error = %v, want %q", tt.line, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseLine(%q) failed: %v", tt.line, err)
+			}
+			if !proto.Equal(got, tt.want) {
+				t.Errorf("ParseLine(%q) = %v, want %v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
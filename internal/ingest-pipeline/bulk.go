@@ -0,0 +1,121 @@
+package ingestpipeline
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/heyyakash/tickdb/internal/limits"
+	memtable "github.com/heyyakash/tickdb/internal/mem-table"
+	seriesindex "github.com/heyyakash/tickdb/internal/series-index"
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// bulkTablePoints is how many points a BulkWriter buffers before writing
+// them to an SSTable
+const bulkTablePoints = 100000
+
+// BulkWriter writes points straight to sorted SSTables, skipping the WAL and
+// the memtable. Points go through the same checks as Ingest, but are only
+// stored once Flush wrote them.
+type BulkWriter struct {
+	p          *PipelineService
+	precision  ingestpb.Precision
+	receivedAt time.Time
+
+	series   map[string][]*ingestpb.Point
+	buffered int
+	// buffered series the index doesn't have yet, in total and by
+	// measurement, they count toward the series quotas until Flush
+	// records them
+	newSeries   int
+	newSeriesIn map[string]int
+
+	// points written to SSTables and the paths of the tables
+	Points int
+	Tables []string
+}
+
+// NewBulkWriter starts a bulk import into the pipeline's database. Timestamps
+// are in precision, points without one get receivedAt.
+func (p *PipelineService) NewBulkWriter(precision ingestpb.Precision, receivedAt time.Time) *BulkWriter {
+	return &BulkWriter{p: p, precision: precision, receivedAt: receivedAt, series: make(map[string][]*ingestpb.Point), newSeriesIn: make(map[string]int)}
+}
+
+// Add checks point and buffers it, writing an SSTable once enough points are
+// buffered. Rejected points return a *PointError, any other error means the
+// import can't go on. Rate limits are waited out.
+func (b *BulkWriter) Add(ctx context.Context, point *ingestpb.Point) error {
+	err := authorize(ctx, point)
+	if err == nil {
+		err = Normalize(point, b.precision, b.receivedAt)
+	}
+	if err == nil {
+		err = b.p.Validator.Validate(point)
+	}
+	if err == nil {
+		_, err = b.p.admitWait(ctx, point, b.checkSeries)
+	}
+	b.p.recordIngest(ctx, point, err)
+	if err != nil {
+		return err
+	}
+
+	key := memtable.SeriesKey(point)
+	if _, ok := b.series[key]; !ok && b.p.Series != nil && !b.p.Series.Contains(key) {
+		name, _, _ := strings.Cut(key, "|")
+		b.newSeries++
+		b.newSeriesIn[name]++
+	}
+	b.series[key] = append(b.series[key], point)
+	if b.buffered++; b.buffered >= bulkTablePoints {
+		return b.Flush()
+	}
+	return nil
+}
+
+// checkSeries checks a series against the series quotas without recording
+// it, the index only learns about the series once Flush wrote them.
+func (b *BulkWriter) checkSeries(key string, l limits.Limits) (seriesindex.Verdict, func()) {
+	if _, ok := b.series[key]; ok {
+		return seriesindex.Admitted, func() {}
+	}
+	name, _, _ := strings.Cut(key, "|")
+	return b.p.Series.CheckPending(key, l.MaxSeries, l.MaxSeriesPerMeasurement, b.newSeries, b.newSeriesIn[name]), func() {}
+}
+
+// Flush writes the buffered points to a new SSTable and records their
+// series in the index.
+func (b *BulkWriter) Flush() error {
+	if b.buffered == 0 {
+		return nil
+	}
+	p := b.p
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	p.tablesMu.Lock()
+	defer p.tablesMu.Unlock()
+	if p.closed {
+		return ErrPipelineClosed
+	}
+	if err := p.readOnlyError(); err != nil {
+		return err
+	}
+
+	path, err := p.sstableService.WriteTable(b.series)
+	if err != nil {
+		return err
+	}
+	if p.Series != nil {
+		for key := range b.series {
+			p.Series.Add(key)
+		}
+	}
+	b.Points += b.buffered
+	b.Tables = append(b.Tables, path)
+	b.series = make(map[string][]*ingestpb.Point)
+	b.buffered = 0
+	b.newSeries = 0
+	clear(b.newSeriesIn)
+	return nil
+}
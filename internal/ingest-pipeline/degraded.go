@@ -0,0 +1,128 @@
+package ingestpipeline
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/heyyakash/tickdb/internal/metrics"
+)
+
+var ErrReadOnly = errors.New("Storage is read only")
+
+// storage operations are retried with an exponential backoff between these
+// bounds while the pipeline refuses writes
+const (
+	retryMinBackoff = 100 * time.Millisecond
+	retryMaxBackoff = 30 * time.Second
+)
+
+// retry runs fn until it succeeds. While it fails the pipeline is read only
+// and op is reported as degraded. It gives up when the pipeline is stopped.
+func (p *PipelineService) retry(op string, fn func() error) error {
+	backoff := retryMinBackoff
+	for {
+		err := fn()
+		if err == nil {
+			p.clearDegraded(op)
+			return nil
+		}
+		metrics.StorageErrors.WithLabelValues(op).Inc()
+		p.setDegraded(op, err)
+		p.logger.Warn("Storage operation failed, retrying", "op", op, "backoff", backoff, "error", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-p.ctx.Done():
+			return err
+		}
+		backoff = min(backoff*2, retryMaxBackoff)
+	}
+}
+
+// setDegraded puts the pipeline in read only mode because op failed.
+func (p *PipelineService) setDegraded(op string, err error) {
+	p.degradedMu.Lock()
+	defer p.degradedMu.Unlock()
+
+	if _, ok := p.degraded[op]; !ok {
+		p.logger.Error("Storage failure, refusing writes", "op", op, "error", err)
+	}
+	p.degraded[op] = err.Error()
+	// the gauge counts read only pipelines, one per database
+	if !p.readOnly.Swap(true) {
+		metrics.ReadOnly.Inc()
+	}
+	if p.Health != nil {
+		p.Health.SetDegraded(p.healthComponent(op), err.Error())
+	}
+}
+
+// clearDegraded marks op as recovered, writes are accepted again once no
+// operation is failing.
+func (p *PipelineService) clearDegraded(op string) {
+	if !p.readOnly.Load() {
+		return
+	}
+
+	p.degradedMu.Lock()
+	defer p.degradedMu.Unlock()
+
+	if _, ok := p.degraded[op]; !ok {
+		return
+	}
+	delete(p.degraded, op)
+	p.logger.Info("Storage operation recovered", "op", op)
+	if len(p.degraded) == 0 {
+		p.readOnly.Store(false)
+		metrics.ReadOnly.Dec()
+		p.logger.Info("Storage recovered, accepting writes again")
+	}
+	if p.Health != nil {
+		p.Health.ClearDegraded(p.healthComponent(op))
+	}
+}
+
+// healthComponent names op in the health status, prefixed with the database
+// unless it's the default one.
+func (p *PipelineService) healthComponent(op string) string {
+	if p.Database == "" {
+		return op
+	}
+	return p.Database + "/" + op
+}
+
+// releaseDegraded withdraws the failures reported by a pipeline that has been
+// shut down, so a dropped database doesn't keep the server degraded.
+func (p *PipelineService) releaseDegraded() {
+	p.degradedMu.Lock()
+	defer p.degradedMu.Unlock()
+
+	if p.Health != nil {
+		for op := range p.degraded {
+			p.Health.ClearDegraded(p.healthComponent(op))
+		}
+	}
+	if p.readOnly.Load() {
+		metrics.ReadOnly.Dec()
+	}
+}
+
+// readOnlyError explains why writes are refused, nil if they aren't.
+func (p *PipelineService) readOnlyError() error {
+	if !p.readOnly.Load() {
+		return nil
+	}
+
+	p.degradedMu.Lock()
+	defer p.degradedMu.Unlock()
+
+	reasons := make([]string, 0, len(p.degraded))
+	for op, reason := range p.degraded {
+		reasons = append(reasons, op+" : "+reason)
+	}
+	sort.Strings(reasons)
+	return fmt.Errorf("%w, %s", ErrReadOnly, strings.Join(reasons, ", "))
+}
@@ -0,0 +1,138 @@
+package ingestpipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/heyyakash/tickdb/internal/auth"
+	"github.com/heyyakash/tickdb/internal/limits"
+	memtable "github.com/heyyakash/tickdb/internal/mem-table"
+	seriesindex "github.com/heyyakash/tickdb/internal/series-index"
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+	"google.golang.org/protobuf/proto"
+)
+
+// databaseLimits are the limits of the pipeline's database with the rate
+// limiter built from them
+type databaseLimits struct {
+	limits.Limits
+	rate *limits.Rate
+}
+
+// SetLimits sets the rate limits and quotas of the pipeline's database.
+func (p *PipelineService) SetLimits(l limits.Limits) {
+	p.limits.Store(&databaseLimits{Limits: l, rate: limits.NewRate(p.owner(), l.PointsPerSecond, l.BytesPerSecond)})
+}
+
+// Limits returns the rate limits and quotas of the pipeline's database.
+func (p *PipelineService) Limits() limits.Limits {
+	if l := p.limits.Load(); l != nil {
+		return l.Limits
+	}
+	return limits.Limits{}
+}
+
+// owner names the pipeline's database in limit errors
+func (p *PipelineService) owner() string {
+	if p.Database == "" {
+		return `database "default"`
+	}
+	return fmt.Sprintf("database %q", p.Database)
+}
+
+// admitSeries checks the series of a point against the series quotas of
+// the database. release undoes what it recorded.
+type admitSeries func(key string, l limits.Limits) (v seriesindex.Verdict, release func())
+
+// recordSeries admits the series of a point and records it in the index
+func (p *PipelineService) recordSeries(key string, l limits.Limits) (seriesindex.Verdict, func()) {
+	v, added := p.Series.Admit(key, l.MaxSeries, l.MaxSeriesPerMeasurement)
+	if !added {
+		return v, func() {}
+	}
+	return v, func() { p.Series.Remove(key) }
+}
+
+// admit checks a point against the quotas of the database and the rate limits
+// of the database and the caller's API token, admitting its series with
+// series. Nothing is taken from the rates unless every limit passes. release
+// undoes what series recorded, callers call it when the point couldn't be
+// queued after all.
+func (p *PipelineService) admit(ctx context.Context, point *ingestpb.Point, series admitSeries) (release func(), err error) {
+	l := p.limits.Load()
+	if l == nil {
+		l = &databaseLimits{}
+	}
+	if l.MaxStoredBytes > 0 && p.sstableService.Bytes() >= l.MaxStoredBytes {
+		return nil, limitError(&limits.Error{Owner: p.owner(), Limit: "stored bytes", Value: l.MaxStoredBytes, Quota: true})
+	}
+
+	release = func() {}
+	if p.Series != nil {
+		var v seriesindex.Verdict
+		if v, release = series(memtable.SeriesKey(point), l.Limits); v != seriesindex.Admitted {
+			return nil, limitError(p.seriesError(v, point.Measurement, l.Limits))
+		}
+	}
+
+	var tokenRate *limits.Rate
+	if token := auth.FromContext(ctx); token != nil && token.RateLimit != nil && p.Tokens != nil {
+		tokenRate = p.Tokens.Get(token.ID, fmt.Sprintf("token %q", token.Name), token.RateLimit.PointsPerSecond, token.RateLimit.BytesPerSecond)
+	}
+	if err := limits.Allow(proto.Size(point), tokenRate, l.rate); err != nil {
+		release()
+		return nil, limitError(err)
+	}
+	return release, nil
+}
+
+// seriesError describes a series quota that was hit, naming the tag with the
+// most values as the likely cause.
+func (p *PipelineService) seriesError(v seriesindex.Verdict, measurement string, l limits.Limits) *limits.Error {
+	err := &limits.Error{Owner: p.owner(), Limit: "series", Value: int64(l.MaxSeries), Quota: true}
+	if v == seriesindex.MeasurementFull {
+		err.Owner = fmt.Sprintf("measurement %q of %s", measurement, p.owner())
+		err.Value = int64(l.MaxSeriesPerMeasurement)
+		if top, ok := p.Series.TopTag(measurement); ok {
+			err.Tag, err.TagValues = top.Tag, top.Values
+		}
+		return err
+	}
+	if top, ok := p.Series.TopTag(""); ok {
+		err.Tag, err.TagMeasurement, err.TagValues = top.Tag, top.Measurement, top.Values
+	}
+	return err
+}
+
+// admitWait is admit for streaming writers, it waits out rate limits instead
+// of rejecting the point.
+func (p *PipelineService) admitWait(ctx context.Context, point *ingestpb.Point, series admitSeries) (release func(), err error) {
+	for {
+		release, err := p.admit(ctx, point, series)
+		var pe *PointError
+		if !errors.As(err, &pe) || pe.RetryAfter == 0 {
+			return release, err
+		}
+		select {
+		case <-time.After(pe.RetryAfter):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-p.ctx.Done():
+			return nil, context.Canceled
+		}
+	}
+}
+
+func limitError(err error) error {
+	var le *limits.Error
+	if !errors.As(err, &le) {
+		return err
+	}
+	code := ingestpb.RejectCode_REJECT_CODE_RATE_LIMITED
+	if le.Quota {
+		code = ingestpb.RejectCode_REJECT_CODE_QUOTA_EXCEEDED
+	}
+	return &PointError{Code: code, Message: le.Error(), RetryAfter: le.RetryAfter}
+}
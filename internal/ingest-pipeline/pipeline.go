@@ -3,30 +3,84 @@ package ingestpipeline
 import (
 	"context"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/heyyakash/tickdb/internal/auth"
+	"github.com/heyyakash/tickdb/internal/health"
+	"github.com/heyyakash/tickdb/internal/limits"
 	memtable "github.com/heyyakash/tickdb/internal/mem-table"
+	"github.com/heyyakash/tickdb/internal/metrics"
+	seriesindex "github.com/heyyakash/tickdb/internal/series-index"
 	"github.com/heyyakash/tickdb/internal/sstable"
 	"github.com/heyyakash/tickdb/internal/wal"
 	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
 )
 
+var ErrPipelineFull = errors.New("Pipeline chanel is full")
+var ErrPipelineClosed = errors.New("Pipeline is shutting down")
+
 type PipelineService struct {
+	Validator *Validator
+	// optional, storage failures are reported to it
+	Health *health.Status
+	// database the pipeline writes to, prefixes the components reported to
+	// Health. Empty for the default database.
+	Database string
+	// optional, series written to the database. The series quota needs it.
+	Series *seriesindex.Index
+	// optional, rate limiters of the API tokens, shared by every database
+	Tokens *limits.Registry
+
 	wal              *wal.WAL
 	memtableSerivice *memtable.MemTableService
 	sstableService   *sstable.SSTableService
 	Pipeline         chan *ingestpb.Point
-	ctx              context.Context
-	cancel           context.CancelFunc
+	flushThreshold   int
+	logger           *slog.Logger
+	replayDone       chan struct{}
+
+	// closed stops intake, senders hold the read lock while queueing so no
+	// point can slip in after Shutdown has taken the write lock
+	mu       sync.RWMutex
+	closed   bool
+	draining chan struct{}
+	stopped  chan struct{}
+	drained  atomic.Int64
+
+	limits atomic.Pointer[databaseLimits]
+
+	// held while the set of SSTables or the active WAL segment changes, see
+	// Freeze
+	tablesMu sync.Mutex
+
+	// failing storage operations, writes are refused while there are any
+	degradedMu sync.Mutex
+	degraded   map[string]string
+	readOnly   atomic.Bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
-func NewPipeline(w *wal.WAL, m *memtable.MemTableService, s *sstable.SSTableService) *PipelineService {
+func NewPipeline(w *wal.WAL, m *memtable.MemTableService, s *sstable.SSTableService, queueSize int, flushThreshold int, logger *slog.Logger) *PipelineService {
 	ctx, cancel := context.WithCancel(context.Background())
 	p := &PipelineService{
+		Validator:        NewValidator(),
 		wal:              w,
 		memtableSerivice: m,
 		sstableService:   s,
-		Pipeline:         make(chan *ingestpb.Point, 100),
+		Pipeline:         make(chan *ingestpb.Point, queueSize),
+		flushThreshold:   flushThreshold,
+		logger:           logger,
+		replayDone:       make(chan struct{}),
+		draining:         make(chan struct{}),
+		stopped:          make(chan struct{}),
+		degraded:         make(map[string]string),
 		ctx:              ctx,
 		cancel:           cancel,
 	}
@@ -35,27 +89,57 @@ func NewPipeline(w *wal.WAL, m *memtable.MemTableService, s *sstable.SSTableServ
 	return p
 }
 
-func (p *PipelineService) WALReplay() {
-	points := p.wal.Replay()
+// WALReplay loads the active WAL segment into the memtable. If the segment
+// can't be read fully the points before the bad record are loaded and the
+// pipeline stays read only, so nothing is appended after the damage.
+func (p *PipelineService) WALReplay() error {
+	defer close(p.replayDone)
+
+	points, err := p.wal.Replay()
 	for _, point := range points {
 		p.memtableSerivice.AddToMemTable(point)
+		if p.Series != nil {
+			p.Series.Add(memtable.SeriesKey(point))
+		}
 	}
-	log.Printf("WAL Replay success!!")
+	if err != nil {
+		metrics.StorageErrors.WithLabelValues("wal_replay").Inc()
+		p.setDegraded("wal_replay", err)
+		return err
+	}
+	p.logger.Info("WAL replay finished", "points", len(points))
 	p.memtableSerivice.LogMemTable()
+	return nil
 }
 
+// ProcessDataPoint drains the pipeline into the WAL and memtable. It waits for
+// WALReplay so new points can't interleave with the replayed ones.
+// Once Shutdown starts it empties the channel and returns.
 func (p *PipelineService) ProcessDataPoint() {
+	defer close(p.stopped)
+
+	select {
+	case <-p.replayDone:
+	case <-p.ctx.Done():
+		return
+	}
+
 	for {
 		select {
 		case point := <-p.Pipeline:
-			if err := p.wal.Append(point); err != nil {
-				log.Printf("Couldn't process datapoint")
-			}
-			p.memtableSerivice.AddToMemTable(point)
-			if p.memtableSerivice.CountPoints() >= 200 {
-				p.sstableService.Flush(p.wal.GetWalStartTime())
-				p.memtableSerivice.FlushMemTable()
-				p.wal.Flush()
+			p.process(point)
+
+		case <-p.draining:
+			for {
+				select {
+				case point := <-p.Pipeline:
+					p.process(point)
+					p.drained.Add(1)
+				case <-p.ctx.Done():
+					return
+				default:
+					return
+				}
 			}
 
 		case <-p.ctx.Done():
@@ -64,17 +148,261 @@ func (p *PipelineService) ProcessDataPoint() {
 	}
 }
 
+// process writes a queued point to the WAL and memtable. Storage failures are
+// retried, the pipeline refuses new points until they succeed.
+func (p *PipelineService) process(point *ingestpb.Point) {
+	metrics.PipelineDepth.Dec()
+	if err := p.retry("wal_append", func() error { return p.wal.Append(point) }); err != nil {
+		p.logger.Error("Couldn't write datapoint to the WAL, dropping it", "measurement", point.Measurement, "error", err)
+		return
+	}
+	p.memtableSerivice.AddToMemTable(point)
+	if p.Series != nil {
+		p.Series.Add(memtable.SeriesKey(point))
+	}
+	if p.memtableSerivice.CountPoints() >= p.flushThreshold {
+		if _, err := p.flush(); err != nil {
+			p.logger.Error("Couldn't flush the memtable", "error", err)
+		}
+	}
+}
+
+// flush writes the memtable to an SSTable and starts a new WAL segment. It
+// returns the path of the new SSTable.
+func (p *PipelineService) flush() (string, error) {
+	p.tablesMu.Lock()
+	defer p.tablesMu.Unlock()
+
+	var path string
+	err := p.retry("sstable_flush", func() (err error) {
+		path, err = p.sstableService.Flush(p.wal.GetWalStartTime())
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	p.memtableSerivice.FlushMemTable()
+	return path, p.retry("wal_rotate", p.wal.Flush)
+}
+
+// Freeze runs fn while no SSTable is written or removed through the pipeline
+// and the WAL isn't rotated, so the SSTables and the active WAL segment seen
+// by fn hold every stored point exactly once. Points are still appended to
+// the active segment meanwhile.
+func (p *PipelineService) Freeze(fn func() error) error {
+	p.tablesMu.Lock()
+	defer p.tablesMu.Unlock()
+	return fn()
+}
+
+// AddDataPoint validates the point and queues it. Invalid points are
+// rejected with a *PointError.
 func (p *PipelineService) AddDataPoint(point *ingestpb.Point) error {
+	if err := p.Validator.Validate(point); err != nil {
+		return err
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrPipelineClosed
+	}
+	if err := p.readOnlyError(); err != nil {
+		return err
+	}
+
 	select {
 	case p.Pipeline <- point:
+		metrics.PipelineDepth.Inc()
 		return nil
 	case <-p.ctx.Done():
 		return context.Canceled
 	default:
-		return errors.New("Pipeline chanel is full")
+		return ErrPipelineFull
+	}
+}
+
+// AddDataPointWait blocks until the pipeline has room for the point instead of
+// rejecting it, so streaming callers can push the backpressure to their clients.
+func (p *PipelineService) AddDataPointWait(ctx context.Context, point *ingestpb.Point) error {
+	if err := p.Validator.Validate(point); err != nil {
+		return err
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrPipelineClosed
+	}
+	if err := p.readOnlyError(); err != nil {
+		return err
+	}
+
+	select {
+	case p.Pipeline <- point:
+		metrics.PipelineDepth.Inc()
+		return nil
+	case <-p.ctx.Done():
+		return context.Canceled
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Ingest normalizes a point received from a client, checks it against the
+// write limits and queues it, counting the outcome in the ingest metrics.
+func (p *PipelineService) Ingest(ctx context.Context, point *ingestpb.Point, precision ingestpb.Precision, receivedAt time.Time) error {
+	err := authorize(ctx, point)
+	if err == nil {
+		err = Normalize(point, precision, receivedAt)
+	}
+	if err == nil {
+		err = p.Validator.Validate(point)
+	}
+	var release func()
+	if err == nil {
+		release, err = p.admit(ctx, point, p.recordSeries)
+	}
+	if err == nil {
+		if err = p.AddDataPoint(point); err != nil {
+			release()
+		}
+	}
+	p.recordIngest(ctx, point, err)
+	return err
+}
+
+// IngestWait is Ingest with the blocking behaviour of AddDataPointWait, rate
+// limits are waited out as well.
+func (p *PipelineService) IngestWait(ctx context.Context, point *ingestpb.Point, precision ingestpb.Precision, receivedAt time.Time) error {
+	err := authorize(ctx, point)
+	if err == nil {
+		err = Normalize(point, precision, receivedAt)
+	}
+	if err == nil {
+		err = p.Validator.Validate(point)
+	}
+	var release func()
+	if err == nil {
+		release, err = p.admitWait(ctx, point, p.recordSeries)
+	}
+	if err == nil {
+		if err = p.AddDataPointWait(ctx, point); err != nil {
+			release()
+		}
+	}
+	p.recordIngest(ctx, point, err)
+	return err
+}
+
+// authorize rejects points for measurements the caller's API token may not
+// write to.
+func authorize(ctx context.Context, point *ingestpb.Point) error {
+	if point != nil && !auth.AllowsMeasurement(ctx, point.Measurement) {
+		return rejectf(ingestpb.RejectCode_REJECT_CODE_MEASUREMENT_NOT_ALLOWED, "token may not write to measurement %q", point.Measurement)
+	}
+	return nil
+}
+
+func (p *PipelineService) recordIngest(ctx context.Context, point *ingestpb.Point, err error) {
+	if err == nil {
+		metrics.IngestAccepted.Inc()
+		return
 	}
+	reason := strings.ToLower(strings.TrimPrefix(Rejection(0, err).Code.String(), "REJECT_CODE_"))
+	metrics.IngestRejected.WithLabelValues(reason).Inc()
+	p.logger.DebugContext(ctx, "Point rejected", "measurement", point.GetMeasurement(), "reason", reason, "error", err)
 }
 
+// Close stops the pipeline right away, points still queued are dropped. Use
+// Shutdown to persist them.
 func (p *PipelineService) Close() {
 	p.cancel()
 }
+
+// ShutdownReport describes what Shutdown persisted.
+type ShutdownReport struct {
+	// queued points written to the WAL while draining
+	Drained int
+	// queued points lost because the deadline passed first
+	Dropped int
+	// points left in the WAL, replayed on the next start
+	WALPoints int
+	// points and series written to FlushedSSTable
+	FlushedPoints  int
+	FlushedSeries  int
+	FlushedSSTable string
+	// set when the memtable flush was asked for but skipped
+	FlushSkipped string
+}
+
+func (r *ShutdownReport) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.Int("drained", r.Drained),
+		slog.Int("dropped", r.Dropped),
+		slog.Int("wal_points", r.WALPoints),
+	}
+	if r.FlushedSSTable != "" {
+		attrs = append(attrs, slog.Int("flushed_points", r.FlushedPoints), slog.Int("flushed_series", r.FlushedSeries), slog.String("sstable", r.FlushedSSTable))
+	}
+	if r.FlushSkipped != "" {
+		attrs = append(attrs, slog.String("flush_skipped", r.FlushSkipped))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// Shutdown stops intake, drains the queued points into the WAL and memtable,
+// optionally flushes the memtable to an SSTable and closes the WAL. Work that
+// doesn't fit before ctx is done is given up and counted in the report.
+func (p *PipelineService) Shutdown(ctx context.Context, flushMemTable bool) (*ShutdownReport, error) {
+	report := &ShutdownReport{}
+
+	// waits for senders blocked in AddDataPointWait, they finish as the
+	// channel keeps draining
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	close(p.draining)
+
+	var err error
+	select {
+	case <-p.stopped:
+	case <-ctx.Done():
+		err = fmt.Errorf("pipeline didn't drain in time : %w", ctx.Err())
+		p.cancel()
+		<-p.stopped
+	}
+	p.cancel()
+	report.Drained = int(p.drained.Load())
+	report.Dropped = len(p.Pipeline)
+	metrics.PipelineDepth.Sub(float64(report.Dropped))
+
+	if flushMemTable {
+		points, series := p.memtableSerivice.Stats()
+		switch {
+		case err != nil:
+			report.FlushSkipped = "shutdown deadline exceeded"
+		case p.readOnly.Load():
+			report.FlushSkipped = "storage is read only"
+		case points == 0:
+		default:
+			// the pipeline is stopped, so this is a single attempt
+			path, ferr := p.flush()
+			if path != "" {
+				report.FlushedSSTable = path
+				report.FlushedPoints = points
+				report.FlushedSeries = series
+			}
+			if ferr != nil {
+				report.FlushSkipped = ferr.Error()
+			}
+		}
+	}
+	report.WALPoints, _ = p.memtableSerivice.Stats()
+
+	if cerr := p.wal.Close(); cerr != nil && err == nil {
+		err = fmt.Errorf("couldn't close the WAL : %w", cerr)
+	}
+	p.releaseDegraded()
+	return report, err
+}
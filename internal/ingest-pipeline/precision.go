@@ -0,0 +1,93 @@
+package ingestpipeline
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// A timestamp further than this from the server clock is considered
+// implausible when looking for a wrong precision.
+const plausibleWindow = 50 * 365 * 24 * time.Hour
+
+var precisionNames = map[ingestpb.Precision]string{
+	ingestpb.Precision_PRECISION_SECONDS:      "s",
+	ingestpb.Precision_PRECISION_MILLISECONDS: "ms",
+	ingestpb.Precision_PRECISION_MICROSECONDS: "us",
+	ingestpb.Precision_PRECISION_NANOSECONDS:  "ns",
+}
+
+var precisionFactors = map[ingestpb.Precision]int64{
+	ingestpb.Precision_PRECISION_SECONDS:      int64(time.Second),
+	ingestpb.Precision_PRECISION_MILLISECONDS: int64(time.Millisecond),
+	ingestpb.Precision_PRECISION_MICROSECONDS: int64(time.Microsecond),
+	ingestpb.Precision_PRECISION_NANOSECONDS:  1,
+}
+
+// ParsePrecision parses the precision query parameter of the rest api.
+// An empty string means nanoseconds.
+func ParsePrecision(s string) (ingestpb.Precision, error) {
+	if s == "" {
+		return ingestpb.Precision_PRECISION_NANOSECONDS, nil
+	}
+	for p, name := range precisionNames {
+		if name == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid precision %q, must be one of s, ms, us or ns", s)
+}
+
+// Normalize converts the point timestamp from precision to nanoseconds. A
+// point without a timestamp gets receivedAt. Timestamps that only make sense
+// in a different unit are rejected with a *PointError.
+func Normalize(point *ingestpb.Point, precision ingestpb.Precision, receivedAt time.Time) error {
+	if point == nil {
+		return nil
+	}
+	if point.TimestampUnixNano == 0 {
+		point.TimestampUnixNano = receivedAt.UnixNano()
+		return nil
+	}
+
+	if precision == ingestpb.Precision_PRECISION_UNSPECIFIED {
+		precision = ingestpb.Precision_PRECISION_NANOSECONDS
+	}
+	factor, ok := precisionFactors[precision]
+	if !ok {
+		return rejectf(ingestpb.RejectCode_REJECT_CODE_TIMESTAMP_WRONG_PRECISION, "unknown precision %v", precision)
+	}
+
+	raw := point.TimestampUnixNano
+	ts, ok := scale(raw, factor)
+	if !ok || !plausible(ts, receivedAt) {
+		for other, otherFactor := range precisionFactors {
+			if other == precision {
+				continue
+			}
+			if alt, ok := scale(raw, otherFactor); ok && plausible(alt, receivedAt) {
+				return rejectf(ingestpb.RejectCode_REJECT_CODE_TIMESTAMP_WRONG_PRECISION, "timestamp %d looks like it is in %s but precision is %s", raw, precisionNames[other], precisionNames[precision])
+			}
+		}
+	}
+	if !ok {
+		return rejectf(ingestpb.RejectCode_REJECT_CODE_TIMESTAMP_OUT_OF_RANGE, "timestamp %d overflows when converted from %s to ns", raw, precisionNames[precision])
+	}
+
+	point.TimestampUnixNano = ts
+	return nil
+}
+
+func scale(ts, factor int64) (int64, bool) {
+	if ts > math.MaxInt64/factor || ts < math.MinInt64/factor {
+		return 0, false
+	}
+	return ts * factor, true
+}
+
+func plausible(ts int64, now time.Time) bool {
+	d := now.Sub(time.Unix(0, ts))
+	return d < plausibleWindow && d > -plausibleWindow
+}
@@ -0,0 +1,117 @@
+package ingestpipeline
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// PointError explains why a single point was rejected.
+type PointError struct {
+	Code    ingestpb.RejectCode
+	Message string
+	// set on rate limited points, when they may be retried
+	RetryAfter time.Duration
+}
+
+func (e *PointError) Error() string {
+	return e.Message
+}
+
+func rejectf(code ingestpb.RejectCode, format string, args ...any) *PointError {
+	return &PointError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Rejection builds the rejection entry reported to clients for the point at
+// index. Errors that aren't a PointError come from the pipeline itself.
+func Rejection(index int, err error) *ingestpb.Rejection {
+	if pe, ok := err.(*PointError); ok {
+		return &ingestpb.Rejection{Index: uint32(index), Code: pe.Code, Message: pe.Message}
+	}
+	return &ingestpb.Rejection{Index: uint32(index), Code: ingestpb.RejectCode_REJECT_CODE_PIPELINE_UNAVAILABLE, Message: err.Error()}
+}
+
+// Tag names reserved for internal use. Names starting with "__" are reserved
+// as well, they clash with labels such as Prometheus' __name__.
+var reservedTags = map[string]bool{
+	"time": true,
+}
+
+// Characters that separate the parts of a series key, see memtable.Key.
+const (
+	keySeparator   = "|"
+	valueSeparator = "="
+)
+
+// Validator checks points before they enter the pipeline.
+type Validator struct {
+	// oldest accepted timestamp in unix nanoseconds
+	MinTimestamp int64
+	// how far behind the server clock a timestamp may be, 0 disables the check
+	MaxAge time.Duration
+	// how far ahead of the server clock a timestamp may be, 0 disables the check
+	MaxFuture time.Duration
+}
+
+func NewValidator() *Validator {
+	return &Validator{
+		MinTimestamp: 0,
+		MaxFuture:    time.Hour,
+	}
+}
+
+// Validate returns a *PointError if the point can't be stored.
+func (v *Validator) Validate(point *ingestpb.Point) error {
+	if point == nil {
+		return rejectf(ingestpb.RejectCode_REJECT_CODE_MISSING_POINT, "no point provided")
+	}
+
+	if point.Measurement == "" {
+		return rejectf(ingestpb.RejectCode_REJECT_CODE_MISSING_MEASUREMENT, "no measurement provided")
+	}
+	if strings.ContainsAny(point.Measurement, keySeparator+valueSeparator) {
+		return rejectf(ingestpb.RejectCode_REJECT_CODE_INVALID_CHARACTER, "measurement %q must not contain %q or %q", point.Measurement, keySeparator, valueSeparator)
+	}
+
+	if point.TimestampUnixNano < v.MinTimestamp {
+		return rejectf(ingestpb.RejectCode_REJECT_CODE_TIMESTAMP_OUT_OF_RANGE, "timestamp %d is before the oldest accepted timestamp %d", point.TimestampUnixNano, v.MinTimestamp)
+	}
+	if v.MaxAge > 0 {
+		if limit := time.Now().Add(-v.MaxAge).UnixNano(); point.TimestampUnixNano < limit {
+			return rejectf(ingestpb.RejectCode_REJECT_CODE_TIMESTAMP_OUT_OF_RANGE, "timestamp %d is older than the retention period of %s", point.TimestampUnixNano, v.MaxAge)
+		}
+	}
+	if v.MaxFuture > 0 {
+		if limit := time.Now().Add(v.MaxFuture).UnixNano(); point.TimestampUnixNano > limit {
+			return rejectf(ingestpb.RejectCode_REJECT_CODE_TIMESTAMP_OUT_OF_RANGE, "timestamp %d is more than %s in the future", point.TimestampUnixNano, v.MaxFuture)
+		}
+	}
+
+	if len(point.Fields) == 0 {
+		return rejectf(ingestpb.RejectCode_REJECT_CODE_NO_FIELDS, "point has no fields")
+	}
+	for k := range point.Fields {
+		if k == "" {
+			return rejectf(ingestpb.RejectCode_REJECT_CODE_NO_FIELDS, "field name must not be empty")
+		}
+	}
+
+	for k, val := range point.Tag {
+		if k == "" {
+			return rejectf(ingestpb.RejectCode_REJECT_CODE_RESERVED_TAG, "tag name must not be empty")
+		}
+		if reservedTags[k] || strings.HasPrefix(k, "__") {
+			return rejectf(ingestpb.RejectCode_REJECT_CODE_RESERVED_TAG, "tag name %q is reserved", k)
+		}
+		if strings.ContainsAny(k, keySeparator+valueSeparator) {
+			return rejectf(ingestpb.RejectCode_REJECT_CODE_INVALID_CHARACTER, "tag name %q must not contain %q or %q", k, keySeparator, valueSeparator)
+		}
+		if strings.Contains(val, keySeparator) {
+			return rejectf(ingestpb.RejectCode_REJECT_CODE_INVALID_CHARACTER, "value of tag %q must not contain %q", k, keySeparator)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package inspect
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	memtable "github.com/heyyakash/tickdb/internal/mem-table"
+	"github.com/heyyakash/tickdb/internal/sstable"
+	"github.com/heyyakash/tickdb/internal/wal"
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// Range is a count of points and the time range they span.
+type Range struct {
+	Points int64 `json:"points"`
+	// unix nanoseconds of the first and last point
+	First int64 `json:"first,omitempty"`
+	Last  int64 `json:"last,omitempty"`
+}
+
+func (r *Range) add(ts int64) {
+	if r.Points == 0 || ts < r.First {
+		r.First = ts
+	}
+	if r.Points == 0 || ts > r.Last {
+		r.Last = ts
+	}
+	r.Points++
+}
+
+func (r *Range) merge(o Range) {
+	if o.Points == 0 {
+		return
+	}
+	if r.Points == 0 || o.First < r.First {
+		r.First = o.First
+	}
+	if r.Points == 0 || o.Last > r.Last {
+		r.Last = o.Last
+	}
+	r.Points += o.Points
+}
+
+// FileSummary sums up one SSTable or WAL segment.
+type FileSummary struct {
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	Database string `json:"database"`
+	Bytes    int64  `json:"bytes"`
+	Series   int    `json:"series"`
+	Range
+	// closed WAL segments hold points already flushed to an SSTable, they
+	// are left out of the series totals
+	Flushed bool `json:"flushed,omitempty"`
+	// why the file was only read partly
+	Error string `json:"error,omitempty"`
+}
+
+// SeriesSummary sums up the points of a series in a database.
+type SeriesSummary struct {
+	Database string `json:"database"`
+	Key      string `json:"key"`
+	Files    int    `json:"files"`
+	Range
+}
+
+// Summary sums up the files at some paths and the series they hold.
+type Summary struct {
+	Files  []FileSummary   `json:"files"`
+	Series []SeriesSummary `json:"series"`
+}
+
+// Summarize reads the SSTables and WAL segments at paths. Files laid out
+// like a data directory, below databases/<name>, are counted in that
+// database, the others in the default one. Files that can't be read whole
+// are summed up as far as they can be.
+func Summarize(paths []string) (*Summary, error) {
+	files, err := Files(paths)
+	if err != nil {
+		return nil, err
+	}
+	summary := &Summary{Files: []FileSummary{}, Series: []SeriesSummary{}}
+	type seriesID struct{ db, key string }
+	series := map[seriesID]*SeriesSummary{}
+	for _, path := range files {
+		file, ranges := summarizeFile(path)
+		summary.Files = append(summary.Files, file)
+		if file.Flushed {
+			continue
+		}
+		for key, r := range ranges {
+			id := seriesID{file.Database, key}
+			s, ok := series[id]
+			if !ok {
+				s = &SeriesSummary{Database: file.Database, Key: key}
+				series[id] = s
+			}
+			s.Files++
+			s.merge(*r)
+		}
+	}
+	for _, s := range series {
+		summary.Series = append(summary.Series, *s)
+	}
+	sort.Slice(summary.Series, func(i, j int) bool {
+		a, b := summary.Series[i], summary.Series[j]
+		if a.Database != b.Database {
+			return a.Database < b.Database
+		}
+		return a.Key < b.Key
+	})
+	return summary, nil
+}
+
+// summarizeFile reads a file and returns its summary with the range of every
+// series in it
+func summarizeFile(path string) (FileSummary, map[string]*Range) {
+	file := FileSummary{Path: path, Kind: Kind(path), Database: database(path)}
+	if file.Kind == "" {
+		file.Kind = KindSSTable
+	}
+	ranges := map[string]*Range{}
+	point := func(key string, p *ingestpb.Point) {
+		r, ok := ranges[key]
+		if !ok {
+			r = &Range{}
+			ranges[key] = r
+		}
+		r.add(p.TimestampUnixNano)
+		file.add(p.TimestampUnixNano)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		file.Error = err.Error()
+		return file, ranges
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		file.Error = err.Error()
+		return file, ranges
+	}
+	file.Bytes = info.Size()
+
+	if file.Kind == KindWAL {
+		file.Flushed = strings.HasSuffix(path, ".closed.log")
+		_, err = wal.ScanSegment(f, func(_ int, _ int64, p *ingestpb.Point) error {
+			point(memtable.SeriesKey(p), p)
+			return nil
+		})
+	} else {
+		end := info.Size()
+		footer, footerErr := sstable.ReadFooter(f, info.Size())
+		if footerErr == nil {
+			end = footer.IndexOffset
+		}
+		_, err = sstable.ScanEntries(f, end, func(_ int64, _ int32, entry *sstable.SSTableEntry) error {
+			key := memtable.NormalizeKey(entry.Key)
+			for _, p := range entry.Value {
+				if p != nil {
+					point(key, p)
+				}
+			}
+			return nil
+		})
+		if footerErr != nil {
+			err = footerErr
+		}
+	}
+	if err != nil {
+		file.Error = err.Error()
+	}
+	file.Series = len(ranges)
+	return file, ranges
+}
+
+// database returns the name of the database a file of a data directory
+// belongs to
+func database(path string) string {
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(path)), "/")
+	for i := len(parts) - 3; i >= 0; i-- {
+		if parts[i] == "databases" {
+			return parts[i+1]
+		}
+	}
+	return "default"
+}
@@ -0,0 +1,240 @@
+// Package inspect reads SSTables and WAL segments straight from disk, without
+// a server, to check their structure and summarize what they hold.
+package inspect
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/heyyakash/tickdb/internal/backup"
+	memtable "github.com/heyyakash/tickdb/internal/mem-table"
+	"github.com/heyyakash/tickdb/internal/repair"
+	"github.com/heyyakash/tickdb/internal/sstable"
+	"github.com/heyyakash/tickdb/internal/wal"
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// Kinds of files inspect reads.
+const (
+	KindSSTable = "sstable"
+	KindWAL     = "wal"
+)
+
+// Problem is something wrong with a file.
+type Problem struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Kind returns the kind of the file at path from its name, or "" when it
+// isn't an SSTable or WAL segment.
+func Kind(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".sst"):
+		return KindSSTable
+	case strings.HasSuffix(path, ".log"):
+		return KindWAL
+	}
+	return ""
+}
+
+// Files expands paths into the SSTables and WAL segments they name, walking
+// directories. Files given by name are kept whatever their name, lost+found
+// is only walked when named.
+func Files(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && d.Name() == repair.LostFound && path != p {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && Kind(path) != "" {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Verify checks the structure of the SSTables and WAL segments at paths.
+// Directories holding a backup manifest also have the size and checksum of
+// every file listed in it checked.
+func Verify(paths []string) ([]Problem, int, error) {
+	files, err := Files(paths)
+	if err != nil {
+		return nil, 0, err
+	}
+	var problems []Problem
+	for _, p := range paths {
+		if _, err := os.Stat(filepath.Join(p, backup.ManifestFile)); err != nil {
+			continue
+		}
+		m, err := backup.ReadManifest(p)
+		if err != nil {
+			problems = append(problems, Problem{Path: p, Message: err.Error()})
+			continue
+		}
+		problems = append(problems, verifyManifest(p, m)...)
+	}
+	for _, f := range files {
+		var messages []string
+		if Kind(f) == KindWAL {
+			messages = VerifyWAL(f)
+		} else {
+			messages = VerifySSTable(f)
+		}
+		for _, m := range messages {
+			problems = append(problems, Problem{Path: f, Message: m})
+		}
+	}
+	return problems, len(files), nil
+}
+
+// VerifySSTable checks the length prefixes, footer and index of an SSTable,
+// that its entries are sorted by key and hold points of their series only.
+func VerifySSTable(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var problems []string
+	end := info.Size()
+	footer, err := sstable.ReadFooter(f, info.Size())
+	var index map[string]int64
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else {
+		end = footer.IndexOffset
+		if index, err = sstable.ReadIndexBlock(f, footer); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	entries := map[string]int64{}
+	last := ""
+	scanned, err := sstable.ScanEntries(f, end, func(offset int64, length int32, entry *sstable.SSTableEntry) error {
+		if _, ok := entries[entry.Key]; ok {
+			problems = append(problems, fmt.Sprintf("key %s at %d is repeated", entry.Key, offset))
+		} else if entry.Key < last {
+			problems = append(problems, fmt.Sprintf("key %s at %d is out of order", entry.Key, offset))
+		}
+		entries[entry.Key], last = offset, entry.Key
+		for i, p := range entry.Value {
+			if p == nil {
+				problems = append(problems, fmt.Sprintf("entry %s at %d has an empty point", entry.Key, offset))
+				break
+			}
+			if memtable.SeriesKey(p) != memtable.NormalizeKey(entry.Key) {
+				problems = append(problems, fmt.Sprintf("entry %s at %d holds point %d of series %s", entry.Key, offset, i, memtable.SeriesKey(p)))
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if index == nil {
+		return problems
+	}
+	// the index and entries are compared in map order
+	var mismatches []string
+	for key, offset := range index {
+		at, ok := entries[key]
+		switch {
+		case !ok && err != nil && offset >= scanned:
+			// past the entry the scan stopped at
+		case !ok:
+			mismatches = append(mismatches, fmt.Sprintf("index has key %s at %d, there is no such entry", key, offset))
+		case at != offset:
+			mismatches = append(mismatches, fmt.Sprintf("index has key %s at %d, the entry is at %d", key, offset, at))
+		}
+	}
+	for key, offset := range entries {
+		if _, ok := index[key]; !ok {
+			mismatches = append(mismatches, fmt.Sprintf("entry %s at %d is missing from the index", key, offset))
+		}
+	}
+	sort.Strings(mismatches)
+	return append(problems, mismatches...)
+}
+
+// VerifyWAL checks that every line of a WAL segment is a record.
+func VerifyWAL(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	defer f.Close()
+	if _, err := wal.ScanSegment(f, func(int, int64, *ingestpb.Point) error { return nil }); err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
+
+// verifyManifest checks the size and checksum of the files of a backup
+// directory, the ones stored in a base backup aren't there
+func verifyManifest(dir string, m *backup.Manifest) []Problem {
+	var problems []Problem
+	for _, db := range m.Databases {
+		for _, file := range db.Files {
+			if file.InBase {
+				continue
+			}
+			path := filepath.Join(dir, filepath.FromSlash(file.Path))
+			sum, n, err := checksum(path)
+			switch {
+			case err != nil:
+				problems = append(problems, Problem{Path: path, Message: err.Error()})
+			case n != file.Bytes:
+				problems = append(problems, Problem{Path: path, Message: fmt.Sprintf("size is %d bytes, the manifest has %d", n, file.Bytes)})
+			case sum != file.SHA256:
+				problems = append(problems, Problem{Path: path, Message: fmt.Sprintf("sha256 is %s, the manifest has %s", sum, file.SHA256)})
+			}
+		}
+	}
+	return problems
+}
+
+func checksum(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
@@ -0,0 +1,206 @@
+// Package limits implements the write rate limits and storage quotas of
+// databases and API tokens.
+package limits
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Limits configures the writes a database accepts. Zero disables a limit.
+type Limits struct {
+	PointsPerSecond int `json:"points_per_second,omitempty"`
+	BytesPerSecond  int `json:"bytes_per_second,omitempty"`
+	MaxSeries       int `json:"max_series,omitempty"`
+	// series each measurement of the database may hold
+	MaxSeriesPerMeasurement int   `json:"max_series_per_measurement,omitempty"`
+	MaxStoredBytes          int64 `json:"max_stored_bytes,omitempty"`
+}
+
+// Validate rejects negative limits.
+func (l Limits) Validate() error {
+	if l.PointsPerSecond < 0 || l.BytesPerSecond < 0 || l.MaxSeries < 0 || l.MaxSeriesPerMeasurement < 0 || l.MaxStoredBytes < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	return nil
+}
+
+// Error names the limit a write ran into.
+type Error struct {
+	// who the limit belongs to, e.g. `database "metrics"` or `token "telegraf"`
+	Owner string
+	// points rate, bytes rate, series or stored bytes
+	Limit string
+	Value int64
+	// quotas stay exceeded until data is removed, rate limits pass
+	Quota bool
+	// when a rate limited write may be retried, 0 if the point is larger
+	// than the limit allows in a second
+	RetryAfter time.Duration
+	// for series quotas, the tag with the most distinct values, the likely
+	// cause. TagMeasurement is set when the quota covers every measurement.
+	Tag            string
+	TagMeasurement string
+	TagValues      int
+}
+
+func (e *Error) Error() string {
+	switch e.Limit {
+	case "points rate":
+		return fmt.Sprintf("%s exceeded its rate limit of %d points/s", e.Owner, e.Value)
+	case "bytes rate":
+		return fmt.Sprintf("%s exceeded its rate limit of %d bytes/s", e.Owner, e.Value)
+	case "stored bytes":
+		return fmt.Sprintf("%s reached its quota of %d stored bytes", e.Owner, e.Value)
+	default:
+		msg := fmt.Sprintf("%s reached its quota of %d %s", e.Owner, e.Value, e.Limit)
+		switch {
+		case e.Tag != "" && e.TagMeasurement != "":
+			msg += fmt.Sprintf(", tag %q of measurement %q has the most values (%d)", e.Tag, e.TagMeasurement, e.TagValues)
+		case e.Tag != "":
+			msg += fmt.Sprintf(", tag %q has the most values (%d)", e.Tag, e.TagValues)
+		}
+		return msg
+	}
+}
+
+// bucket is a token bucket holding at most one second of its rate.
+type bucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate int, now time.Time) *bucket {
+	if rate <= 0 {
+		return nil
+	}
+	return &bucket{rate: float64(rate), tokens: float64(rate), last: now}
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens = min(b.rate, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// wait returns how long until n tokens are available, -1 if they never are.
+func (b *bucket) wait(n float64) time.Duration {
+	if b == nil || n <= b.tokens {
+		return 0
+	}
+	if n > b.rate {
+		return -1
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Rate limits points and bytes per second.
+type Rate struct {
+	owner           string
+	pointsPerSecond int
+	bytesPerSecond  int
+
+	mu     sync.Mutex
+	points *bucket
+	bytes  *bucket
+}
+
+// NewRate returns a rate limit for owner, nil when both rates are 0.
+func NewRate(owner string, pointsPerSecond, bytesPerSecond int) *Rate {
+	if pointsPerSecond <= 0 && bytesPerSecond <= 0 {
+		return nil
+	}
+	now := time.Now()
+	return &Rate{
+		owner:           owner,
+		pointsPerSecond: pointsPerSecond,
+		bytesPerSecond:  bytesPerSecond,
+		points:          newBucket(pointsPerSecond, now),
+		bytes:           newBucket(bytesPerSecond, now),
+	}
+}
+
+// Allow takes one point of size bytes from the rate, or returns an *Error
+// naming the exceeded limit. Nothing is taken when it fails. A nil Rate
+// allows everything.
+func (r *Rate) Allow(size int) error {
+	return Allow(size, r)
+}
+
+// Allow takes one point of size bytes from every rate, or returns an *Error
+// naming the first exceeded limit. Nothing is taken from any rate unless they
+// all allow the point. Nil rates allow everything. Callers pass the rates in
+// the same order, they are locked in that order.
+func Allow(size int, rates ...*Rate) error {
+	now := time.Now()
+	var locked []*Rate
+	for _, r := range rates {
+		if r == nil || slices.Contains(locked, r) {
+			continue
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		locked = append(locked, r)
+		if err := r.check(size, now); err != nil {
+			return err
+		}
+	}
+	for _, r := range locked {
+		if r.points != nil {
+			r.points.tokens--
+		}
+		if r.bytes != nil {
+			r.bytes.tokens -= float64(size)
+		}
+	}
+	return nil
+}
+
+// check refills the buckets and reports whether a point of size bytes can
+// be taken, the caller holds the lock
+func (r *Rate) check(size int, now time.Time) error {
+	if r.points != nil {
+		r.points.refill(now)
+	}
+	if r.bytes != nil {
+		r.bytes.refill(now)
+	}
+	if wait := r.points.wait(1); wait != 0 {
+		return &Error{Owner: r.owner, Limit: "points rate", Value: int64(r.pointsPerSecond), RetryAfter: max(wait, 0)}
+	}
+	if wait := r.bytes.wait(float64(size)); wait != 0 {
+		return &Error{Owner: r.owner, Limit: "bytes rate", Value: int64(r.bytesPerSecond), RetryAfter: max(wait, 0)}
+	}
+	return nil
+}
+
+// Registry keeps the rate limits of API tokens, so every request made with a
+// token shares the same buckets.
+type Registry struct {
+	mu    sync.Mutex
+	rates map[string]*Rate
+}
+
+func NewRegistry() *Registry {
+	return &Registry{rates: make(map[string]*Rate)}
+}
+
+// Get returns the rate limit of the token with id. It is replaced when the
+// rates have changed and is nil when there are none.
+func (r *Registry) Get(id, owner string, pointsPerSecond, bytesPerSecond int) *Rate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if pointsPerSecond <= 0 && bytesPerSecond <= 0 {
+		delete(r.rates, id)
+		return nil
+	}
+	rate, ok := r.rates[id]
+	if !ok || rate.pointsPerSecond != pointsPerSecond || rate.bytesPerSecond != bytesPerSecond {
+		rate = NewRate(owner, pointsPerSecond, bytesPerSecond)
+		r.rates[id] = rate
+	}
+	return rate
+}
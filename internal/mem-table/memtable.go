@@ -1,21 +1,27 @@
 package memtable
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
 	"sync"
 
+	"github.com/heyyakash/tickdb/internal/metrics"
 	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
 )
 
 type MemTableService struct {
 	MemTable   map[string][]*ingestpb.Point
-	PointCount uint16
+	PointCount int
+	logger     *slog.Logger
 	sync.RWMutex
 }
 
-func NewMemTableService(memtable map[string][]*ingestpb.Point) *MemTableService {
+func NewMemTableService(memtable map[string][]*ingestpb.Point, logger *slog.Logger) *MemTableService {
 	return &MemTableService{
 		MemTable: memtable,
+		logger:   logger,
 	}
 }
 
@@ -23,6 +29,9 @@ func (m *MemTableService) FlushMemTable() {
 	m.RWMutex.Lock()
 	defer m.RWMutex.Unlock()
 
+	// the gauges are shared by every database, so they move by deltas
+	metrics.MemTablePoints.Sub(float64(m.PointCount))
+	metrics.MemTableSeries.Sub(float64(len(m.MemTable)))
 	m.MemTable = make(map[string][]*ingestpb.Point)
 	m.PointCount = 0
 }
@@ -31,26 +40,115 @@ func (m *MemTableService) AddToMemTable(point *ingestpb.Point) {
 	m.RWMutex.Lock()
 	defer m.RWMutex.Unlock()
 
-	tagString := ""
-	for k, v := range point.Tag {
-		tagString += "|" + k + "=" + v
+	key := SeriesKey(point)
+	if _, ok := m.MemTable[key]; !ok {
+		metrics.MemTableSeries.Inc()
 	}
-	key := point.Measurement + tagString
 	m.MemTable[key] = append(m.MemTable[key], point)
 	// log.Print("New Memtable\n")
 	// m.LogMemTable()
 	m.PointCount += 1
+	metrics.MemTablePoints.Inc()
+}
+
+// SeriesKey builds the memtable key for a point. Tags are sorted so the same
+// series always maps to the same key.
+func SeriesKey(point *ingestpb.Point) string {
+	return Key(point.Measurement, point.Tag)
+}
+
+// Key builds a series key from a measurement and its tags.
+func Key(measurement string, tags map[string]string) string {
+	tagKeys := make([]string, 0, len(tags))
+	for k := range tags {
+		tagKeys = append(tagKeys, k)
+	}
+	sort.Strings(tagKeys)
+
+	tagString := ""
+	for _, k := range tagKeys {
+		tagString += "|" + k + "=" + tags[k]
+	}
+	return measurement + tagString
+}
+
+// NormalizeKey sorts the tags of a series key given by a client, so keys
+// written in any tag order find the series. Tags are sorted by name like Key
+// does, sorting whole k=v pairs differs when a tag name is a prefix of
+// another.
+func NormalizeKey(key string) string {
+	parts := strings.Split(key, "|")
+	tags := make(map[string]string, len(parts)-1)
+	for _, part := range parts[1:] {
+		name, value, _ := strings.Cut(part, "=")
+		tags[name] = value
+	}
+	return Key(parts[0], tags)
+}
+
+// Snapshot returns a copy of the memtable that is safe to read without
+// holding the lock.
+func (m *MemTableService) Snapshot() map[string][]*ingestpb.Point {
+	m.RWMutex.RLock()
+	defer m.RWMutex.RUnlock()
+
+	snapshot := make(map[string][]*ingestpb.Point, len(m.MemTable))
+	for k, v := range m.MemTable {
+		snapshot[k] = append([]*ingestpb.Point(nil), v...)
+	}
+	return snapshot
+}
+
+// SnapshotSeries is Snapshot limited to the series keep accepts.
+func (m *MemTableService) SnapshotSeries(keep func(key string) bool) map[string][]*ingestpb.Point {
+	m.RWMutex.RLock()
+	defer m.RWMutex.RUnlock()
+
+	snapshot := make(map[string][]*ingestpb.Point)
+	for k, v := range m.MemTable {
+		if keep(k) {
+			snapshot[k] = append([]*ingestpb.Point(nil), v...)
+		}
+	}
+	return snapshot
+}
+
+// CountSeries returns the number of series keys in the memtable that keep
+// accepts, without copying their points.
+func (m *MemTableService) CountSeries(keep func(key string) bool) int {
+	m.RWMutex.RLock()
+	defer m.RWMutex.RUnlock()
+
+	n := 0
+	for k := range m.MemTable {
+		if keep(k) {
+			n++
+		}
+	}
+	return n
 }
 
+// LogMemTable dumps every series in the memtable at debug level.
 func (m *MemTableService) LogMemTable() {
-	m.RWMutex.Lock()
-	defer m.RWMutex.Unlock()
+	if !m.logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+
+	m.RWMutex.RLock()
+	defer m.RWMutex.RUnlock()
 
 	for k, v := range m.MemTable {
-		log.Println(k, v)
+		m.logger.Debug("Memtable series", "key", k, "points", v)
 	}
 }
 
-func (m *MemTableService) CountPoints() uint16 {
+// Stats returns the number of points and series keys in the memtable.
+func (m *MemTableService) Stats() (points int, series int) {
+	m.RWMutex.RLock()
+	defer m.RWMutex.RUnlock()
+	return m.PointCount, len(m.MemTable)
+}
+
+func (m *MemTableService) CountPoints() int {
 	return m.PointCount
 }
@@ -0,0 +1,33 @@
+package memtable
+
+import (
+	"testing"
+
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+func TestNormalizeKeyMatchesSeriesKey(t *testing.T) {
+	cases := []struct {
+		key         string
+		measurement string
+		tags        map[string]string
+	}{
+		{"cpu", "cpu", nil},
+		{"cpu|host=a", "cpu", map[string]string{"host": "a"}},
+		{"cpu|host=a|dc=eu", "cpu", map[string]string{"host": "a", "dc": "eu"}},
+		// tag names that are a prefix of another sort before it
+		{"cpu|host2=b|host=a", "cpu", map[string]string{"host": "a", "host2": "b"}},
+		{"cpu|host=a|host2=b", "cpu", map[string]string{"host": "a", "host2": "b"}},
+		{"http|service.name=api|service=web", "http", map[string]string{"service": "web", "service.name": "api"}},
+		{"q|expr=a=b|e=c", "q", map[string]string{"expr": "a=b", "e": "c"}},
+	}
+	for _, c := range cases {
+		want := SeriesKey(&ingestpb.Point{Measurement: c.measurement, Tag: c.tags})
+		if got := NormalizeKey(c.key); got != want {
+			t.Errorf("NormalizeKey(%q) = %q, SeriesKey gives %q", c.key, got, want)
+		}
+		if got := NormalizeKey(want); got != want {
+			t.Errorf("NormalizeKey(%q) = %q, want it unchanged", want, got)
+		}
+	}
+}
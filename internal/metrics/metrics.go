@@ -0,0 +1,95 @@
+// Package metrics holds the Prometheus collectors for TickDB's internal
+// telemetry. They are registered with the default registry and exposed on
+// /metrics by the query server.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "tickdb"
+
+var (
+	IngestAccepted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ingest_points_accepted_total",
+		Help:      "Points accepted into the ingest pipeline.",
+	})
+
+	IngestRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ingest_points_rejected_total",
+		Help:      "Points rejected at ingest, by reason.",
+	}, []string{"reason"})
+
+	PipelineDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pipeline_queue_depth",
+		Help:      "Points waiting in the ingest pipeline channel.",
+	})
+
+	WALAppendDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "wal_append_duration_seconds",
+		Help:      "Time to write a record to the WAL, without the fsync.",
+		Buckets:   prometheus.ExponentialBuckets(0.00001, 4, 10),
+	})
+
+	WALFsyncDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "wal_fsync_duration_seconds",
+		Help:      "Time to fsync the WAL after an append.",
+		Buckets:   prometheus.ExponentialBuckets(0.00001, 4, 10),
+	})
+
+	MemTablePoints = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "memtable_points",
+		Help:      "Points held in the memtable.",
+	})
+
+	MemTableSeries = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "memtable_series",
+		Help:      "Series keys held in the memtable.",
+	})
+
+	FlushDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sstable_flush_duration_seconds",
+		Help:      "Time to flush the memtable to an SSTable.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+	})
+
+	SSTableCount = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sstables",
+		Help:      "SSTable files on disk.",
+	})
+
+	SSTableBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sstable_bytes",
+		Help:      "Total size of the SSTable files on disk.",
+	})
+
+	StorageErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_errors_total",
+		Help:      "Failed storage operations, by operation.",
+	}, []string{"op"})
+
+	ReadOnly = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "read_only",
+		Help:      "Databases refusing writes because of a storage failure.",
+	})
+
+	QueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "query_duration_seconds",
+		Help:      "Query latency by endpoint.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint"})
+)
@@ -0,0 +1,213 @@
+// Package otlp converts OpenTelemetry metrics into TickDB points.
+//
+// Naming scheme:
+//
+//   - Gauges and sums become one point per data point. The measurement is the
+//     metric name and the value is stored in the "value" field.
+//   - Histograms follow the Prometheus convention. Each bucket becomes a
+//     point in <name>_bucket with the cumulative count in "value" and the
+//     upper bound in the "le" tag ("+Inf" for the last bucket). The sum and
+//     the count are written to <name>_sum and <name>_count.
+//   - Exponential histograms only write <name>_sum and <name>_count.
+//   - Summaries write every quantile to <name> with a "quantile" tag, plus
+//     <name>_sum and <name>_count.
+//
+// Resource attributes, scope attributes and data point attributes all become
+// tags. On a key collision the data point attribute wins over the scope
+// attribute, which wins over the resource attribute. Data points with a NaN
+// or infinite value are rejected.
+package otlp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+const (
+	bucketSuffix = "_bucket"
+	sumSuffix    = "_sum"
+	countSuffix  = "_count"
+	leTag        = "le"
+	quantileTag  = "quantile"
+	valueField   = "value"
+	posInf       = "+Inf"
+)
+
+// DataPoint holds the TickDB points an OTLP data point became, so rejections
+// can be counted the way the client sent them. Err is set instead when the
+// data point can't be stored.
+type DataPoint struct {
+	Points []*ingestpb.Point
+	Err    error
+}
+
+// Convert turns every data point of an export request into TickDB points.
+func Convert(req *colmetricspb.ExportMetricsServiceRequest) []DataPoint {
+	var points []DataPoint
+	for _, rm := range req.GetResourceMetrics() {
+		resourceTags := attributesToTags(nil, rm.GetResource().GetAttributes())
+		for _, sm := range rm.GetScopeMetrics() {
+			scopeTags := attributesToTags(resourceTags, sm.GetScope().GetAttributes())
+			for _, metric := range sm.GetMetrics() {
+				points = append(points, convertMetric(metric, scopeTags)...)
+			}
+		}
+	}
+	return points
+}
+
+func convertMetric(metric *metricspb.Metric, baseTags map[string]string) []DataPoint {
+	name := metric.GetName()
+	var points []DataPoint
+
+	switch data := metric.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		for _, dp := range data.Gauge.GetDataPoints() {
+			points = append(points, dataPoint(name, numberPoint(name, dp, baseTags)))
+		}
+	case *metricspb.Metric_Sum:
+		for _, dp := range data.Sum.GetDataPoints() {
+			points = append(points, dataPoint(name, numberPoint(name, dp, baseTags)))
+		}
+	case *metricspb.Metric_Histogram:
+		for _, dp := range data.Histogram.GetDataPoints() {
+			tags := attributesToTags(baseTags, dp.GetAttributes())
+			ts := int64(dp.GetTimeUnixNano())
+
+			var dpPoints []*ingestpb.Point
+			var cumulative uint64
+			bounds := dp.GetExplicitBounds()
+			for i, count := range dp.GetBucketCounts() {
+				cumulative += count
+				le := posInf
+				if i < len(bounds) {
+					le = formatFloat(bounds[i])
+				}
+				bucketTags := copyTags(tags)
+				bucketTags[leTag] = le
+				dpPoints = append(dpPoints, newPoint(name+bucketSuffix, ts, bucketTags, strconv.FormatUint(cumulative, 10)))
+			}
+			points = append(points, dataPoint(name, append(dpPoints,
+				newPoint(name+sumSuffix, ts, tags, formatFloat(dp.GetSum())),
+				newPoint(name+countSuffix, ts, copyTags(tags), strconv.FormatUint(dp.GetCount(), 10)),
+			)...))
+		}
+	case *metricspb.Metric_ExponentialHistogram:
+		for _, dp := range data.ExponentialHistogram.GetDataPoints() {
+			tags := attributesToTags(baseTags, dp.GetAttributes())
+			ts := int64(dp.GetTimeUnixNano())
+			points = append(points, dataPoint(name,
+				newPoint(name+sumSuffix, ts, tags, formatFloat(dp.GetSum())),
+				newPoint(name+countSuffix, ts, copyTags(tags), strconv.FormatUint(dp.GetCount(), 10)),
+			))
+		}
+	case *metricspb.Metric_Summary:
+		for _, dp := range data.Summary.GetDataPoints() {
+			tags := attributesToTags(baseTags, dp.GetAttributes())
+			ts := int64(dp.GetTimeUnixNano())
+			var dpPoints []*ingestpb.Point
+			for _, q := range dp.GetQuantileValues() {
+				quantileTags := copyTags(tags)
+				quantileTags[quantileTag] = formatFloat(q.GetQuantile())
+				dpPoints = append(dpPoints, newPoint(name, ts, quantileTags, formatFloat(q.GetValue())))
+			}
+			points = append(points, dataPoint(name, append(dpPoints,
+				newPoint(name+sumSuffix, ts, tags, formatFloat(dp.GetSum())),
+				newPoint(name+countSuffix, ts, copyTags(tags), strconv.FormatUint(dp.GetCount(), 10)),
+			)...))
+		}
+	}
+	return points
+}
+
+// dataPoint groups the points of a data point, rejecting it when one of its
+// values is NaN or infinite, which can't be queried
+func dataPoint(name string, points ...*ingestpb.Point) DataPoint {
+	for _, p := range points {
+		value := p.Fields[valueField]
+		if v, err := strconv.ParseFloat(value, 64); err == nil && (math.IsNaN(v) || math.IsInf(v, 0)) {
+			return DataPoint{Err: fmt.Errorf("data point of metric %s has the non finite value %s", name, value)}
+		}
+	}
+	return DataPoint{Points: points}
+}
+
+func numberPoint(name string, dp *metricspb.NumberDataPoint, baseTags map[string]string) *ingestpb.Point {
+	var value string
+	switch v := dp.GetValue().(type) {
+	case *metricspb.NumberDataPoint_AsInt:
+		value = strconv.FormatInt(v.AsInt, 10)
+	case *metricspb.NumberDataPoint_AsDouble:
+		value = formatFloat(v.AsDouble)
+	}
+	return newPoint(name, int64(dp.GetTimeUnixNano()), attributesToTags(baseTags, dp.GetAttributes()), value)
+}
+
+func newPoint(measurement string, ts int64, tags map[string]string, value string) *ingestpb.Point {
+	return &ingestpb.Point{
+		Measurement:       measurement,
+		TimestampUnixNano: ts,
+		Tag:               tags,
+		Fields:            map[string]string{valueField: value},
+	}
+}
+
+// attributesToTags copies base and adds attrs on top of it.
+func attributesToTags(base map[string]string, attrs []*commonpb.KeyValue) map[string]string {
+	tags := copyTags(base)
+	for _, kv := range attrs {
+		tags[kv.GetKey()] = anyValueToString(kv.GetValue())
+	}
+	return tags
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	out := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		out[k] = v
+	}
+	return out
+}
+
+func anyValueToString(v *commonpb.AnyValue) string {
+	switch val := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return val.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(val.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(val.IntValue, 10)
+	case *commonpb.AnyValue_DoubleValue:
+		return formatFloat(val.DoubleValue)
+	case *commonpb.AnyValue_BytesValue:
+		return hex.EncodeToString(val.BytesValue)
+	case *commonpb.AnyValue_ArrayValue:
+		parts := make([]string, 0, len(val.ArrayValue.GetValues()))
+		for _, item := range val.ArrayValue.GetValues() {
+			parts = append(parts, anyValueToString(item))
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	case *commonpb.AnyValue_KvlistValue:
+		parts := make([]string, 0, len(val.KvlistValue.GetValues()))
+		for _, kv := range val.KvlistValue.GetValues() {
+			parts = append(parts, kv.GetKey()+":"+anyValueToString(kv.GetValue()))
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	}
+	return ""
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return posInf
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
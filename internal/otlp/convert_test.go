@@ -0,0 +1,155 @@
+package otlp
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const ts = 1700000000000000000
+
+func attr(k, v string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: k, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}}
+}
+
+func request(metrics ...*metricspb.Metric) *colmetricspb.ExportMetricsServiceRequest {
+	return &colmetricspb.ExportMetricsServiceRequest{ResourceMetrics: []*metricspb.ResourceMetrics{{
+		Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{attr("host", "a"), attr("dc", "eu")}},
+		ScopeMetrics: []*metricspb.ScopeMetrics{{
+			Scope:   &commonpb.InstrumentationScope{Attributes: []*commonpb.KeyValue{attr("dc", "us")}},
+			Metrics: metrics,
+		}},
+	}}}
+}
+
+func gauge(name string, values ...float64) *metricspb.Metric {
+	g := &metricspb.Gauge{}
+	for _, v := range values {
+		g.DataPoints = append(g.DataPoints, &metricspb.NumberDataPoint{TimeUnixNano: ts, Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: v}})
+	}
+	return &metricspb.Metric{Name: name, Data: &metricspb.Metric_Gauge{Gauge: g}}
+}
+
+func point(measurement string, tags map[string]string, value string) *ingestpb.Point {
+	return &ingestpb.Point{Measurement: measurement, TimestampUnixNano: ts, Tag: tags, Fields: map[string]string{valueField: value}}
+}
+
+// withTags returns the tags every point gets from the request, plus extra
+func withTags(extra ...string) map[string]string {
+	tags := map[string]string{"host": "a", "dc": "us"}
+	for i := 0; i < len(extra); i += 2 {
+		tags[extra[i]] = extra[i+1]
+	}
+	return tags
+}
+
+func checkPoints(t *testing.T, got DataPoint, want ...*ingestpb.Point) {
+	t.Helper()
+	if got.Err != nil {
+		t.Fatalf("data point rejected: %v", got.Err)
+	}
+	if len(got.Points) != len(want) {
+		t.Fatalf("data point became %d points, want %d: %v", len(got.Points), len(want), got.Points)
+	}
+	for i := range want {
+		if !proto.Equal(got.Points[i], want[i]) {
+			t.Errorf("point %d is %v, want %v", i, got.Points[i], want[i])
+		}
+	}
+}
+
+func TestConvertNumbers(t *testing.T) {
+	sum := &metricspb.Metric{Name: "requests", Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{DataPoints: []*metricspb.NumberDataPoint{{
+		TimeUnixNano: ts,
+		Attributes:   []*commonpb.KeyValue{attr("host", "b")},
+		Value:        &metricspb.NumberDataPoint_AsInt{AsInt: 42},
+	}}}}}
+	got := Convert(request(gauge("temp", 21.5, -3), sum))
+	if len(got) != 3 {
+		t.Fatalf("got %d data points, want 3", len(got))
+	}
+	checkPoints(t, got[0], point("temp", withTags(), "21.5"))
+	checkPoints(t, got[1], point("temp", withTags(), "-3"))
+	// the data point attribute wins over the resource attribute
+	checkPoints(t, got[2], point("requests", withTags("host", "b"), "42"))
+}
+
+func TestConvertHistogram(t *testing.T) {
+	histogram := &metricspb.Metric{Name: "latency", Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{DataPoints: []*metricspb.HistogramDataPoint{{
+		TimeUnixNano:   ts,
+		Count:          6,
+		Sum:            proto.Float64(4.5),
+		BucketCounts:   []uint64{1, 2, 3},
+		ExplicitBounds: []float64{0.5, 1},
+	}}}}}
+	got := Convert(request(histogram))
+	if len(got) != 1 {
+		t.Fatalf("got %d data points, want 1", len(got))
+	}
+	checkPoints(t, got[0],
+		point("latency_bucket", withTags("le", "0.5"), "1"),
+		point("latency_bucket", withTags("le", "1"), "3"),
+		point("latency_bucket", withTags("le", "+Inf"), "6"),
+		point("latency_sum", withTags(), "4.5"),
+		point("latency_count", withTags(), "6"),
+	)
+}
+
+func TestConvertSummary(t *testing.T) {
+	summary := &metricspb.Metric{Name: "rpc", Data: &metricspb.Metric_Summary{Summary: &metricspb.Summary{DataPoints: []*metricspb.SummaryDataPoint{{
+		TimeUnixNano:   ts,
+		Count:          10,
+		Sum:            20,
+		QuantileValues: []*metricspb.SummaryDataPoint_ValueAtQuantile{{Quantile: 0.5, Value: 1.5}, {Quantile: 0.99, Value: 9}},
+	}}}}}
+	exponential := &metricspb.Metric{Name: "size", Data: &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: &metricspb.ExponentialHistogram{DataPoints: []*metricspb.ExponentialHistogramDataPoint{{
+		TimeUnixNano: ts,
+		Count:        3,
+		Sum:          proto.Float64(7),
+	}}}}}
+	got := Convert(request(summary, exponential))
+	if len(got) != 2 {
+		t.Fatalf("got %d data points, want 2", len(got))
+	}
+	checkPoints(t, got[0],
+		point("rpc", withTags("quantile", "0.5"), "1.5"),
+		point("rpc", withTags("quantile", "0.99"), "9"),
+		point("rpc_sum", withTags(), "20"),
+		point("rpc_count", withTags(), "10"),
+	)
+	checkPoints(t, got[1], point("size_sum", withTags(), "7"), point("size_count", withTags(), "3"))
+}
+
+func TestConvertRejectsNonFinite(t *testing.T) {
+	histogram := &metricspb.Metric{Name: "latency", Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{DataPoints: []*metricspb.HistogramDataPoint{{
+		TimeUnixNano: ts,
+		Count:        1,
+		Sum:          proto.Float64(math.Inf(1)),
+		BucketCounts: []uint64{1},
+	}}}}}
+	summary := &metricspb.Metric{Name: "rpc", Data: &metricspb.Metric_Summary{Summary: &metricspb.Summary{DataPoints: []*metricspb.SummaryDataPoint{{
+		TimeUnixNano:   ts,
+		QuantileValues: []*metricspb.SummaryDataPoint_ValueAtQuantile{{Quantile: 0.5, Value: math.Inf(-1)}},
+	}}}}}
+	got := Convert(request(gauge("temp", math.NaN(), 1), histogram, summary))
+	if len(got) != 4 {
+		t.Fatalf("got %d data points, want 4", len(got))
+	}
+	for _, i := range []int{0, 2, 3} {
+		if got[i].Err == nil || !strings.Contains(got[i].Err.Error(), "non finite") {
+			t.Errorf("data point %d: error = %v, want a non finite value", i, got[i].Err)
+		}
+		if len(got[i].Points) != 0 {
+			t.Errorf("rejected data point %d has points %v", i, got[i].Points)
+		}
+	}
+	// the other data point of the gauge is kept
+	checkPoints(t, got[1], point("temp", withTags(), "1"))
+}
@@ -0,0 +1,371 @@
+package promql
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/heyyakash/tickdb/internal/auth"
+	"github.com/heyyakash/tickdb/internal/query"
+	seriesindex "github.com/heyyakash/tickdb/internal/series-index"
+)
+
+// DefaultLookbackDelta is how far back an instant selector looks for the
+// latest sample, same as Prometheus.
+const DefaultLookbackDelta = 5 * time.Minute
+
+// Sample is a single value at a unix millisecond timestamp.
+type Sample struct {
+	T int64
+	V float64
+}
+
+// Series is a labelled list of samples sorted by timestamp.
+type Series struct {
+	Labels  Labels
+	Samples []Sample
+}
+
+// Engine evaluates PromQL expressions against TickDB storage. Every numeric
+// field of a point becomes its own series, named with MetricName and
+// labelled with the point tags.
+type Engine struct {
+	q *query.Engine
+	// series stored in the database, label names are looked up in it
+	series        *seriesindex.Index
+	LookbackDelta time.Duration
+}
+
+func NewEngine(q *query.Engine, series *seriesindex.Index) *Engine {
+	return &Engine{
+		q:             q,
+		series:        series,
+		LookbackDelta: DefaultLookbackDelta,
+	}
+}
+
+// matcher returns the storage matcher of a selector, which applies the tag
+// matchers and skips the measurements no metric name matcher can accept
+func matcher(matchers []*LabelMatcher) query.Matcher {
+	var tagMatchers []*LabelMatcher
+	for _, m := range matchers {
+		if m.Name != MetricNameLabel {
+			tagMatchers = append(tagMatchers, m)
+		}
+	}
+	return func(measurement string, tags map[string]string) bool {
+		return matchMeasurement(matchers, measurement) && matchLabels(tagMatchers, tags)
+	}
+}
+
+// Select returns the raw samples of every series matching matchers between
+// start and end.
+func (e *Engine) Select(ctx context.Context, matchers []*LabelMatcher, start, end time.Time) ([]*Series, error) {
+	// tag and measurement matching is done before the fields are expanded
+	stored, err := e.q.Select(ctx, matcher(matchers), start.UnixNano(), end.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Series
+	for _, s := range stored {
+		byName := make(map[string]*Series)
+		for _, point := range s.Points {
+			for field, raw := range point.Fields {
+				v, err := strconv.ParseFloat(raw, 64)
+				if err != nil {
+					continue
+				}
+				name := MetricName(point.Measurement, field)
+				series, ok := byName[name]
+				if !ok {
+					labels := Labels{MetricNameLabel: name}
+					for k, v := range s.Tags {
+						labels[k] = v
+					}
+					if !matchLabels(matchers, labels) {
+						continue
+					}
+					series = &Series{Labels: labels}
+					byName[name] = series
+					result = append(result, series)
+				}
+				series.Samples = append(series.Samples, Sample{T: point.TimestampUnixNano / int64(time.Millisecond), V: v})
+			}
+		}
+	}
+
+	sortSeries(result)
+	return result, nil
+}
+
+// SeriesLabels returns the labels of every series matching matchers that has
+// samples between start and end. The points are read a series at a time to
+// find its numeric fields, they aren't held in memory.
+func (e *Engine) SeriesLabels(ctx context.Context, matchers []*LabelMatcher, start, end time.Time) ([]Labels, error) {
+	var result []Labels
+	err := e.q.Each(ctx, matcher(matchers), start.UnixNano(), end.UnixNano(), func(s *query.Series, points query.PointIterator) error {
+		seen := make(map[string]bool)
+		for {
+			point, err := points.Next()
+			if err != nil || point == nil {
+				return err
+			}
+			for field, raw := range point.Fields {
+				name := MetricName(point.Measurement, field)
+				if seen[name] {
+					continue
+				}
+				if _, err := strconv.ParseFloat(raw, 64); err != nil {
+					continue
+				}
+				seen[name] = true
+				labels := Labels{MetricNameLabel: name}
+				for k, v := range s.Tags {
+					labels[k] = v
+				}
+				if matchLabels(matchers, labels) {
+					result = append(result, labels)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].String() < result[j].String() })
+	return result, nil
+}
+
+// LabelNames returns the label names of the series matching any of the
+// selectors, or of every series when there are none. They are looked up in
+// the series index without reading points, so the time range and the fields
+// of the series aren't taken into account: a metric name matcher only
+// narrows down the measurements.
+func (e *Engine) LabelNames(ctx context.Context, selectors [][]*LabelMatcher) []string {
+	allow := func(string) bool { return true }
+	if token := auth.FromContext(ctx); token != nil && len(token.Measurements) > 0 {
+		allow = token.AllowsMeasurement
+	}
+	names := make(map[string]struct{})
+	add := func(tags map[string]string) {
+		names[MetricNameLabel] = struct{}{}
+		for k := range tags {
+			names[k] = struct{}{}
+		}
+	}
+
+	if len(selectors) == 0 {
+		for _, m := range e.series.Measurements(allow) {
+			names[MetricNameLabel] = struct{}{}
+			for _, tag := range m.Tags {
+				names[tag.Tag] = struct{}{}
+			}
+		}
+	} else {
+		matchers := make([]query.Matcher, len(selectors))
+		for i, selector := range selectors {
+			matchers[i] = matcher(selector)
+		}
+		for _, key := range e.series.Keys() {
+			measurement, tags := query.ParseKey(key)
+			if !allow(measurement) {
+				continue
+			}
+			for _, match := range matchers {
+				if match(measurement, tags) {
+					add(tags)
+					break
+				}
+			}
+		}
+	}
+
+	result := make([]string, 0, len(names))
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// QueryRange evaluates expr at every step between start and end.
+func (e *Engine) QueryRange(ctx context.Context, expr Expr, start, end time.Time, step time.Duration) ([]*Series, error) {
+	if step <= 0 {
+		return nil, fmt.Errorf("step must be positive")
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("end timestamp must not be before start time")
+	}
+
+	var steps []int64
+	for t := start; !t.After(end); t = t.Add(step) {
+		steps = append(steps, t.UnixMilli())
+	}
+
+	result, err := e.eval(ctx, expr, start, end, steps)
+	if err != nil {
+		return nil, err
+	}
+	sortSeries(result)
+	return result, nil
+}
+
+func (e *Engine) eval(ctx context.Context, expr Expr, start, end time.Time, steps []int64) ([]*Series, error) {
+	switch ex := expr.(type) {
+	case *VectorSelector:
+		if ex.Range > 0 {
+			return nil, fmt.Errorf("invalid expression type range vector for range query, must be an instant vector")
+		}
+		raw, err := e.Select(ctx, ex.Matchers, start.Add(-e.LookbackDelta), end)
+		if err != nil {
+			return nil, err
+		}
+		lookback := e.LookbackDelta.Milliseconds()
+		return evalWindows(raw, steps, lookback, true, func(samples []Sample) (float64, bool) {
+			return samples[len(samples)-1].V, true
+		}), nil
+
+	case *Call:
+		raw, err := e.Select(ctx, ex.Arg.Matchers, start.Add(-ex.Arg.Range), end)
+		if err != nil {
+			return nil, err
+		}
+		window := ex.Arg.Range.Milliseconds()
+		switch ex.Func {
+		case "rate":
+			return evalWindows(raw, steps, window, false, func(samples []Sample) (float64, bool) {
+				return rate(samples, ex.Arg.Range)
+			}), nil
+		case "avg_over_time":
+			return evalWindows(raw, steps, window, false, func(samples []Sample) (float64, bool) {
+				sum := 0.0
+				for _, s := range samples {
+					sum += s.V
+				}
+				return sum / float64(len(samples)), true
+			}), nil
+		}
+		return nil, fmt.Errorf("unsupported function %q", ex.Func)
+
+	case *Aggregate:
+		inner, err := e.eval(ctx, ex.Expr, start, end, steps)
+		if err != nil {
+			return nil, err
+		}
+		return sumBy(inner, ex.Grouping), nil
+	}
+	return nil, fmt.Errorf("unsupported expression %s", expr)
+}
+
+// evalWindows applies fn to the samples in (t-window, t] for every step t.
+func evalWindows(raw []*Series, steps []int64, window int64, keepName bool, fn func([]Sample) (float64, bool)) []*Series {
+	var result []*Series
+	for _, s := range raw {
+		out := &Series{Labels: s.Labels}
+		if !keepName {
+			out.Labels = dropName(s.Labels)
+		}
+
+		lo, hi := 0, 0
+		for _, t := range steps {
+			for hi < len(s.Samples) && s.Samples[hi].T <= t {
+				hi++
+			}
+			for lo < hi && s.Samples[lo].T <= t-window {
+				lo++
+			}
+			if lo == hi {
+				continue
+			}
+			if v, ok := fn(s.Samples[lo:hi]); ok {
+				out.Samples = append(out.Samples, Sample{T: t, V: v})
+			}
+		}
+		if len(out.Samples) > 0 {
+			result = append(result, out)
+		}
+	}
+	return result
+}
+
+// rate is the per second increase over the window, compensating for counter
+// resets. Unlike Prometheus the result is not extrapolated to the window edges.
+func rate(samples []Sample, window time.Duration) (float64, bool) {
+	if len(samples) < 2 {
+		return 0, false
+	}
+	increase := 0.0
+	for i := 1; i < len(samples); i++ {
+		if samples[i].V < samples[i-1].V {
+			increase += samples[i].V
+		} else {
+			increase += samples[i].V - samples[i-1].V
+		}
+	}
+	return increase / window.Seconds(), true
+}
+
+func sumBy(input []*Series, grouping []string) []*Series {
+	groups := make(map[string]*Series)
+	sums := make(map[string]map[int64]float64)
+
+	for _, s := range input {
+		labels := Labels{}
+		for _, name := range grouping {
+			if v, ok := s.Labels[name]; ok && v != "" {
+				labels[name] = v
+			}
+		}
+		key := labels.String()
+		if _, ok := groups[key]; !ok {
+			groups[key] = &Series{Labels: labels}
+			sums[key] = make(map[int64]float64)
+		}
+		for _, sample := range s.Samples {
+			sums[key][sample.T] += sample.V
+		}
+	}
+
+	result := make([]*Series, 0, len(groups))
+	for key, group := range groups {
+		for t, v := range sums[key] {
+			group.Samples = append(group.Samples, Sample{T: t, V: v})
+		}
+		sort.Slice(group.Samples, func(i, j int) bool { return group.Samples[i].T < group.Samples[j].T })
+		result = append(result, group)
+	}
+	return result
+}
+
+func dropName(l Labels) Labels {
+	out := make(Labels, len(l))
+	for k, v := range l {
+		if k != MetricNameLabel {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func sortSeries(series []*Series) {
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].Labels.String() < series[j].Labels.String()
+	})
+}
+
+// FormatValue formats a sample value the way the Prometheus HTTP API does.
+func FormatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
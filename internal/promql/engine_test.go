@@ -0,0 +1,198 @@
+package promql
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	memtable "github.com/heyyakash/tickdb/internal/mem-table"
+	"github.com/heyyakash/tickdb/internal/query"
+	seriesindex "github.com/heyyakash/tickdb/internal/series-index"
+	"github.com/heyyakash/tickdb/internal/sstable"
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+var t0 = time.Unix(1700000000, 0)
+
+// newEngine returns an engine over a memtable holding, every 15s for 2m:
+//
+//   - http_requests counters for hosts a (1/s) and b (2/s, reset to 10 at
+//     the last sample), with a status string field that isn't a metric
+//   - cpu gauges for host a, stored in the value field
+func newEngine(t *testing.T) *Engine {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	m := memtable.NewMemTableService(make(map[string][]*ingestpb.Point), logger)
+	series := seriesindex.New()
+	add := func(p *ingestpb.Point) {
+		m.AddToMemTable(p)
+		series.Add(memtable.SeriesKey(p))
+	}
+	for i := 0; i <= 8; i++ {
+		ts := t0.Add(time.Duration(i) * 15 * time.Second).UnixNano()
+		b := i * 30
+		if i == 8 {
+			b = 10
+		}
+		add(&ingestpb.Point{Measurement: "http", TimestampUnixNano: ts, Tag: map[string]string{"host": "a", "code": "200"}, Fields: map[string]string{"requests": strconv.Itoa(i * 15), "status": "ok"}})
+		add(&ingestpb.Point{Measurement: "http", TimestampUnixNano: ts, Tag: map[string]string{"host": "b", "code": "200"}, Fields: map[string]string{"requests": strconv.Itoa(b)}})
+		add(&ingestpb.Point{Measurement: "cpu", TimestampUnixNano: ts, Tag: map[string]string{"host": "a", "dc": "eu"}, Fields: map[string]string{"value": strconv.Itoa(i % 2)}})
+	}
+	s := sstable.NewSSTableService(m, t.TempDir(), logger)
+	return NewEngine(query.NewEngine(m, s, logger), series)
+}
+
+// queryAt evaluates q at the single step t
+func queryAt(t *testing.T, e *Engine, q string, at time.Time) []*Series {
+	t.Helper()
+	expr, err := Parse(q)
+	if err != nil {
+		t.Fatalf("Parse(%q) failed: %v", q, err)
+	}
+	result, err := e.QueryRange(context.Background(), expr, at, at, time.Minute)
+	if err != nil {
+		t.Fatalf("QueryRange(%q) failed: %v", q, err)
+	}
+	return result
+}
+
+// checkValues compares the labels and the single value of every series
+func checkValues(t *testing.T, q string, got []*Series, want map[string]float64) {
+	t.Helper()
+	values := make(map[string]float64)
+	for _, s := range got {
+		if len(s.Samples) != 1 {
+			t.Errorf("%s: series %s has %d samples, want 1", q, s.Labels, len(s.Samples))
+			continue
+		}
+		values[s.Labels.String()] = s.Samples[0].V
+	}
+	if len(values) != len(want) {
+		t.Errorf("%s = %v, want %v", q, values, want)
+	}
+	for labels, v := range want {
+		if got, ok := values[labels]; !ok || math.Abs(got-v) > 1e-9 {
+			t.Errorf("%s: %s is %v, want %v", q, labels, values[labels], v)
+		}
+	}
+}
+
+func TestInstantSelector(t *testing.T) {
+	e := newEngine(t)
+	end := t0.Add(2 * time.Minute)
+
+	q := `http_requests{host="a"}`
+	checkValues(t, q, queryAt(t, e, q, end.Add(10*time.Second)), map[string]float64{
+		`{__name__="http_requests",code="200",host="a"}`: 120,
+	})
+
+	q = `{__name__=~"http_.*"}`
+	checkValues(t, q, queryAt(t, e, q, end), map[string]float64{
+		`{__name__="http_requests",code="200",host="a"}`: 120,
+		`{__name__="http_requests",code="200",host="b"}`: 10,
+	})
+
+	// fields named value take the measurement name
+	q = `cpu{dc="eu"}`
+	checkValues(t, q, queryAt(t, e, q, end), map[string]float64{
+		`{__name__="cpu",dc="eu",host="a"}`: 0,
+	})
+
+	// string fields aren't metrics
+	for _, q := range []string{`http_status`, `cpu{host="b"}`} {
+		checkValues(t, q, queryAt(t, e, q, end), nil)
+	}
+	// samples older than the lookback aren't returned
+	q = `cpu`
+	checkValues(t, q, queryAt(t, e, q, end.Add(e.LookbackDelta+time.Second)), nil)
+}
+
+func TestRate(t *testing.T) {
+	e := newEngine(t)
+	end := t0.Add(2 * time.Minute)
+
+	// the samples in (end-1m, end] are the last 4
+	q := `rate(http_requests[1m])`
+	checkValues(t, q, queryAt(t, e, q, end), map[string]float64{
+		`{code="200",host="a"}`: 45.0 / 60,
+		// 30 + 30 and the reset counts as an increase of 10
+		`{code="200",host="b"}`: 70.0 / 60,
+	})
+
+	q = `sum by (code) (rate(http_requests[1m]))`
+	checkValues(t, q, queryAt(t, e, q, end), map[string]float64{
+		`{code="200"}`: 115.0 / 60,
+	})
+
+	q = `sum(rate(http_requests[1m])) by (host)`
+	checkValues(t, q, queryAt(t, e, q, end), map[string]float64{
+		`{host="a"}`: 45.0 / 60,
+		`{host="b"}`: 70.0 / 60,
+	})
+
+	q = `avg_over_time(cpu[1m])`
+	checkValues(t, q, queryAt(t, e, q, end), map[string]float64{
+		`{dc="eu",host="a"}`: 0.5,
+	})
+
+	// a single sample has no rate
+	q = `rate(http_requests[10s])`
+	checkValues(t, q, queryAt(t, e, q, end), nil)
+}
+
+func TestQueryRangeSteps(t *testing.T) {
+	e := newEngine(t)
+	expr, err := Parse(`sum(http_requests)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := e.QueryRange(context.Background(), expr, t0, t0.Add(time.Minute), 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Sample{{t0.UnixMilli(), 0}, {t0.Add(30 * time.Second).UnixMilli(), 90}, {t0.Add(time.Minute).UnixMilli(), 180}}
+	if len(result) != 1 || !reflect.DeepEqual(result[0].Samples, want) {
+		t.Fatalf("sum(http_requests) = %v, want one series with %v", result, want)
+	}
+
+	if _, err := e.QueryRange(context.Background(), expr, t0, t0.Add(-time.Second), time.Second); err == nil {
+		t.Error("a range ending before its start was accepted")
+	}
+	if _, err := e.QueryRange(context.Background(), &VectorSelector{Matchers: expr.(*Aggregate).Expr.(*VectorSelector).Matchers, Range: time.Minute}, t0, t0, time.Second); err == nil {
+		t.Error("a range vector was accepted as a range query")
+	}
+}
+
+func TestLabels(t *testing.T) {
+	e := newEngine(t)
+	ctx := context.Background()
+
+	if got, want := e.LabelNames(ctx, nil), []string{"__name__", "code", "dc", "host"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LabelNames() = %v, want %v", got, want)
+	}
+	selector, err := ParseSelector(`{__name__=~"http_.*"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := e.LabelNames(ctx, [][]*LabelMatcher{selector}), []string{"__name__", "code", "host"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LabelNames(%v) = %v, want %v", selector, got, want)
+	}
+
+	labels, err := e.SeriesLabels(ctx, selector, t0, t0.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, l := range labels {
+		got = append(got, l.String())
+	}
+	want := []string{`{__name__="http_requests",code="200",host="a"}`, `{__name__="http_requests",code="200",host="b"}`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SeriesLabels(%v) = %v, want %v", selector, got, want)
+	}
+}
@@ -0,0 +1,174 @@
+package promql
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MetricNameLabel is the label that carries the metric name of a series.
+const MetricNameLabel = "__name__"
+
+// Labels is the label set of a Prometheus series.
+type Labels map[string]string
+
+// String renders the labels in a stable order so they can be used as map keys
+// and for sorting.
+func (l Labels) String() string {
+	names := make([]string, 0, len(l))
+	for k := range l {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=%q", k, l[k])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Names returns the label names in sorted order.
+func (l Labels) Names() []string {
+	names := make([]string, 0, len(l))
+	for k := range l {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MetricName maps a TickDB measurement and field to a Prometheus metric name.
+// A field called "value" maps to the bare measurement name, every other field
+// is appended to it with an underscore.
+func MetricName(measurement, field string) string {
+	name := measurement
+	if field != "value" {
+		name += "_" + field
+	}
+	return sanitizeName(name)
+}
+
+func sanitizeName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9' && i > 0:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+type MatchType int
+
+const (
+	MatchEqual MatchType = iota
+	MatchNotEqual
+	MatchRegexp
+	MatchNotRegexp
+)
+
+func (t MatchType) String() string {
+	switch t {
+	case MatchEqual:
+		return "="
+	case MatchNotEqual:
+		return "!="
+	case MatchRegexp:
+		return "=~"
+	case MatchNotRegexp:
+		return "!~"
+	}
+	return "unknown"
+}
+
+// LabelMatcher matches the value of a single label.
+type LabelMatcher struct {
+	Type  MatchType
+	Name  string
+	Value string
+	re    *regexp.Regexp
+}
+
+func NewLabelMatcher(t MatchType, name, value string) (*LabelMatcher, error) {
+	m := &LabelMatcher{Type: t, Name: name, Value: value}
+	if t == MatchRegexp || t == MatchNotRegexp {
+		// prometheus regexes are always fully anchored
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q : %w", value, err)
+		}
+		m.re = re
+	}
+	return m, nil
+}
+
+// Matches reports whether v satisfies the matcher. A missing label is matched
+// as the empty string.
+func (m *LabelMatcher) Matches(v string) bool {
+	switch m.Type {
+	case MatchEqual:
+		return v == m.Value
+	case MatchNotEqual:
+		return v != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(v)
+	case MatchNotRegexp:
+		return !m.re.MatchString(v)
+	}
+	return false
+}
+
+func (m *LabelMatcher) String() string {
+	return fmt.Sprintf("%s%s%q", m.Name, m.Type, m.Value)
+}
+
+func matchLabels(matchers []*LabelMatcher, l Labels) bool {
+	for _, m := range matchers {
+		if !m.Matches(l[m.Name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchMeasurement reports whether a metric of measurement may be accepted
+// by the __name__ matchers, so measurements that can't be are skipped before
+// their points are read. The metric names of a measurement are its sanitized
+// name, alone or followed by an underscore and a field. Equality and the
+// literal prefix of regular expressions narrow the measurements down.
+func matchMeasurement(matchers []*LabelMatcher, measurement string) bool {
+	name := sanitizeName(measurement)
+	for _, m := range matchers {
+		if m.Name != MetricNameLabel {
+			continue
+		}
+		prefix, complete := m.Value, true
+		switch m.Type {
+		case MatchEqual:
+		case MatchRegexp:
+			prefix, complete = m.re.LiteralPrefix()
+		default:
+			continue
+		}
+		if complete && prefix != name && !strings.HasPrefix(prefix, name+"_") {
+			return false
+		}
+		// some metric name of the measurement has to start with prefix
+		if !complete && !strings.HasPrefix(name, prefix) && !strings.HasPrefix(prefix, name+"_") {
+			return false
+		}
+	}
+	return true
+}
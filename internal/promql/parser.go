@@ -0,0 +1,498 @@
+package promql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Expr is a parsed PromQL expression. Only a subset of PromQL is supported:
+// instant and range selectors with label matchers, rate, avg_over_time and
+// sum with an optional by clause.
+type Expr interface {
+	String() string
+}
+
+// VectorSelector selects series by label matchers. A non-zero Range turns it
+// into a range vector selector such as foo[5m].
+type VectorSelector struct {
+	Matchers []*LabelMatcher
+	Range    time.Duration
+}
+
+// Call is a function applied to a range vector.
+type Call struct {
+	Func string
+	Arg  *VectorSelector
+}
+
+// Aggregate is an aggregation over an instant vector.
+type Aggregate struct {
+	Op       string
+	Grouping []string
+	Expr     Expr
+}
+
+func (v *VectorSelector) String() string {
+	parts := make([]string, 0, len(v.Matchers))
+	for _, m := range v.Matchers {
+		parts = append(parts, m.String())
+	}
+	s := "{" + strings.Join(parts, ",") + "}"
+	if v.Range > 0 {
+		s += "[" + formatDuration(v.Range) + "]"
+	}
+	return s
+}
+
+func (c *Call) String() string {
+	return c.Func + "(" + c.Arg.String() + ")"
+}
+
+func (a *Aggregate) String() string {
+	s := a.Op
+	if len(a.Grouping) > 0 {
+		s += " by (" + strings.Join(a.Grouping, ",") + ")"
+	}
+	return s + " (" + a.Expr.String() + ")"
+}
+
+var rangeFunctions = map[string]bool{
+	"rate":          true,
+	"avg_over_time": true,
+}
+
+var aggregateOps = map[string]bool{
+	"sum": true,
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokDuration
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+	pos  int
+}
+
+type parser struct {
+	input  string
+	tokens []token
+	pos    int
+}
+
+// Parse parses a PromQL expression.
+func Parse(input string) (Expr, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{input: input, tokens: tokens}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.val, t.pos)
+	}
+	return expr, nil
+}
+
+// ParseSelector parses a single instant vector selector such as the match[]
+// arguments of the series API.
+func ParseSelector(input string) ([]*LabelMatcher, error) {
+	expr, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := expr.(*VectorSelector)
+	if !ok || sel.Range > 0 {
+		return nil, fmt.Errorf("%q is not an instant vector selector", input)
+	}
+	return sel.Matchers, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind, val string) (token, error) {
+	t := p.next()
+	if t.kind != kind || (val != "" && t.val != val) {
+		want := val
+		if want == "" {
+			want = map[tokenKind]string{tokIdent: "identifier", tokString: "string", tokDuration: "duration"}[kind]
+		}
+		if t.kind == tokEOF {
+			return t, fmt.Errorf("unexpected end of input, expected %s", want)
+		}
+		return t, fmt.Errorf("unexpected %q at position %d, expected %s", t.val, t.pos, want)
+	}
+	return t, nil
+}
+
+func (p *parser) parseExpr() (Expr, error) {
+	t := p.peek()
+	if t.kind == tokIdent {
+		if aggregateOps[t.val] {
+			return p.parseAggregate()
+		}
+		if rangeFunctions[t.val] {
+			return p.parseCall()
+		}
+	}
+	return p.parseSelector()
+}
+
+func (p *parser) parseAggregate() (Expr, error) {
+	op := p.next().val
+	agg := &Aggregate{Op: op}
+
+	if t := p.peek(); t.kind == tokIdent && t.val == "by" {
+		grouping, err := p.parseGrouping()
+		if err != nil {
+			return nil, err
+		}
+		agg.Grouping = grouping
+	}
+
+	if _, err := p.expect(tokPunct, "("); err != nil {
+		return nil, err
+	}
+	inner, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if sel, ok := inner.(*VectorSelector); ok && sel.Range > 0 {
+		return nil, fmt.Errorf("%s expects an instant vector, got a range vector", op)
+	}
+	agg.Expr = inner
+	if _, err := p.expect(tokPunct, ")"); err != nil {
+		return nil, err
+	}
+
+	// the grouping clause may also follow the expression
+	if t := p.peek(); t.kind == tokIdent && t.val == "by" {
+		if agg.Grouping != nil {
+			return nil, fmt.Errorf("duplicate by clause at position %d", t.pos)
+		}
+		grouping, err := p.parseGrouping()
+		if err != nil {
+			return nil, err
+		}
+		agg.Grouping = grouping
+	}
+	return agg, nil
+}
+
+func (p *parser) parseGrouping() ([]string, error) {
+	p.next() // by
+	if _, err := p.expect(tokPunct, "("); err != nil {
+		return nil, err
+	}
+	grouping := []string{}
+	for {
+		t := p.next()
+		if t.kind == tokPunct && t.val == ")" {
+			return grouping, nil
+		}
+		if t.kind != tokIdent {
+			return nil, fmt.Errorf("unexpected %q at position %d, expected label name", t.val, t.pos)
+		}
+		grouping = append(grouping, t.val)
+
+		t = p.next()
+		if t.kind == tokPunct && t.val == ")" {
+			return grouping, nil
+		}
+		if t.kind != tokPunct || t.val != "," {
+			return nil, fmt.Errorf("unexpected %q at position %d, expected , or )", t.val, t.pos)
+		}
+	}
+}
+
+func (p *parser) parseCall() (Expr, error) {
+	name := p.next().val
+	if _, err := p.expect(tokPunct, "("); err != nil {
+		return nil, err
+	}
+	arg, err := p.parseSelector()
+	if err != nil {
+		return nil, err
+	}
+	sel := arg.(*VectorSelector)
+	if sel.Range == 0 {
+		return nil, fmt.Errorf("%s expects a range vector such as metric[5m]", name)
+	}
+	if _, err := p.expect(tokPunct, ")"); err != nil {
+		return nil, err
+	}
+	return &Call{Func: name, Arg: sel}, nil
+}
+
+func (p *parser) parseSelector() (Expr, error) {
+	sel := &VectorSelector{}
+
+	t := p.peek()
+	if t.kind == tokIdent {
+		p.next()
+		m, _ := NewLabelMatcher(MatchEqual, MetricNameLabel, t.val)
+		sel.Matchers = append(sel.Matchers, m)
+	}
+
+	if t := p.peek(); t.kind == tokPunct && t.val == "{" {
+		p.next()
+		matchers, err := p.parseMatchers()
+		if err != nil {
+			return nil, err
+		}
+		sel.Matchers = append(sel.Matchers, matchers...)
+	}
+
+	if len(sel.Matchers) == 0 {
+		t := p.peek()
+		if t.kind == tokEOF {
+			return nil, fmt.Errorf("unexpected end of input, expected selector")
+		}
+		return nil, fmt.Errorf("unexpected %q at position %d, expected selector", t.val, t.pos)
+	}
+
+	empty := true
+	for _, m := range sel.Matchers {
+		if !m.Matches("") {
+			empty = false
+		}
+	}
+	if empty {
+		return nil, fmt.Errorf("vector selector must contain at least one non-empty matcher")
+	}
+
+	if t := p.peek(); t.kind == tokPunct && t.val == "[" {
+		p.next()
+		d, err := p.expect(tokDuration, "")
+		if err != nil {
+			return nil, err
+		}
+		sel.Range, err = ParseDuration(d.val)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokPunct, "]"); err != nil {
+			return nil, err
+		}
+	}
+	return sel, nil
+}
+
+func (p *parser) parseMatchers() ([]*LabelMatcher, error) {
+	var matchers []*LabelMatcher
+	for {
+		t := p.next()
+		if t.kind == tokPunct && t.val == "}" {
+			return matchers, nil
+		}
+		if t.kind != tokIdent {
+			return nil, fmt.Errorf("unexpected %q at position %d, expected label name", t.val, t.pos)
+		}
+		name := t.val
+
+		op := p.next()
+		var matchType MatchType
+		switch op.val {
+		case "=":
+			matchType = MatchEqual
+		case "!=":
+			matchType = MatchNotEqual
+		case "=~":
+			matchType = MatchRegexp
+		case "!~":
+			matchType = MatchNotRegexp
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d, expected label matcher", op.val, op.pos)
+		}
+
+		value, err := p.expect(tokString, "")
+		if err != nil {
+			return nil, err
+		}
+		m, err := NewLabelMatcher(matchType, name, value.val)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+
+		t = p.next()
+		if t.kind == tokPunct && t.val == "}" {
+			return matchers, nil
+		}
+		if t.kind != tokPunct || t.val != "," {
+			return nil, fmt.Errorf("unexpected %q at position %d, expected , or }", t.val, t.pos)
+		}
+	}
+}
+
+func lex(input string) ([]token, error) {
+	var tokens []token
+	inRange := false
+	i := 0
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case inRange && c >= '0' && c <= '9':
+			start := i
+			for i < len(input) && (isAlnum(input[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokDuration, val: input[start:i], pos: start})
+		case isIdentStart(c):
+			start := i
+			for i < len(input) && (isIdentStart(input[i]) || (input[i] >= '0' && input[i] <= '9')) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, val: input[start:i], pos: start})
+		case c == '"' || c == '\'' || c == '`':
+			start := i
+			i++
+			for i < len(input) && input[i] != c {
+				if input[i] == '\\' && c != '`' {
+					i++
+				}
+				i++
+			}
+			if i >= len(input) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++
+			val, err := unquote(input[start:i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d : %w", start, err)
+			}
+			tokens = append(tokens, token{kind: tokString, val: val, pos: start})
+		case c == '=' || c == '!':
+			start := i
+			i++
+			if i < len(input) && (input[i] == '=' || input[i] == '~') {
+				i++
+			}
+			op := input[start:i]
+			if op == "!" || op == "==" {
+				return nil, fmt.Errorf("unexpected %q at position %d", op, start)
+			}
+			tokens = append(tokens, token{kind: tokPunct, val: op, pos: start})
+		case strings.IndexByte("{}()[],", c) >= 0:
+			if c == '[' {
+				inRange = true
+			} else if c == ']' {
+				inRange = false
+			}
+			tokens = append(tokens, token{kind: tokPunct, val: string(c), pos: i})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+		}
+	}
+	tokens = append(tokens, token{kind: tokEOF, pos: len(input)})
+	return tokens, nil
+}
+
+func unquote(s string) (string, error) {
+	if s[0] == '\'' {
+		// convert to a double quoted string so strconv can handle the escapes
+		inner := strings.ReplaceAll(s[1:len(s)-1], `\'`, `'`)
+		inner = strings.ReplaceAll(inner, `"`, `\"`)
+		s = `"` + inner + `"`
+	}
+	return strconv.Unquote(s)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isAlnum(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+var durationUnits = []struct {
+	suffix string
+	unit   time.Duration
+}{
+	{"ms", time.Millisecond},
+	{"s", time.Second},
+	{"m", time.Minute},
+	{"h", time.Hour},
+	{"d", 24 * time.Hour},
+	{"w", 7 * 24 * time.Hour},
+	{"y", 365 * 24 * time.Hour},
+}
+
+// formatDuration writes d with the largest unit dividing it, such as 90s or
+// 1h, so it parses back
+func formatDuration(d time.Duration) string {
+	for i := len(durationUnits) - 1; i >= 0; i-- {
+		if u := durationUnits[i]; d%u.unit == 0 {
+			return strconv.FormatInt(int64(d/u.unit), 10) + u.suffix
+		}
+	}
+	return d.String()
+}
+
+// ParseDuration parses Prometheus style durations such as 30s, 5m or 1h30m.
+func ParseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+	var total time.Duration
+	rest := s
+	for rest != "" {
+		i := 0
+		for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		n, err := strconv.ParseInt(rest[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		rest = rest[i:]
+
+		matched := false
+		// "ms" is listed before "m" so it wins
+		for _, u := range durationUnits {
+			if strings.HasPrefix(rest, u.suffix) {
+				total += time.Duration(n) * u.unit
+				rest = rest[len(u.suffix):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+	}
+	if total <= 0 {
+		return 0, fmt.Errorf("duration must be positive : %q", s)
+	}
+	return total, nil
+}
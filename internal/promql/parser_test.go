@@ -0,0 +1,120 @@
+package promql
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`cpu_usage`, `{__name__="cpu_usage"}`},
+		{`cpu_usage{host="a",dc!="eu"}`, `{__name__="cpu_usage",host="a",dc!="eu"}`},
+		{`cpu{host='a'}`, `{__name__="cpu",host="a"}`},
+		{`{__name__=~"cpu.*",host!~"b|c"}`, `{__name__=~"cpu.*",host!~"b|c"}`},
+		{`rate(http_requests_total{code="200"}[5m])`, `rate({__name__="http_requests_total",code="200"}[5m])`},
+		{`avg_over_time(temp[1h30m])`, `avg_over_time({__name__="temp"}[90m])`},
+		{`rate(req[1500ms])`, `rate({__name__="req"}[1500ms])`},
+		{`sum(cpu)`, `sum ({__name__="cpu"})`},
+		{`sum by (host) (rate(req[1m]))`, `sum by (host) (rate({__name__="req"}[1m]))`},
+		{`sum(rate(req[1m])) by (host, dc)`, `sum by (host,dc) (rate({__name__="req"}[1m]))`},
+		{`cpu[2d]`, `{__name__="cpu"}[2d]`},
+	}
+	for _, tt := range tests {
+		expr, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.query, err)
+			continue
+		}
+		if got := expr.String(); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.query, got, tt.want)
+		}
+		// the printed expression parses to itself
+		again, err := Parse(expr.String())
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", expr.String(), err)
+		} else if again.String() != tt.want {
+			t.Errorf("Parse(%q) = %s", expr.String(), again.String())
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{`max(cpu)`, `unexpected "("`},
+		{`rate(cpu)`, "expects a range vector"},
+		{`{}`, "expected selector"},
+		{`cpu{host="a"`, "expected , or }"},
+		{`rate(cpu[5x])`, "invalid duration"},
+		{`rate(cpu[0s])`, "duration must be positive"},
+		{`cpu{host=~"("}`, "invalid regular expression"},
+		{`cpu extra`, `unexpected "extra"`},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.query)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.query, err, tt.err)
+		}
+	}
+}
+
+func TestParseSelector(t *testing.T) {
+	matchers, err := ParseSelector(`up{job=~"api|web",instance!=""}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`__name__="up"`, `job=~"api|web"`, `instance!=""`}
+	if len(matchers) != len(want) {
+		t.Fatalf("got %d matchers, want %d", len(matchers), len(want))
+	}
+	for i, m := range matchers {
+		if m.String() != want[i] {
+			t.Errorf("matcher %d is %s, want %s", i, m, want[i])
+		}
+	}
+	// regexes are anchored
+	if job := matchers[1]; !job.Matches("api") || job.Matches("apis") || job.Matches("xapi") {
+		t.Errorf("%s matches the wrong values", job)
+	}
+
+	for _, input := range []string{`up[5m]`, `rate(up[5m])`, `sum(up)`} {
+		if _, err := ParseSelector(input); err == nil {
+			t.Errorf("ParseSelector(%q) succeeded", input)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		s    string
+		want time.Duration
+		// how the duration is printed back
+		format string
+	}{
+		{"250ms", 250 * time.Millisecond, "250ms"},
+		{"30s", 30 * time.Second, "30s"},
+		{"1h30m", 90 * time.Minute, "90m"},
+		{"1d", 24 * time.Hour, "1d"},
+		{"1y", 365 * 24 * time.Hour, "1y"},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.s)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, %v, want %v", tt.s, got, err, tt.want)
+		}
+		if f := formatDuration(got); f != tt.format {
+			t.Errorf("formatDuration(%v) = %s, want %s", got, f, tt.format)
+		}
+	}
+	for _, s := range []string{"", "5", "m", "5x", "0s"} {
+		if _, err := ParseDuration(s); err == nil {
+			t.Errorf("ParseDuration(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	memtable "github.com/heyyakash/tickdb/internal/mem-table"
+)
+
+type AggregateFunc string
+
+const (
+	Count AggregateFunc = "count"
+	Sum   AggregateFunc = "sum"
+	Mean  AggregateFunc = "mean"
+	Min   AggregateFunc = "min"
+	Max   AggregateFunc = "max"
+	First AggregateFunc = "first"
+	Last  AggregateFunc = "last"
+)
+
+// Bucket is the aggregated value of one time window.
+type Bucket struct {
+	Start int64   `json:"start_unix_nano"`
+	Value float64 `json:"value"`
+	Count uint64  `json:"count"`
+}
+
+// Aggregate applies fn to the numeric values of field for a single series key.
+// With a window of 0 the whole range is one bucket, otherwise the range is
+// split into windows aligned to from. Empty windows are left out.
+func (e *Engine) Aggregate(ctx context.Context, key, field string, fn AggregateFunc, from, to, window int64) ([]Bucket, error) {
+	switch fn {
+	case Count, Sum, Mean, Min, Max, First, Last:
+	default:
+		return nil, fmt.Errorf("unknown aggregate function %q", fn)
+	}
+	if window < 0 {
+		return nil, fmt.Errorf("window must not be negative")
+	}
+
+	key = memtable.NormalizeKey(key)
+	measurement, _, _ := strings.Cut(key, "|")
+	cursor, err := e.Execute(ctx, &Plan{
+		Measurement: measurement,
+		Match: func(measurement string, tags map[string]string) bool {
+			return memtable.Key(measurement, tags) == key
+		},
+		From:   from,
+		To:     to,
+		Calls:  []Call{{Func: fn, Field: field}, {Func: Count, Field: field}},
+		Window: window,
+		Origin: from,
+	})
+	if err != nil {
+		return nil, err
+	}
+	results, err := Collect(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := []Bucket{}
+	for _, result := range results {
+		for _, values := range result.Values {
+			buckets = append(buckets, Bucket{Start: values[0].(int64), Value: values[1].(float64), Count: uint64(values[2].(float64))})
+		}
+	}
+	return buckets, nil
+}
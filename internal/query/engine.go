@@ -0,0 +1,120 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	memtable "github.com/heyyakash/tickdb/internal/mem-table"
+	"github.com/heyyakash/tickdb/internal/sstable"
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// Series is every point of one series key within a time range, sorted by timestamp.
+type Series struct {
+	Key         string
+	Measurement string
+	Tags        map[string]string
+	Points      []*ingestpb.Point
+}
+
+// Matcher decides whether a series takes part in a query.
+type Matcher func(measurement string, tags map[string]string) bool
+
+// Engine reads series from the memtable and the SSTables on disk through
+// iterators: a block iterator per series and source, merged by timestamp,
+// then filtered, aggregated, filled and limited.
+type Engine struct {
+	m      *memtable.MemTableService
+	s      *sstable.SSTableService
+	logger *slog.Logger
+
+	// MaxPoints is the most points or rows one query may return, 0 is unlimited
+	MaxPoints int
+}
+
+func NewEngine(m *memtable.MemTableService, s *sstable.SSTableService, logger *slog.Logger) *Engine {
+	return &Engine{
+		m:      m,
+		s:      s,
+		logger: logger,
+	}
+}
+
+// Select returns every series accepted by match that has points within
+// [from, to] (inclusive, unix nanoseconds). A nil match accepts every series.
+// Measurements the caller's API token may not read are left out. The points
+// are read into memory, Execute and Points stream them.
+func (e *Engine) Select(ctx context.Context, match Matcher, from, to int64) ([]*Series, error) {
+	start := time.Now()
+	s, err := e.scan(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	read := 0
+	result := make([]*Series, 0, len(s.series))
+	for _, ref := range s.series {
+		points, err := ReadAll(&guardIterator{PointIterator: ref.iterator(ctx, from, to, false), read: &read, max: e.MaxPoints})
+		if err != nil {
+			return nil, err
+		}
+		if len(points) > 0 {
+			result = append(result, &Series{Key: ref.key, Measurement: ref.measurement, Tags: ref.tags, Points: points})
+		}
+	}
+	e.logger.DebugContext(ctx, "Select finished", "from", from, "to", to, "sstables", len(s.readers), "series", len(result), "duration", time.Since(start))
+	return result, nil
+}
+
+// Each calls fn with every series accepted by match that Select would return,
+// without its points, and an iterator over them. Series are read one at a
+// time, fn must not keep the iterator.
+func (e *Engine) Each(ctx context.Context, match Matcher, from, to int64, fn func(s *Series, points PointIterator) error) error {
+	s, err := e.scan(ctx, match)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	for _, ref := range s.series {
+		it := ref.iterator(ctx, from, to, false)
+		err := fn(&Series{Key: ref.key, Measurement: ref.measurement, Tags: ref.tags}, it)
+		if closeErr := it.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Points returns an iterator over the points of a single series key within
+// [from, to], oldest first.
+func (e *Engine) Points(ctx context.Context, key string, from, to int64) (PointIterator, error) {
+	key = memtable.NormalizeKey(key)
+	s, err := e.scan(ctx, func(measurement string, tags map[string]string) bool {
+		return memtable.Key(measurement, tags) == key
+	})
+	if err != nil {
+		return nil, err
+	}
+	var inputs []PointIterator
+	for _, ref := range s.series {
+		inputs = append(inputs, ref.iterator(ctx, from, to, false))
+	}
+	return &scanIterator{PointIterator: Merge(false, inputs...), scan: s}, nil
+}
+
+// scanIterator closes the SSTables of a scan with its iterator
+type scanIterator struct {
+	PointIterator
+	scan *scan
+}
+
+func (s *scanIterator) Close() error {
+	return errors.Join(s.PointIterator.Close(), s.scan.Close())
+}
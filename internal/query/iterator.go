@@ -0,0 +1,522 @@
+package query
+
+import (
+	"container/heap"
+	"math"
+	"strconv"
+
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// PointIterator yields points in timestamp order.
+type PointIterator interface {
+	// Next returns the next point, nil once the iterator is exhausted.
+	Next() (*ingestpb.Point, error)
+	Close() error
+}
+
+// Row is one line of a query result, Values holds a value per selected
+// column: a float64 for numeric fields, a string otherwise, nil when the
+// field is missing.
+type Row struct {
+	Time   int64
+	Values []any
+}
+
+// RowIterator yields the rows of a result.
+type RowIterator interface {
+	// Next returns the next row, nil once the iterator is exhausted.
+	Next() (*Row, error)
+	Close() error
+}
+
+// mergeItem is the head of one of the merged iterators
+type mergeItem struct {
+	point *ingestpb.Point
+	index int
+}
+
+type mergeHeap struct {
+	items []mergeItem
+	desc  bool
+}
+
+func (h *mergeHeap) Len() int { return len(h.items) }
+func (h *mergeHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if a.point.TimestampUnixNano != b.point.TimestampUnixNano {
+		return (a.point.TimestampUnixNano < b.point.TimestampUnixNano) != h.desc
+	}
+	return (a.index < b.index) != h.desc
+}
+func (h *mergeHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *mergeHeap) Push(x any)    { h.items = append(h.items, x.(mergeItem)) }
+func (h *mergeHeap) Pop() any {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return item
+}
+
+// mergeIterator is a k-way merge holding the next point of every input
+type mergeIterator struct {
+	inputs []PointIterator
+	heap   mergeHeap
+	primed bool
+}
+
+// Merge interleaves the points of inputs, which must all be in the same
+// order, by timestamp: oldest first, or newest first when desc is set.
+// Points with the same timestamp come in the order of their inputs, reversed
+// when desc is set.
+func Merge(desc bool, inputs ...PointIterator) PointIterator {
+	return &mergeIterator{inputs: inputs, heap: mergeHeap{desc: desc}}
+}
+
+func (m *mergeIterator) Next() (*ingestpb.Point, error) {
+	if !m.primed {
+		m.primed = true
+		for i, it := range m.inputs {
+			point, err := it.Next()
+			if err != nil {
+				return nil, err
+			}
+			if point != nil {
+				m.heap.items = append(m.heap.items, mergeItem{point: point, index: i})
+			}
+		}
+		heap.Init(&m.heap)
+	}
+	if len(m.heap.items) == 0 {
+		return nil, nil
+	}
+
+	head := m.heap.items[0]
+	point, err := m.inputs[head.index].Next()
+	if err != nil {
+		return nil, err
+	}
+	if point != nil {
+		m.heap.items[0].point = point
+		heap.Fix(&m.heap, 0)
+	} else {
+		heap.Pop(&m.heap)
+	}
+	return head.point, nil
+}
+
+func (m *mergeIterator) Close() error {
+	var first error
+	for _, it := range m.inputs {
+		if err := it.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+type filterIterator struct {
+	PointIterator
+	keep func(*ingestpb.Point) bool
+}
+
+// Filter drops the points keep rejects.
+func Filter(it PointIterator, keep func(*ingestpb.Point) bool) PointIterator {
+	return &filterIterator{PointIterator: it, keep: keep}
+}
+
+func (f *filterIterator) Next() (*ingestpb.Point, error) {
+	for {
+		point, err := f.PointIterator.Next()
+		if point == nil || err != nil {
+			return nil, err
+		}
+		if f.keep(point) {
+			return point, nil
+		}
+	}
+}
+
+// FieldValue returns a field as a float64 when it is numeric, as a string
+// otherwise.
+func FieldValue(raw string) any {
+	if v, ok := ParseNumber(raw); ok {
+		return v
+	}
+	return raw
+}
+
+// ParseNumber parses a numeric field. NaN and infinities aren't numbers
+// here, they can't be encoded as JSON and would poison aggregates.
+func ParseNumber(raw string) (float64, bool) {
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, false
+	}
+	return v, true
+}
+
+type projectIterator struct {
+	in     PointIterator
+	fields []string
+}
+
+// Project turns points into rows holding the values of fields. Points that
+// have none of the fields are left out.
+func Project(it PointIterator, fields []string) RowIterator {
+	return &projectIterator{in: it, fields: fields}
+}
+
+func (p *projectIterator) Next() (*Row, error) {
+	for {
+		point, err := p.in.Next()
+		if point == nil || err != nil {
+			return nil, err
+		}
+		row := &Row{Time: point.TimestampUnixNano, Values: make([]any, len(p.fields))}
+		found := false
+		for i, field := range p.fields {
+			if raw, ok := point.Fields[field]; ok {
+				row.Values[i] = FieldValue(raw)
+				found = true
+			}
+		}
+		if found {
+			return row, nil
+		}
+	}
+}
+
+func (p *projectIterator) Close() error { return p.in.Close() }
+
+// Call is an aggregate function applied to a field.
+type Call struct {
+	Func  AggregateFunc
+	Field string
+}
+
+// callState accumulates one call over a window
+type callState struct {
+	value float64
+	sum   float64
+	count uint64
+	// timestamp of value for first and last, points may come newest first
+	ts int64
+}
+
+func (c *callState) add(fn AggregateFunc, v float64, ts int64) {
+	if c.count == 0 {
+		c.value, c.ts = v, ts
+	}
+	c.count++
+	c.sum += v
+	switch fn {
+	case Min:
+		c.value = math.Min(c.value, v)
+	case Max:
+		c.value = math.Max(c.value, v)
+	case First:
+		if ts < c.ts {
+			c.value, c.ts = v, ts
+		}
+	case Last:
+		if ts >= c.ts {
+			c.value, c.ts = v, ts
+		}
+	}
+}
+
+func (c *callState) result(fn AggregateFunc) any {
+	if c.count == 0 {
+		if fn == Count {
+			return float64(0)
+		}
+		return nil
+	}
+	switch fn {
+	case Count:
+		return float64(c.count)
+	case Sum:
+		return c.sum
+	case Mean:
+		return c.sum / float64(c.count)
+	}
+	return c.value
+}
+
+type aggregateIterator struct {
+	in     PointIterator
+	calls  []Call
+	origin int64
+	window int64
+
+	current *Row
+	states  []callState
+	done    bool
+}
+
+// AggregateWindows applies calls to the points of it in windows of window
+// nanoseconds aligned to origin. With a window of 0 every point is
+// aggregated into one row at origin. Windows without points are left out.
+// The points may come in either order, only one window is held in memory.
+func AggregateWindows(it PointIterator, calls []Call, origin, window int64) RowIterator {
+	return &aggregateIterator{in: it, calls: calls, origin: origin, window: window}
+}
+
+// WindowStart returns the start of the window of width window, aligned to
+// origin, holding ts. A window of 0 holds every timestamp and starts at origin.
+func WindowStart(ts, origin, window int64) int64 {
+	if window <= 0 {
+		return origin
+	}
+	offset := ts - origin
+	start := offset / window * window
+	if offset < 0 && start != offset {
+		start -= window
+	}
+	return origin + start
+}
+
+func (a *aggregateIterator) Next() (*Row, error) {
+	for !a.done {
+		point, err := a.in.Next()
+		if err != nil {
+			return nil, err
+		}
+		if point == nil {
+			a.done = true
+			break
+		}
+
+		start := WindowStart(point.TimestampUnixNano, a.origin, a.window)
+		var finished *Row
+		if a.current != nil && a.current.Time != start {
+			finished = a.flush()
+		}
+		if a.current == nil {
+			a.current = &Row{Time: start}
+			a.states = make([]callState, len(a.calls))
+		}
+		for i, call := range a.calls {
+			raw, ok := point.Fields[call.Field]
+			if !ok {
+				continue
+			}
+			if v, ok := ParseNumber(raw); ok {
+				a.states[i].add(call.Func, v, point.TimestampUnixNano)
+			}
+		}
+		if finished != nil {
+			return finished, nil
+		}
+	}
+	if a.current != nil {
+		return a.flush(), nil
+	}
+	return nil, nil
+}
+
+// flush returns the current window as a row, nil if no field matched in it
+func (a *aggregateIterator) flush() *Row {
+	row, states := a.current, a.states
+	a.current, a.states = nil, nil
+
+	found := false
+	row.Values = make([]any, len(a.calls))
+	for i, call := range a.calls {
+		if states[i].count > 0 {
+			found = true
+		}
+		row.Values[i] = states[i].result(call.Func)
+	}
+	if !found {
+		return nil
+	}
+	return row
+}
+
+func (a *aggregateIterator) Close() error { return a.in.Close() }
+
+// FillMode decides the values of windows without points.
+type FillMode string
+
+const (
+	// leave the window out
+	FillNone FillMode = "none"
+	// a row of nulls
+	FillNull FillMode = "null"
+	// a row holding Fill.Value in every column
+	FillValue FillMode = "value"
+	// the values of the previous row
+	FillPrevious FillMode = "previous"
+	// values interpolated between the rows around the gap
+	FillLinear FillMode = "linear"
+)
+
+// Fill configures FillWindows.
+type Fill struct {
+	Mode  FillMode
+	Value float64
+}
+
+type fillIterator struct {
+	in     RowIterator
+	fill   Fill
+	window int64
+	// start of the first and last windows in iteration order, unbounded ends
+	// start and stop at the rows of it
+	first, last       int64
+	hasFirst, hasLast bool
+	desc              bool
+
+	started bool
+	next    int64
+	prev    *Row
+	pending *Row
+	done    bool
+}
+
+// FillWindows adds a row for every window of width window without one in it.
+// first and last are the starts of the first and last windows, in the order
+// of the rows, and are ignored when the has flags are false.
+func FillWindows(it RowIterator, fill Fill, window, first, last int64, hasFirst, hasLast, desc bool) RowIterator {
+	if fill.Mode == FillNone || fill.Mode == "" || window <= 0 {
+		return it
+	}
+	return &fillIterator{in: it, fill: fill, window: window, first: first, last: last, hasFirst: hasFirst, hasLast: hasLast, desc: desc}
+}
+
+// after reports whether window a comes after window b in iteration order
+func (f *fillIterator) after(a, b int64) bool {
+	if f.desc {
+		return a < b
+	}
+	return a > b
+}
+
+func (f *fillIterator) step() {
+	if f.desc {
+		f.next -= f.window
+	} else {
+		f.next += f.window
+	}
+}
+
+func (f *fillIterator) Next() (*Row, error) {
+	if f.pending == nil && !f.done {
+		row, err := f.in.Next()
+		if err != nil {
+			return nil, err
+		}
+		f.pending = row
+		f.done = row == nil
+	}
+
+	if !f.started {
+		switch {
+		case f.hasFirst:
+			f.next = f.first
+		case f.pending != nil:
+			f.next = f.pending.Time
+		default:
+			return nil, nil
+		}
+		f.started = true
+	}
+
+	if f.pending != nil {
+		if !f.after(f.pending.Time, f.next) {
+			row := f.pending
+			f.pending, f.prev = nil, row
+			f.next = row.Time
+			f.step()
+			return row, nil
+		}
+	} else if !f.hasLast || f.after(f.next, f.last) {
+		return nil, nil
+	}
+
+	row := f.filled(f.next)
+	f.step()
+	return row, nil
+}
+
+// filled returns the row for an empty window at ts
+func (f *fillIterator) filled(ts int64) *Row {
+	width := 0
+	if f.prev != nil {
+		width = len(f.prev.Values)
+	} else if f.pending != nil {
+		width = len(f.pending.Values)
+	}
+	row := &Row{Time: ts, Values: make([]any, width)}
+	for i := range row.Values {
+		switch f.fill.Mode {
+		case FillValue:
+			row.Values[i] = f.fill.Value
+		case FillPrevious:
+			if f.prev != nil {
+				row.Values[i] = f.prev.Values[i]
+			}
+		case FillLinear:
+			if f.prev == nil || f.pending == nil {
+				continue
+			}
+			a, aok := f.prev.Values[i].(float64)
+			b, bok := f.pending.Values[i].(float64)
+			if aok && bok {
+				row.Values[i] = a + (b-a)*float64(ts-f.prev.Time)/float64(f.pending.Time-f.prev.Time)
+			}
+		}
+	}
+	return row
+}
+
+func (f *fillIterator) Close() error { return f.in.Close() }
+
+type limitIterator struct {
+	in      RowIterator
+	offset  int
+	limit   int
+	emitted int
+}
+
+// Limit skips the first offset rows of it and stops after limit rows. A limit
+// of 0 is unlimited.
+func Limit(it RowIterator, offset, limit int) RowIterator {
+	return &limitIterator{in: it, offset: offset, limit: limit}
+}
+
+func (l *limitIterator) Next() (*Row, error) {
+	if l.limit > 0 && l.emitted >= l.limit {
+		return nil, nil
+	}
+	for ; l.offset > 0; l.offset-- {
+		row, err := l.in.Next()
+		if row == nil || err != nil {
+			return nil, err
+		}
+	}
+	row, err := l.in.Next()
+	if row != nil {
+		l.emitted++
+	}
+	return row, err
+}
+
+func (l *limitIterator) Close() error { return l.in.Close() }
+
+// ReadAll returns the remaining points of it and closes it.
+func ReadAll(it PointIterator) ([]*ingestpb.Point, error) {
+	defer it.Close()
+	var points []*ingestpb.Point
+	for {
+		point, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		if point == nil {
+			return points, nil
+		}
+		points = append(points, point)
+	}
+}
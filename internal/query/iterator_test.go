@@ -0,0 +1,23 @@
+package query
+
+import "testing"
+
+func TestFieldValue(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want any
+	}{
+		{"1.5", 1.5},
+		{"-3", -3.0},
+		{"1e3", 1000.0},
+		{"ok", "ok"},
+		{"NaN", "NaN"},
+		{"inf", "inf"},
+		{"-Infinity", "-Infinity"},
+	}
+	for _, tt := range tests {
+		if got := FieldValue(tt.raw); got != tt.want {
+			t.Errorf("FieldValue(%q) = %#v, want %#v", tt.raw, got, tt.want)
+		}
+	}
+}
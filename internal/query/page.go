@@ -0,0 +1,207 @@
+package query
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+var ErrInvalidCursor = errors.New("Invalid cursor")
+
+// TooManyPointsError is returned when a query produces more points or rows
+// than the engine allows.
+type TooManyPointsError struct {
+	Max int
+}
+
+func (e *TooManyPointsError) Error() string {
+	return fmt.Sprintf("query exceeds the limit of %d points, narrow the time range or page through the result with page_size and cursor", e.Max)
+}
+
+// Position is where a page of a read stopped. Range reads resume at Time,
+// skipping the Skip points at it already returned. Statements resume in the
+// group with key Group, skipping the Skip rows already returned from it.
+type Position struct {
+	// fingerprint of the query the position belongs to
+	Query string `json:"q"`
+	Time  int64  `json:"t,omitempty"`
+	Group string `json:"g,omitempty"`
+	Skip  int    `json:"s,omitempty"`
+	// time now() resolved to on the first page, so every page sees the same range
+	Now int64 `json:"n,omitempty"`
+}
+
+// Fingerprint identifies a query so a cursor can't be used with another one.
+func Fingerprint(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Token encodes p as an opaque cursor.
+func (p *Position) Token() string {
+	data, _ := json.Marshal(p)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParsePosition decodes a cursor token of the query with fingerprint.
+func ParsePosition(token, fingerprint string) (*Position, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var p Position
+	if err := json.Unmarshal(data, &p); err != nil || p.Skip < 0 {
+		return nil, ErrInvalidCursor
+	}
+	if p.Query != fingerprint {
+		return nil, fmt.Errorf("%w, it belongs to a different query", ErrInvalidCursor)
+	}
+	return &p, nil
+}
+
+// Page limits a read to Size points or rows, 0 reads everything, starting at
+// Resume when it is set.
+type Page struct {
+	Size   int
+	Resume *Position
+	// fingerprint and resolved now() put in the next cursor
+	Query string
+	Now   int64
+}
+
+// position returns a cursor position of the read of page
+func (p Page) position() *Position {
+	return &Position{Query: p.Query, Now: p.Now}
+}
+
+// PointPage is a page of the points of one series.
+type PointPage struct {
+	it   PointIterator
+	page Page
+	max  int
+
+	read    int
+	pending *ingestpb.Point
+	// position after the last point returned
+	last Position
+	next *Position
+	done bool
+}
+
+// PointPage returns the points of a single series key within [from, to],
+// oldest first, a page at a time. The page has to be closed.
+func (e *Engine) PointPage(ctx context.Context, key string, from, to int64, page Page) (*PointPage, error) {
+	skip := 0
+	if page.Resume != nil {
+		from, skip = max(from, page.Resume.Time), page.Resume.Skip
+	}
+	it, err := e.Points(ctx, key, from, to)
+	if err != nil {
+		return nil, err
+	}
+	p := &PointPage{it: it, page: page, max: e.MaxPoints, last: *page.position()}
+	p.last.Time = from
+	// skip the points at the resume time that the previous page returned
+	for ; skip > 0; skip-- {
+		point, err := it.Next()
+		if err != nil {
+			it.Close()
+			return nil, err
+		}
+		if point == nil || point.TimestampUnixNano != from {
+			p.pending, p.done = point, point == nil
+			break
+		}
+		p.last.Skip++
+	}
+	return p, nil
+}
+
+// Next returns the next point of the page, nil once the page is full or
+// there are no more points.
+func (p *PointPage) Next() (*ingestpb.Point, error) {
+	if p.done {
+		return nil, nil
+	}
+	point := p.pending
+	p.pending = nil
+	if point == nil {
+		var err error
+		if point, err = p.it.Next(); err != nil {
+			return nil, err
+		}
+	}
+	if point == nil {
+		p.done = true
+		return nil, nil
+	}
+	if p.page.Size > 0 && p.read >= p.page.Size {
+		// there is a point past the page, the next one starts after the last
+		// point returned
+		next := p.last
+		p.next, p.done = &next, true
+		return nil, nil
+	}
+	p.read++
+	if p.max > 0 && p.read > p.max {
+		return nil, &TooManyPointsError{Max: p.max}
+	}
+	if point.TimestampUnixNano == p.last.Time {
+		p.last.Skip++
+	} else {
+		p.last.Time, p.last.Skip = point.TimestampUnixNano, 1
+	}
+	return point, nil
+}
+
+// NextCursor returns the cursor of the next page once Next returned nil, the
+// empty string when the page was the last one.
+func (p *PointPage) NextCursor() string {
+	if p.next == nil {
+		return ""
+	}
+	return p.next.Token()
+}
+
+func (p *PointPage) Close() error { return p.it.Close() }
+
+// ReadPage returns the points of p and closes it.
+func ReadPage(p *PointPage) ([]*ingestpb.Point, error) {
+	defer p.Close()
+	var points []*ingestpb.Point
+	for {
+		point, err := p.Next()
+		if err != nil {
+			return nil, err
+		}
+		if point == nil {
+			return points, nil
+		}
+		points = append(points, point)
+	}
+}
+
+// guardIterator fails once more than max points were read through the
+// iterators sharing read
+type guardIterator struct {
+	PointIterator
+	read *int
+	max  int
+}
+
+func (g *guardIterator) Next() (*ingestpb.Point, error) {
+	point, err := g.PointIterator.Next()
+	if point != nil && g.max > 0 {
+		if *g.read++; *g.read > g.max {
+			return nil, &TooManyPointsError{Max: g.max}
+		}
+	}
+	return point, err
+}
@@ -0,0 +1,350 @@
+package query
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// Plan describes a query over one measurement, it is built by the tickql
+// planner and run by Execute.
+type Plan struct {
+	Measurement string
+	// series taking part, nil accepts every series of the measurement
+	Match Matcher
+	// points taking part, nil accepts every point
+	Filter func(*ingestpb.Point) bool
+	// inclusive, unix nanoseconds. math.MinInt64 and math.MaxInt64 leave the
+	// range open.
+	From, To int64
+
+	// raw field values to return, every field when both Fields and Calls are empty
+	Fields []string
+	Calls  []Call
+	// names of the selected fields or calls, without the time column
+	Columns []string
+	// width of the aggregate windows, 0 aggregates the whole range
+	Window int64
+	// windows are aligned to Origin, the unix epoch unless set
+	Origin int64
+	// what to return for windows without points
+	Fill Fill
+
+	// tags splitting the result into groups
+	GroupBy    []string
+	Descending bool
+	// applied to every group, a limit of 0 is unlimited
+	Limit, Offset int
+}
+
+// Group is the rows of the series sharing the values of the GROUP BY tags.
+// Rows is only valid until the next call to Cursor.Next.
+type Group struct {
+	Name string
+	Tags map[string]string
+	// the first column is the time
+	Columns []string
+	Rows    RowIterator
+}
+
+// Result is a Group read into memory.
+type Result struct {
+	Name    string            `json:"name"`
+	Tags    map[string]string `json:"tags,omitempty"`
+	Columns []string          `json:"columns"`
+	Values  [][]any           `json:"values"`
+}
+
+// Cursor streams the result of a plan group by group. Only the SSTable
+// blocks of the series in the current group are held in memory.
+type Cursor struct {
+	ctx     context.Context
+	plan    *Plan
+	scan    *scan
+	groups  []*seriesGroup
+	current RowIterator
+
+	page Page
+	// rows of the first group returned by the previous page
+	skip int
+	max  int
+	read int
+	next *Position
+}
+
+// seriesGroup is the series sharing the values of the GROUP BY tags
+type seriesGroup struct {
+	key    string
+	tags   map[string]string
+	series []*seriesRef
+}
+
+// Execute starts running plan. The cursor has to be closed.
+func (e *Engine) Execute(ctx context.Context, plan *Plan) (*Cursor, error) {
+	return e.ExecutePage(ctx, plan, Page{})
+}
+
+// ExecutePage starts running plan for a page of its rows. Resuming skips the
+// groups before the one the previous page stopped in and the rows of it that
+// were returned, which are computed again.
+func (e *Engine) ExecutePage(ctx context.Context, plan *Plan, page Page) (*Cursor, error) {
+	match := func(measurement string, tags map[string]string) bool {
+		return measurement == plan.Measurement && (plan.Match == nil || plan.Match(measurement, tags))
+	}
+	s, err := e.scan(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+	e.logger.DebugContext(ctx, "Execute started", "measurement", plan.Measurement, "series", len(s.series), "sstables", len(s.readers))
+	c := &Cursor{ctx: ctx, plan: plan, scan: s, groups: groupSeries(s.series, plan.GroupBy), page: page, max: e.MaxPoints}
+	if resume := page.Resume; resume != nil {
+		for len(c.groups) > 0 && c.groups[0].key < resume.Group {
+			c.groups = c.groups[1:]
+		}
+		if len(c.groups) > 0 && c.groups[0].key == resume.Group {
+			c.skip = resume.Skip
+		}
+	}
+	return c, nil
+}
+
+// Next returns the next group that has rows, nil once there are no more.
+func (c *Cursor) Next() (*Group, error) {
+	if c.current != nil {
+		c.current.Close()
+		c.current = nil
+	}
+	for len(c.groups) > 0 {
+		if err := c.ctx.Err(); err != nil {
+			return nil, err
+		}
+		g := c.groups[0]
+		if c.full() {
+			c.next = c.page.position()
+			c.next.Group = g.key
+			return nil, nil
+		}
+		c.groups = c.groups[1:]
+
+		columns, rows, err := c.rows(g)
+		if err != nil {
+			return nil, err
+		}
+		skip := c.skip
+		c.skip = 0
+		if skip > 0 {
+			rows = Limit(rows, skip, 0)
+		}
+		first, err := rows.Next()
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if first == nil {
+			rows.Close()
+			continue
+		}
+		c.current = &groupIterator{RowIterator: rows, cursor: c, key: g.key, first: first, returned: skip}
+		return &Group{
+			Name:    c.plan.Measurement,
+			Tags:    g.tags,
+			Columns: append([]string{"time"}, columns...),
+			Rows:    c.current,
+		}, nil
+	}
+	return nil, nil
+}
+
+// rows builds the iterators of a group: merge, filter, project or
+// aggregate, fill and limit.
+func (c *Cursor) rows(g *seriesGroup) ([]string, RowIterator, error) {
+	plan := c.plan
+	var blocks []*blockIterator
+	inputs := make([]PointIterator, 0, len(g.series))
+	for _, s := range g.series {
+		sources := s.sources(c.ctx, plan.From, plan.To, plan.Descending)
+		blocks = append(blocks, sources...)
+		series := make([]PointIterator, 0, len(sources))
+		for _, source := range sources {
+			series = append(series, source)
+		}
+		inputs = append(inputs, Merge(plan.Descending, series...))
+	}
+	var points PointIterator = Merge(plan.Descending, inputs...)
+	if plan.Filter != nil {
+		points = Filter(points, plan.Filter)
+	}
+
+	columns := plan.Columns
+	var rows RowIterator
+	switch {
+	case len(plan.Calls) > 0:
+		rows = AggregateWindows(points, plan.Calls, plan.Origin, plan.Window)
+		first, last := plan.From, plan.To
+		hasFirst, hasLast := first != math.MinInt64, last != math.MaxInt64
+		if plan.Descending {
+			first, last = last, first
+			hasFirst, hasLast = hasLast, hasFirst
+		}
+		rows = FillWindows(rows, plan.Fill, plan.Window,
+			WindowStart(first, plan.Origin, plan.Window), WindowStart(last, plan.Origin, plan.Window),
+			hasFirst, hasLast, plan.Descending)
+	case len(plan.Fields) > 0:
+		rows = Project(points, plan.Fields)
+	default:
+		// every field of the group, the blocks are read now instead of by the merge
+		var err error
+		if columns, err = fieldNames(blocks); err != nil {
+			points.Close()
+			return nil, nil, err
+		}
+		rows = Project(points, columns)
+	}
+	return columns, Limit(rows, plan.Offset, plan.Limit), nil
+}
+
+// Close releases the group being read and the SSTables.
+func (c *Cursor) Close() error {
+	if c.current != nil {
+		c.current.Close()
+		c.current = nil
+	}
+	return c.scan.Close()
+}
+
+// NextCursor returns the cursor of the next page once Next returned nil, the
+// empty string when the page was the last one.
+func (c *Cursor) NextCursor() string {
+	if c.next == nil {
+		return ""
+	}
+	return c.next.Token()
+}
+
+// full reports whether the page has all its rows
+func (c *Cursor) full() bool {
+	return c.page.Size > 0 && c.read >= c.page.Size
+}
+
+// groupIterator returns the row read by Cursor.Next first, stops when the
+// query is cancelled or the page is full and counts the rows returned
+type groupIterator struct {
+	RowIterator
+	cursor *Cursor
+	key    string
+	first  *Row
+	// rows of the group returned by this and previous pages
+	returned int
+}
+
+func (g *groupIterator) Next() (*Row, error) {
+	c := g.cursor
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	row := g.first
+	g.first = nil
+	if row == nil {
+		var err error
+		if row, err = g.RowIterator.Next(); row == nil || err != nil {
+			return nil, err
+		}
+	}
+	if c.full() {
+		// there is a row past the page, the next one resumes in this group
+		c.next = c.page.position()
+		c.next.Group, c.next.Skip = g.key, g.returned
+		c.groups = nil
+		return nil, nil
+	}
+	c.read++
+	if c.max > 0 && c.read > c.max {
+		return nil, &TooManyPointsError{Max: c.max}
+	}
+	g.returned++
+	return row, nil
+}
+
+// Collect reads every group of c into memory and closes it.
+func Collect(c *Cursor) ([]*Result, error) {
+	defer c.Close()
+	results := []*Result{}
+	for {
+		g, err := c.Next()
+		if err != nil {
+			return nil, err
+		}
+		if g == nil {
+			return results, nil
+		}
+		result := &Result{Name: g.Name, Tags: g.Tags, Columns: g.Columns}
+		for {
+			row, err := g.Rows.Next()
+			if err != nil {
+				return nil, err
+			}
+			if row == nil {
+				break
+			}
+			result.Values = append(result.Values, append([]any{row.Time}, row.Values...))
+		}
+		results = append(results, result)
+	}
+}
+
+// groupSeries splits series by the values of the tags in by, ordered by
+// those values.
+func groupSeries(series []*seriesRef, by []string) []*seriesGroup {
+	groups := make(map[string]*seriesGroup)
+	var keys []string
+	for _, s := range series {
+		var tags map[string]string
+		values := make([]string, 0, len(by))
+		if len(by) > 0 {
+			tags = make(map[string]string, len(by))
+		}
+		for _, tag := range by {
+			tags[tag] = s.tags[tag]
+			values = append(values, s.tags[tag])
+		}
+		key := strings.Join(values, "\x00")
+		g, ok := groups[key]
+		if !ok {
+			g = &seriesGroup{key: key, tags: tags}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		g.series = append(g.series, s)
+	}
+	sort.Strings(keys)
+
+	result := make([]*seriesGroup, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, groups[key])
+	}
+	return result
+}
+
+// fieldNames loads blocks and returns the sorted names of the fields in them
+func fieldNames(blocks []*blockIterator) ([]string, error) {
+	seen := make(map[string]bool)
+	var names []string
+	for _, b := range blocks {
+		if err := b.fill(); err != nil {
+			return nil, err
+		}
+		for _, point := range b.points {
+			for name := range point.Fields {
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
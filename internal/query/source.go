@@ -0,0 +1,211 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/heyyakash/tickdb/internal/auth"
+	memtable "github.com/heyyakash/tickdb/internal/mem-table"
+	"github.com/heyyakash/tickdb/internal/sstable"
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// seriesRef is a series and the places its points are stored
+type seriesRef struct {
+	key         string
+	measurement string
+	tags        map[string]string
+	// the key of the series in each table holding it, older tables were
+	// keyed with unsorted tags
+	tables []tableKey
+	// copied from the memtable when the query started
+	memtable []*ingestpb.Point
+}
+
+type tableKey struct {
+	reader *sstable.Reader
+	key    string
+}
+
+// scan is the series a query reads and the SSTables holding them, the tables
+// stay open until Close.
+type scan struct {
+	readers []*sstable.Reader
+	series  []*seriesRef
+}
+
+// ParseKey splits a series key into its measurement and tags.
+func ParseKey(key string) (string, map[string]string) {
+	parts := strings.Split(key, "|")
+	tags := make(map[string]string, len(parts)-1)
+	for _, part := range parts[1:] {
+		k, v, _ := strings.Cut(part, "=")
+		tags[k] = v
+	}
+	return parts[0], tags
+}
+
+// scan finds the series accepted by match without reading their points.
+// A nil match accepts every series. Measurements the caller's API token may
+// not read are left out.
+func (e *Engine) scan(ctx context.Context, match Matcher) (*scan, error) {
+	if token := auth.FromContext(ctx); token != nil && len(token.Measurements) > 0 {
+		inner := match
+		match = func(measurement string, tags map[string]string) bool {
+			return token.AllowsMeasurement(measurement) && (inner == nil || inner(measurement, tags))
+		}
+	}
+
+	s := &scan{}
+	found := make(map[string]*seriesRef)
+	// matched caches the verdict for every key seen, so match runs once per series
+	matched := make(map[string]bool)
+	lookup := func(key string) *seriesRef {
+		if ok, seen := matched[key]; seen {
+			if !ok {
+				return nil
+			}
+			return found[key]
+		}
+		measurement, tags := ParseKey(key)
+		ok := match == nil || match(measurement, tags)
+		matched[key] = ok
+		if !ok {
+			return nil
+		}
+		ref := &seriesRef{key: key, measurement: measurement, tags: tags}
+		found[key] = ref
+		s.series = append(s.series, ref)
+		return ref
+	}
+
+	tables, err := e.s.ListTables()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range tables {
+		if err := ctx.Err(); err != nil {
+			s.Close()
+			return nil, err
+		}
+		reader, err := sstable.OpenReader(path)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.readers = append(s.readers, reader)
+		for _, key := range reader.Keys() {
+			if ref := lookup(memtable.NormalizeKey(key)); ref != nil {
+				ref.tables = append(ref.tables, tableKey{reader: reader, key: key})
+			}
+		}
+	}
+
+	snapshot := e.m.SnapshotSeries(func(key string) bool { return lookup(key) != nil })
+	for key, points := range snapshot {
+		found[key].memtable = points
+	}
+
+	sort.Slice(s.series, func(i, j int) bool { return s.series[i].key < s.series[j].key })
+	return s, nil
+}
+
+func (s *scan) Close() error {
+	var errs []error
+	for _, reader := range s.readers {
+		errs = append(errs, reader.Close())
+	}
+	s.readers = nil
+	return errors.Join(errs...)
+}
+
+// sources returns an iterator for every place the points of the series are
+// stored, SSTables oldest first and the memtable last.
+func (r *seriesRef) sources(ctx context.Context, from, to int64, desc bool) []*blockIterator {
+	sources := make([]*blockIterator, 0, len(r.tables)+1)
+	for _, t := range r.tables {
+		sources = append(sources, &blockIterator{ctx: ctx, from: from, to: to, desc: desc, load: func() ([]*ingestpb.Point, error) {
+			entry, ok, err := t.reader.Get(t.key)
+			if err != nil || !ok {
+				return nil, err
+			}
+			return entry.Value, nil
+		}})
+	}
+	if len(r.memtable) > 0 {
+		points := r.memtable
+		sources = append(sources, &blockIterator{ctx: ctx, from: from, to: to, desc: desc, load: func() ([]*ingestpb.Point, error) {
+			return points, nil
+		}})
+	}
+	return sources
+}
+
+// iterator merges the sources of the series into one iterator
+func (r *seriesRef) iterator(ctx context.Context, from, to int64, desc bool) PointIterator {
+	sources := r.sources(ctx, from, to, desc)
+	inputs := make([]PointIterator, 0, len(sources))
+	for _, source := range sources {
+		inputs = append(inputs, source)
+	}
+	return Merge(desc, inputs...)
+}
+
+// blockIterator reads the points of one series from one SSTable block or the
+// memtable. The block is loaded on the first call to Next, so only the
+// blocks being merged are held in memory.
+type blockIterator struct {
+	ctx      context.Context
+	load     func() ([]*ingestpb.Point, error)
+	from, to int64
+	desc     bool
+
+	loaded bool
+	points []*ingestpb.Point
+}
+
+// fill loads the block, keeping the points within [from, to] sorted by
+// timestamp
+func (b *blockIterator) fill() error {
+	if b.loaded {
+		return nil
+	}
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+	points, err := b.load()
+	if err != nil {
+		return err
+	}
+	b.loaded = true
+
+	sort.SliceStable(points, func(i, j int) bool { return points[i].TimestampUnixNano < points[j].TimestampUnixNano })
+	start := sort.Search(len(points), func(i int) bool { return points[i].TimestampUnixNano >= b.from })
+	end := sort.Search(len(points), func(i int) bool { return points[i].TimestampUnixNano > b.to })
+	b.points = points[start:max(start, end)]
+	return nil
+}
+
+func (b *blockIterator) Next() (*ingestpb.Point, error) {
+	if err := b.fill(); err != nil {
+		return nil, err
+	}
+	if len(b.points) == 0 {
+		return nil, nil
+	}
+	if b.desc {
+		point := b.points[len(b.points)-1]
+		b.points = b.points[:len(b.points)-1]
+		return point, nil
+	}
+	point := b.points[0]
+	b.points = b.points[1:]
+	return point, nil
+}
+
+func (b *blockIterator) Close() error {
+	b.points = nil
+	return nil
+}
@@ -0,0 +1,395 @@
+// Package repair fixes the files a crash can leave damaged in the data
+// directories of a stopped server: WAL segments ending in a partly written
+// record and SSTables without a usable index. Nothing is thrown away, what
+// can't be kept is moved to a lost+found directory.
+package repair
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/heyyakash/tickdb/internal/backup"
+	"github.com/heyyakash/tickdb/internal/sstable"
+	"github.com/heyyakash/tickdb/internal/wal"
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// LostFound is the name of the directory in the data directory that damaged
+// files and the parts cut off them are moved to.
+const LostFound = "lost+found"
+
+// Actions taken on a file.
+const (
+	// the records after the last good one were cut off a WAL segment
+	ActionTruncated = "truncated"
+	// the newline after the last record of a WAL segment was missing
+	ActionTerminated = "terminated"
+	// an SSTable was rewritten with a new index from the entries it has
+	ActionRebuilt = "rebuilt"
+	// a file was moved to lost+found
+	ActionQuarantined = "quarantined"
+)
+
+// Database is where a database keeps its files.
+type Database struct {
+	Name       string
+	WALDir     string
+	SSTableDir string
+}
+
+// Options configures a repair.
+type Options struct {
+	Databases []Database
+	// directory damaged files are moved to, below it they are kept in
+	// <database>/<wal or sstable>/
+	LostFound string
+	// only report what would be done
+	DryRun bool
+}
+
+// Action is a change made to a file.
+type Action struct {
+	Database string `json:"database"`
+	Path     string `json:"path"`
+	Action   string `json:"action"`
+	Reason   string `json:"reason"`
+	// where the file or the part cut off it was moved, empty on a dry run
+	Saved string `json:"saved,omitempty"`
+}
+
+// Report lists the files checked and what was done to them.
+type Report struct {
+	Files   int      `json:"files"`
+	Actions []Action `json:"actions"`
+	DryRun  bool     `json:"dry_run"`
+}
+
+type repair struct {
+	opts   Options
+	report *Report
+}
+
+// Run checks the WAL segments and SSTables of every database and repairs the
+// damaged ones. The server must be stopped.
+func Run(opts Options) (*Report, error) {
+	r := &repair{opts: opts, report: &Report{Actions: []Action{}, DryRun: opts.DryRun}}
+	for _, db := range opts.Databases {
+		segments, err := list(db.WALDir, ".log")
+		if err != nil {
+			return r.report, err
+		}
+		for _, path := range segments {
+			r.report.Files++
+			if err := r.segment(db.Name, path); err != nil {
+				return r.report, fmt.Errorf("couldn't repair %s : %w", path, err)
+			}
+		}
+		tables, err := list(db.SSTableDir, ".sst")
+		if err != nil {
+			return r.report, err
+		}
+		for _, path := range tables {
+			r.report.Files++
+			if err := r.table(db.Name, path); err != nil {
+				return r.report, fmt.Errorf("couldn't repair %s : %w", path, err)
+			}
+		}
+	}
+	return r.report, nil
+}
+
+// list returns the files in dir with the suffix
+func list(dir, suffix string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var paths []string
+	for _, v := range entries {
+		if !v.IsDir() && strings.HasSuffix(v.Name(), suffix) {
+			paths = append(paths, filepath.Join(dir, v.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// segment cuts off a WAL segment after its last good record, keeping the
+// rest in lost+found
+func (r *repair) segment(db, path string) error {
+	name := filepath.Base(path)
+	// the WAL can't open an active segment not named after its start
+	if _, err := strconv.ParseInt(strings.Split(name, ".")[0], 10, 64); err != nil && strings.HasSuffix(name, ".new.log") {
+		return r.quarantine(db, path, backup.KindWAL, "the name doesn't start with a timestamp")
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	records := 0
+	end, scanErr := wal.ScanSegment(f, func(int, int64, *ingestpb.Point) error {
+		records++
+		return nil
+	})
+
+	if scanErr == nil {
+		if end == 0 {
+			return nil
+		}
+		// a record appended after a last record without a newline would
+		// share its line
+		var last [1]byte
+		if _, err := f.ReadAt(last[:], end-1); err != nil {
+			return err
+		}
+		if last[0] == '\n' {
+			return nil
+		}
+		if !r.opts.DryRun {
+			if _, err := f.WriteAt([]byte{'\n'}, end); err != nil {
+				return err
+			}
+			if err := f.Sync(); err != nil {
+				return err
+			}
+		}
+		r.add(Action{Database: db, Path: path, Action: ActionTerminated, Reason: "the last record has no newline"})
+		return nil
+	}
+
+	tail := make([]byte, info.Size()-end)
+	if _, err := f.ReadAt(tail, end); err != nil {
+		return err
+	}
+	lines := bytes.Count(tail, []byte{'\n'})
+	if !bytes.HasSuffix(tail, []byte{'\n'}) {
+		lines++
+	}
+	action := Action{
+		Database: db,
+		Path:     path,
+		Action:   ActionTruncated,
+		Reason:   fmt.Sprintf("%v, kept %d records and cut off %d lines (%d bytes)", scanErr, records, lines, len(tail)),
+	}
+	if !r.opts.DryRun {
+		saved, err := r.save(db, backup.KindWAL, name+".tail", bytes.NewReader(tail))
+		if err != nil {
+			return err
+		}
+		if err := f.Truncate(end); err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+		action.Saved = saved
+	}
+	r.add(action)
+	return nil
+}
+
+// table rewrites an SSTable whose footer or index can't be used, or whose
+// data block is damaged, with the entries that can be read and a new index.
+// The damaged file is moved to lost+found, it may be hard linked into a
+// backup.
+func (r *repair) table(db, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	reason := ""
+	end := info.Size()
+	var index map[string]int64
+	footer, err := sstable.ReadFooter(f, info.Size())
+	if err == nil {
+		end = footer.IndexOffset
+		index, err = sstable.ReadIndexBlock(f, footer)
+	}
+	if err != nil {
+		reason = err.Error()
+	}
+
+	entries := map[string]int64{}
+	scanned, scanErr := sstable.ScanEntries(f, end, func(offset int64, _ int32, entry *sstable.SSTableEntry) error {
+		entries[entry.Key] = offset
+		return nil
+	})
+	switch {
+	case scanErr != nil:
+		if reason != "" {
+			reason += ", "
+		}
+		reason += scanErr.Error()
+	case reason == "" && !sameIndex(index, entries):
+		reason = "the index doesn't match the entries"
+	}
+	if reason == "" {
+		return nil
+	}
+
+	if len(entries) == 0 {
+		return r.quarantine(db, path, backup.KindSSTable, reason+", no entry can be read")
+	}
+	action := Action{
+		Database: db,
+		Path:     path,
+		Action:   ActionRebuilt,
+		Reason:   fmt.Sprintf("%s, kept %d entries (%d of %d bytes)", reason, len(entries), scanned, info.Size()),
+	}
+	if !r.opts.DryRun {
+		saved, err := r.rebuild(db, path, scanned, entries)
+		if err != nil {
+			return err
+		}
+		action.Saved = saved
+	}
+	r.add(action)
+	return nil
+}
+
+func sameIndex(index, entries map[string]int64) bool {
+	if len(index) != len(entries) {
+		return false
+	}
+	for key, offset := range entries {
+		if at, ok := index[key]; !ok || at != offset {
+			return false
+		}
+	}
+	return true
+}
+
+// rebuild writes the first end bytes of the SSTable at path, which hold the
+// entries, followed by their index to a new file, moves the old one to
+// lost+found and the new one in its place
+func (r *repair) rebuild(db, path string, end int64, index map[string]int64) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, io.NewSectionReader(src, 0, end))
+	if err == nil {
+		err = sstable.WriteIndex(f, index)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("couldn't write the rebuilt sstable : %w", err)
+	}
+
+	saved, err := r.move(db, backup.KindSSTable, path)
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("couldn't put the rebuilt sstable in place, the damaged one is in %s : %w", saved, err)
+	}
+	return saved, nil
+}
+
+// quarantine moves a file that can't be repaired to lost+found
+func (r *repair) quarantine(db, path, kind, reason string) error {
+	action := Action{Database: db, Path: path, Action: ActionQuarantined, Reason: reason}
+	if !r.opts.DryRun {
+		saved, err := r.move(db, kind, path)
+		if err != nil {
+			return err
+		}
+		action.Saved = saved
+	}
+	r.add(action)
+	return nil
+}
+
+func (r *repair) add(a Action) {
+	r.report.Actions = append(r.report.Actions, a)
+}
+
+// lostPath returns a path in lost+found for a file named name, not taken by
+// an earlier repair
+func (r *repair) lostPath(db, kind, name string) (string, error) {
+	dir := filepath.Join(r.opts.LostFound, db, kind)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(path); errors.Is(err, os.ErrNotExist) {
+			return path, nil
+		} else if err != nil {
+			return "", err
+		}
+		path = filepath.Join(dir, name+"."+strconv.Itoa(i))
+	}
+}
+
+// move moves the file at path to lost+found
+func (r *repair) move(db, kind, path string) (string, error) {
+	dst, err := r.lostPath(db, kind, filepath.Base(path))
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(path, dst); err != nil {
+		return "", fmt.Errorf("couldn't move %s to %s : %w", path, r.opts.LostFound, err)
+	}
+	return dst, nil
+}
+
+// save writes rd to a new file in lost+found
+func (r *repair) save(db, kind, name string, rd io.Reader) (string, error) {
+	dst, err := r.lostPath(db, kind, name)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, rd)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return "", fmt.Errorf("couldn't save to %s : %w", r.opts.LostFound, err)
+	}
+	return dst, nil
+}
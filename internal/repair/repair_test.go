@@ -0,0 +1,283 @@
+package repair
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/heyyakash/tickdb/internal/sstable"
+	"github.com/heyyakash/tickdb/internal/wal"
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// setup returns the options to repair a database named db with its files in
+// a temporary directory
+func setup(t *testing.T) Options {
+	t.Helper()
+	dir := t.TempDir()
+	db := Database{Name: "db", WALDir: filepath.Join(dir, "wal"), SSTableDir: filepath.Join(dir, "sstable")}
+	for _, d := range []string{db.WALDir, db.SSTableDir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return Options{Databases: []Database{db}, LostFound: filepath.Join(dir, LostFound)}
+}
+
+// records returns WAL records for n points, each on its own line
+func records(t *testing.T, n int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	for i := 0; i < n; i++ {
+		data, err := json.Marshal(&ingestpb.Point{Measurement: "cpu", TimestampUnixNano: int64(i), Fields: map[string]string{"v": "1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(append(data, '\n'))
+	}
+	return buf.Bytes()
+}
+
+// writeTable writes an SSTable with a series per key to dir
+func writeTable(t *testing.T, dir string, keys ...string) string {
+	t.Helper()
+	series := make(map[string][]*ingestpb.Point)
+	for i, key := range keys {
+		series[key] = []*ingestpb.Point{{Measurement: key, TimestampUnixNano: int64(i), Fields: map[string]string{"v": "1"}}}
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	path, err := sstable.NewSSTableService(nil, dir, logger).WriteTable(series)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func run(t *testing.T, opts Options) *Report {
+	t.Helper()
+	report, err := Run(opts)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	return report
+}
+
+// checkAction checks the report holds the single action on path
+func checkAction(t *testing.T, report *Report, path, action string) Action {
+	t.Helper()
+	if len(report.Actions) != 1 {
+		t.Fatalf("got actions %+v, want one", report.Actions)
+	}
+	a := report.Actions[0]
+	if a.Database != "db" || a.Path != path || a.Action != action {
+		t.Fatalf("got action %+v, want %s on %s", a, action, path)
+	}
+	return a
+}
+
+func TestSegmentTornRecord(t *testing.T) {
+	opts := setup(t)
+	good := records(t, 3)
+	tail := []byte(`{"measurement":"cpu","timest` + "\n" + `{"measu`)
+	path := filepath.Join(opts.Databases[0].WALDir, "1700000000.new.log")
+	writeFile(t, path, append(bytes.Clone(good), tail...))
+
+	report := run(t, opts)
+	if report.Files != 1 {
+		t.Errorf("checked %d files, want 1", report.Files)
+	}
+	a := checkAction(t, report, path, ActionTruncated)
+	if !strings.Contains(a.Reason, "kept 3 records and cut off 2 lines") {
+		t.Errorf("reason is %q", a.Reason)
+	}
+	if got := readFile(t, path); !bytes.Equal(got, good) {
+		t.Errorf("segment is %q after the repair, want %q", got, good)
+	}
+	if _, err := wal.ScanSegment(bytes.NewReader(good), func(int, int64, *ingestpb.Point) error { return nil }); err != nil {
+		t.Errorf("repaired segment can't be read: %v", err)
+	}
+	if want := filepath.Join(opts.LostFound, "db", "wal", "1700000000.new.log.tail"); a.Saved != want {
+		t.Errorf("tail saved to %s, want %s", a.Saved, want)
+	}
+	if got := readFile(t, a.Saved); !bytes.Equal(got, tail) {
+		t.Errorf("saved tail is %q, want %q", got, tail)
+	}
+
+	// the repaired segment has nothing left to repair, and a second torn
+	// record doesn't overwrite the first tail
+	if report := run(t, opts); len(report.Actions) != 0 {
+		t.Errorf("repaired segment got actions %+v", report.Actions)
+	}
+	writeFile(t, path, append(bytes.Clone(good), tail...))
+	a = checkAction(t, run(t, opts), path, ActionTruncated)
+	if want := filepath.Join(opts.LostFound, "db", "wal", "1700000000.new.log.tail.1"); a.Saved != want {
+		t.Errorf("second tail saved to %s, want %s", a.Saved, want)
+	}
+}
+
+func TestSegmentMissingNewline(t *testing.T) {
+	opts := setup(t)
+	good := records(t, 2)
+	path := filepath.Join(opts.Databases[0].WALDir, "1700000000-1700000060.log")
+	writeFile(t, path, good[:len(good)-1])
+
+	a := checkAction(t, run(t, opts), path, ActionTerminated)
+	if a.Saved != "" {
+		t.Errorf("nothing is cut off, but %s was saved", a.Saved)
+	}
+	if got := readFile(t, path); !bytes.Equal(got, good) {
+		t.Errorf("segment is %q after the repair, want %q", got, good)
+	}
+	if _, err := os.Stat(opts.LostFound); !os.IsNotExist(err) {
+		t.Errorf("lost+found was created: %v", err)
+	}
+}
+
+func TestSegmentMisnamed(t *testing.T) {
+	opts := setup(t)
+	path := filepath.Join(opts.Databases[0].WALDir, "active.new.log")
+	writeFile(t, path, records(t, 1))
+
+	a := checkAction(t, run(t, opts), path, ActionQuarantined)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("segment is still in the WAL directory: %v", err)
+	}
+	if got := readFile(t, a.Saved); !bytes.Equal(got, records(t, 1)) {
+		t.Errorf("quarantined segment is %q", got)
+	}
+}
+
+func TestTableCorruptFooter(t *testing.T) {
+	opts := setup(t)
+	path := writeTable(t, opts.Databases[0].SSTableDir, "cpu|host=a", "cpu|host=b", "mem|host=a")
+	original := readFile(t, path)
+	damaged := bytes.Clone(original)
+	binary.LittleEndian.PutUint64(damaged[len(damaged)-8:], 1<<40)
+	writeFile(t, path, damaged)
+
+	a := checkAction(t, run(t, opts), path, ActionRebuilt)
+	if !strings.Contains(a.Reason, "kept 3 entries") {
+		t.Errorf("reason is %q", a.Reason)
+	}
+	// the index is rebuilt the same as the one written with the entries
+	if got := readFile(t, path); !bytes.Equal(got, original) {
+		t.Errorf("rebuilt sstable differs from the original")
+	}
+	if got := readFile(t, a.Saved); !bytes.Equal(got, damaged) {
+		t.Errorf("damaged sstable wasn't kept in %s", a.Saved)
+	}
+	r, err := sstable.OpenReader(path)
+	if err != nil {
+		t.Fatalf("rebuilt sstable can't be opened: %v", err)
+	}
+	defer r.Close()
+	for _, key := range []string{"cpu|host=a", "cpu|host=b", "mem|host=a"} {
+		if entry, ok, err := r.Get(key); err != nil || !ok || entry.Key != key {
+			t.Errorf("Get(%s) = %v, %v, %v", key, entry, ok, err)
+		}
+	}
+}
+
+func TestTableTruncated(t *testing.T) {
+	opts := setup(t)
+	path := writeTable(t, opts.Databases[0].SSTableDir, "cpu|host=a", "cpu|host=b")
+	data := readFile(t, path)
+	footer, err := sstable.ReadFooter(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the second entry is cut in half along with the index
+	writeFile(t, path, data[:footer.IndexOffset-10])
+
+	a := checkAction(t, run(t, opts), path, ActionRebuilt)
+	if !strings.Contains(a.Reason, "kept 1 entries") {
+		t.Errorf("reason is %q", a.Reason)
+	}
+	r, err := sstable.OpenReader(path)
+	if err != nil {
+		t.Fatalf("rebuilt sstable can't be opened: %v", err)
+	}
+	defer r.Close()
+	if keys := r.Keys(); len(keys) != 1 || keys[0] != "cpu|host=a" {
+		t.Errorf("rebuilt sstable has keys %v, want [cpu|host=a]", keys)
+	}
+}
+
+func TestTableUnreadable(t *testing.T) {
+	opts := setup(t)
+	path := filepath.Join(opts.Databases[0].SSTableDir, "1700000000-1700000060.sst")
+	writeFile(t, path, []byte("not an sstable"))
+
+	a := checkAction(t, run(t, opts), path, ActionQuarantined)
+	if want := filepath.Join(opts.LostFound, "db", "sstable", filepath.Base(path)); a.Saved != want {
+		t.Errorf("sstable moved to %s, want %s", a.Saved, want)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("sstable is still in the sstable directory: %v", err)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	opts := setup(t)
+	db := opts.Databases[0]
+	files := map[string][]byte{
+		filepath.Join(db.WALDir, "1700000000.new.log"):            append(records(t, 2), `{"mea`...),
+		filepath.Join(db.WALDir, "1700000000-1700000060.log"):     bytes.TrimSuffix(records(t, 1), []byte("\n")),
+		filepath.Join(db.WALDir, "active.new.log"):                records(t, 1),
+		filepath.Join(db.SSTableDir, "1700000000-1700000060.sst"): []byte("not an sstable"),
+	}
+	for path, data := range files {
+		writeFile(t, path, data)
+	}
+	table := writeTable(t, db.SSTableDir, "cpu|host=a")
+	data := readFile(t, table)
+	files[table] = data[:len(data)-1]
+	writeFile(t, table, files[table])
+
+	opts.DryRun = true
+	report := run(t, opts)
+	if !report.DryRun || report.Files != 5 || len(report.Actions) != 5 {
+		t.Fatalf("dry run checked %d files with actions %+v, want 5 of each", report.Files, report.Actions)
+	}
+	for _, a := range report.Actions {
+		if a.Saved != "" {
+			t.Errorf("dry run saved %s to %s", a.Path, a.Saved)
+		}
+	}
+	for path, want := range files {
+		if got := readFile(t, path); !bytes.Equal(got, want) {
+			t.Errorf("dry run changed %s", path)
+		}
+	}
+	if _, err := os.Stat(opts.LostFound); !os.IsNotExist(err) {
+		t.Errorf("dry run created lost+found: %v", err)
+	}
+
+	// the same files are repaired without the dry run
+	opts.DryRun = false
+	if report := run(t, opts); len(report.Actions) != 5 {
+		t.Errorf("repair took actions %+v, want 5", report.Actions)
+	}
+}
@@ -0,0 +1,301 @@
+// Package seriesindex keeps the set of series keys stored in a database,
+// in the memtable and in its SSTables, with the series count of every
+// measurement and tag.
+package seriesindex
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Verdict is the outcome of Admit and Check.
+type Verdict int
+
+const (
+	Admitted Verdict = iota
+	// the database holds its maximum number of series
+	DatabaseFull
+	// the measurement of the series holds its maximum number of series
+	MeasurementFull
+)
+
+type Index struct {
+	mu           sync.RWMutex
+	series       map[string]struct{}
+	measurements map[string]*measurement
+}
+
+type measurement struct {
+	series int
+	// series per tag value, by tag key
+	tags map[string]map[string]int
+}
+
+// TagCardinality describes a tag key of a measurement.
+type TagCardinality struct {
+	Measurement string `json:"measurement"`
+	Tag         string `json:"tag"`
+	// distinct values
+	Values int `json:"values"`
+	// series that have the tag
+	Series int `json:"series"`
+}
+
+// MeasurementCardinality describes a measurement and its tag keys, the tag
+// with the most values first.
+type MeasurementCardinality struct {
+	Measurement string           `json:"measurement"`
+	Series      int              `json:"series"`
+	Tags        []TagCardinality `json:"tags"`
+}
+
+func New() *Index {
+	return &Index{
+		series:       make(map[string]struct{}),
+		measurements: make(map[string]*measurement),
+	}
+}
+
+// parseKey splits a series key built by memtable.Key into its measurement
+// and tags.
+func parseKey(key string) (string, [][2]string) {
+	parts := strings.Split(key, "|")
+	tags := make([][2]string, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		k, v, _ := strings.Cut(part, "=")
+		tags = append(tags, [2]string{k, v})
+	}
+	return parts[0], tags
+}
+
+// add records a key that isn't in the index yet, the caller holds the lock
+func (i *Index) add(key string) {
+	i.series[key] = struct{}{}
+	name, tags := parseKey(key)
+	m, ok := i.measurements[name]
+	if !ok {
+		m = &measurement{tags: make(map[string]map[string]int)}
+		i.measurements[name] = m
+	}
+	m.series++
+	for _, tag := range tags {
+		values, ok := m.tags[tag[0]]
+		if !ok {
+			values = make(map[string]int)
+			m.tags[tag[0]] = values
+		}
+		values[tag[1]]++
+	}
+}
+
+// Add records key, it reports whether the series is new.
+func (i *Index) Add(key string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, ok := i.series[key]; ok {
+		return false
+	}
+	i.add(key)
+	return true
+}
+
+// Admit records key unless it is a new series and the index already holds
+// max series, or its measurement holds maxPerMeasurement series. A limit of
+// 0 is unlimited. added reports whether the series is new.
+func (i *Index) Admit(key string, max, maxPerMeasurement int) (v Verdict, added bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, ok := i.series[key]; ok {
+		return Admitted, false
+	}
+	if v := i.check(key, max, maxPerMeasurement, 0, 0); v != Admitted {
+		return v, false
+	}
+	i.add(key)
+	return Admitted, true
+}
+
+// Remove forgets key, for a series that was admitted but whose point wasn't
+// written after all.
+func (i *Index) Remove(key string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, ok := i.series[key]; !ok {
+		return
+	}
+	delete(i.series, key)
+	name, tags := parseKey(key)
+	m := i.measurements[name]
+	if m.series--; m.series == 0 {
+		delete(i.measurements, name)
+		return
+	}
+	for _, tag := range tags {
+		values := m.tags[tag[0]]
+		if values[tag[1]]--; values[tag[1]] == 0 {
+			delete(values, tag[1])
+		}
+		if len(values) == 0 {
+			delete(m.tags, tag[0])
+		}
+	}
+}
+
+// Check is Admit without recording the key.
+func (i *Index) Check(key string, max, maxPerMeasurement int) Verdict {
+	return i.CheckPending(key, max, maxPerMeasurement, 0, 0)
+}
+
+// CheckPending is Check for a writer holding new series it hasn't recorded
+// yet, they count toward the limits. measurementPending of the pending series
+// are in the measurement of key.
+func (i *Index) CheckPending(key string, max, maxPerMeasurement, pending, measurementPending int) Verdict {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if _, ok := i.series[key]; ok {
+		return Admitted
+	}
+	return i.check(key, max, maxPerMeasurement, pending, measurementPending)
+}
+
+func (i *Index) check(key string, max, maxPerMeasurement, pending, measurementPending int) Verdict {
+	if max > 0 && len(i.series)+pending >= max {
+		return DatabaseFull
+	}
+	if maxPerMeasurement > 0 {
+		name, _ := parseKey(key)
+		n := measurementPending
+		if m, ok := i.measurements[name]; ok {
+			n += m.series
+		}
+		if n >= maxPerMeasurement {
+			return MeasurementFull
+		}
+	}
+	return Admitted
+}
+
+func (i *Index) Contains(key string) bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	_, ok := i.series[key]
+	return ok
+}
+
+// Keys returns every series key, sorted.
+func (i *Index) Keys() []string {
+	i.mu.RLock()
+	keys := make([]string, 0, len(i.series))
+	for key := range i.series {
+		keys = append(keys, key)
+	}
+	i.mu.RUnlock()
+	sort.Strings(keys)
+	return keys
+}
+
+// Len returns the number of series.
+func (i *Index) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return len(i.series)
+}
+
+// Reset replaces the content of the index with keys.
+func (i *Index) Reset(keys []string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.series = make(map[string]struct{}, len(keys))
+	i.measurements = make(map[string]*measurement)
+	for _, key := range keys {
+		if _, ok := i.series[key]; !ok {
+			i.add(key)
+		}
+	}
+}
+
+// TopTag returns the tag key with the most distinct values, in measurement
+// or in every measurement when it is empty. It is the likely cause of a
+// series explosion. ok is false when there are no tags.
+func (i *Index) TopTag(name string) (top TagCardinality, ok bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	for mName, m := range i.measurements {
+		if name != "" && mName != name {
+			continue
+		}
+		for tag, values := range m.tags {
+			if !ok || len(values) > top.Values {
+				top = tagCardinality(mName, tag, values)
+				ok = true
+			}
+		}
+	}
+	return top, ok
+}
+
+func tagCardinality(name, tag string, values map[string]int) TagCardinality {
+	t := TagCardinality{Measurement: name, Tag: tag, Values: len(values)}
+	for _, n := range values {
+		t.Series += n
+	}
+	return t
+}
+
+// Measurements returns every measurement accepted by allow (nil accepts all),
+// the one with the most series first.
+func (i *Index) Measurements(allow func(string) bool) []MeasurementCardinality {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	result := make([]MeasurementCardinality, 0, len(i.measurements))
+	for name, m := range i.measurements {
+		if allow != nil && !allow(name) {
+			continue
+		}
+		mc := MeasurementCardinality{Measurement: name, Series: m.series, Tags: make([]TagCardinality, 0, len(m.tags))}
+		for tag, values := range m.tags {
+			mc.Tags = append(mc.Tags, tagCardinality(name, tag, values))
+		}
+		sortTags(mc.Tags)
+		result = append(result, mc)
+	}
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].Series != result[b].Series {
+			return result[a].Series > result[b].Series
+		}
+		return result[a].Measurement < result[b].Measurement
+	})
+	return result
+}
+
+// sortTags orders tags by distinct values, most first
+func sortTags(tags []TagCardinality) {
+	sort.Slice(tags, func(a, b int) bool {
+		if tags[a].Values != tags[b].Values {
+			return tags[a].Values > tags[b].Values
+		}
+		if tags[a].Measurement != tags[b].Measurement {
+			return tags[a].Measurement < tags[b].Measurement
+		}
+		return tags[a].Tag < tags[b].Tag
+	})
+}
+
+// TagKeys returns the tag keys of every measurement accepted by allow (nil
+// accepts all), the one with the most values first.
+func (i *Index) TagKeys(allow func(string) bool) []TagCardinality {
+	var tags []TagCardinality
+	for _, m := range i.Measurements(allow) {
+		tags = append(tags, m.Tags...)
+	}
+	sortTags(tags)
+	return tags
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/heyyakash/tickdb/internal/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" value.
+func bearerToken(header string) string {
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// RequireScope authenticates requests with an API token and rejects tokens
+// without scope. The token is added to the request context.
+func RequireScope(store *auth.Store, scope auth.Scope) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		token, err := store.Authenticate(bearerToken(ctx.GetHeader("Authorization")))
+		if err != nil {
+			ctx.Header("WWW-Authenticate", `Bearer realm="tickdb"`)
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if !token.Allows(scope) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "token lacks the " + string(scope) + " scope"})
+			return
+		}
+		ctx.Request = ctx.Request.WithContext(auth.WithToken(ctx.Request.Context(), token))
+		ctx.Next()
+	}
+}
+
+// scope needed by each grpc service, services not listed (grpc health) are
+// public
+var grpcScopes = map[string]auth.Scope{
+	"/tickdb.ingest.InjestService/":                             auth.ScopeWrite,
+	"/opentelemetry.proto.collector.metrics.v1.MetricsService/": auth.ScopeWrite,
+	"/tickdb.query.QueryService/":                               auth.ScopeRead,
+}
+
+// grpcAuthenticate checks the token sent in the authorization metadata against
+// the scope needed by method and returns the context carrying it.
+func grpcAuthenticate(ctx context.Context, store *auth.Store, method string) (context.Context, error) {
+	var scope auth.Scope
+	for prefix, s := range grpcScopes {
+		if strings.HasPrefix(method, prefix) {
+			scope = s
+		}
+	}
+	if scope == "" {
+		return ctx, nil
+	}
+
+	var header string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			header = values[0]
+		}
+	}
+	token, err := store.Authenticate(bearerToken(header))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if !token.Allows(scope) {
+		return nil, status.Errorf(codes.PermissionDenied, "token lacks the %s scope", scope)
+	}
+	return auth.WithToken(ctx, token), nil
+}
+
+// AuthUnaryInterceptor is the grpc counterpart of RequireScope.
+func AuthUnaryInterceptor(store *auth.Store) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := grpcAuthenticate(ctx, store, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// AuthStreamInterceptor is the grpc counterpart of RequireScope for streams.
+func AuthStreamInterceptor(store *auth.Store) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := grpcAuthenticate(ss.Context(), store, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// TokenAdminServer serves the endpoints for managing API tokens.
+type TokenAdminServer struct {
+	store *auth.Store
+}
+
+type CreateTokenRequest struct {
+	Name         string          `json:"name"`
+	Scopes       []string        `json:"scopes"`
+	Measurements []string        `json:"measurements"`
+	RateLimit    *auth.RateLimit `json:"rate_limit"`
+}
+
+// TokenInfo is a token without its hash.
+type TokenInfo struct {
+	ID           string          `json:"id"`
+	Name         string          `json:"name"`
+	Scopes       []auth.Scope    `json:"scopes"`
+	Measurements []string        `json:"measurements,omitempty"`
+	RateLimit    *auth.RateLimit `json:"rate_limit,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+type CreateTokenResponse struct {
+	TokenInfo
+	// the secret is only returned here, it can't be looked up later
+	Token string `json:"token"`
+}
+
+func NewTokenAdminServer(store *auth.Store) *TokenAdminServer {
+	return &TokenAdminServer{store: store}
+}
+
+// SetupHandlers registers the admin endpoints, every one needs the admin scope.
+func (a *TokenAdminServer) SetupHandlers(r gin.IRouter) {
+	admin := r.Group("admin", RequireScope(a.store, auth.ScopeAdmin))
+	admin.GET("tokens", a.handleList)
+	admin.POST("tokens", a.handleCreate)
+	admin.DELETE("tokens/:id", a.handleRevoke)
+}
+
+func tokenInfo(t auth.Token) TokenInfo {
+	return TokenInfo{
+		ID:           t.ID,
+		Name:         t.Name,
+		Scopes:       t.Scopes,
+		Measurements: t.Measurements,
+		RateLimit:    t.RateLimit,
+		CreatedAt:    t.CreatedAt,
+	}
+}
+
+func (a *TokenAdminServer) handleList(ctx *gin.Context) {
+	tokens := a.store.List()
+	infos := make([]TokenInfo, 0, len(tokens))
+	for _, t := range tokens {
+		infos = append(infos, tokenInfo(t))
+	}
+	ctx.JSON(http.StatusOK, gin.H{"tokens": infos})
+}
+
+func (a *TokenAdminServer) handleCreate(ctx *gin.Context) {
+	var body CreateTokenRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Request Body"})
+		return
+	}
+
+	scopes := make([]auth.Scope, 0, len(body.Scopes))
+	for _, s := range body.Scopes {
+		scope, err := auth.ParseScope(s)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		scopes = append(scopes, scope)
+	}
+
+	token, secret, err := a.store.Create(body.Name, scopes, body.Measurements, body.RateLimit)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusCreated, CreateTokenResponse{TokenInfo: tokenInfo(*token), Token: secret})
+}
+
+func (a *TokenAdminServer) handleRevoke(ctx *gin.Context) {
+	err := a.store.Revoke(ctx.Param("id"))
+	if errors.Is(err, auth.ErrTokenNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/heyyakash/tickdb/internal/auth"
+	"github.com/heyyakash/tickdb/internal/backup"
+	"github.com/heyyakash/tickdb/internal/database"
+)
+
+// BackupRequest is the body of a backup, every field is optional.
+type BackupRequest struct {
+	// databases to back up, all of them when empty
+	Databases []string `json:"databases"`
+	// manifest of an earlier backup, SSTables stored in it are left out
+	Base *backup.Manifest `json:"base"`
+	// directory to write the backup to on the server, relative to its
+	// backup directory. When empty the backup is sent back as a gzipped
+	// tarball.
+	Dir string `json:"dir"`
+}
+
+// BackupServer serves consistent backups of running databases.
+type BackupServer struct {
+	catalog *database.Catalog
+	store   *auth.Store
+	// directory backups with a dir are written under, they are refused
+	// when empty
+	root   string
+	logger *slog.Logger
+}
+
+// NewBackupServer returns the backup endpoint. With a token store it needs
+// the admin scope. Backups are only written on the server under root.
+func NewBackupServer(catalog *database.Catalog, store *auth.Store, root string, logger *slog.Logger) *BackupServer {
+	return &BackupServer{catalog: catalog, store: store, root: root, logger: logger}
+}
+
+func (b *BackupServer) SetupHandlers(r gin.IRouter) {
+	admin := r.Group("backup")
+	if b.store != nil {
+		admin.Use(RequireScope(b.store, auth.ScopeAdmin))
+	}
+	admin.POST("", b.handleBackup)
+}
+
+func (b *BackupServer) handleBackup(ctx *gin.Context) {
+	var body BackupRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Request Body"})
+		return
+	}
+
+	if body.Dir != "" {
+		if b.root == "" {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "server side backups are disabled, set backup_dir to allow them"})
+			return
+		}
+		if !filepath.IsLocal(body.Dir) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "dir must be a relative path inside the backup directory"})
+			return
+		}
+		target, err := backup.NewDirTarget(filepath.Join(b.root, body.Dir))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		m, err := b.catalog.Backup(body.Databases, body.Base, target)
+		if err != nil {
+			ctx.JSON(backupStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusOK, m)
+		return
+	}
+
+	ctx.Header("Content-Type", "application/gzip")
+	ctx.Header("Content-Disposition", `attachment; filename="tickdb-backup.tar.gz"`)
+	_, err := b.catalog.Backup(body.Databases, body.Base, backup.NewTarTarget(ctx.Writer))
+	if err == nil {
+		return
+	}
+	b.logger.ErrorContext(ctx.Request.Context(), "Backup failed", "error", err)
+	if !ctx.Writer.Written() {
+		ctx.Header("Content-Disposition", "")
+		ctx.JSON(backupStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	// the tarball is cut off without its manifest
+	if conn, _, hijackErr := ctx.Writer.Hijack(); hijackErr == nil {
+		conn.Close()
+	}
+}
+
+func backupStatus(err error) int {
+	if errors.Is(err, database.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/heyyakash/tickdb/internal/auth"
+	"github.com/heyyakash/tickdb/internal/database"
+	"github.com/heyyakash/tickdb/internal/limits"
+	seriesindex "github.com/heyyakash/tickdb/internal/series-index"
+)
+
+// number of measurements and tag keys listed when the request doesn't say
+const defaultCardinalityLimit = 10
+
+// CardinalityServer reports which measurements and tags hold the series of
+// a database.
+type CardinalityServer struct {
+	catalog *database.Catalog
+	logger  *slog.Logger
+}
+
+type CardinalityResponse struct {
+	Database string `json:"database"`
+	// series in the memtable and SSTables
+	Series         int           `json:"series"`
+	MemTableSeries int           `json:"memtable_series"`
+	SSTableSeries  int           `json:"sstable_series"`
+	Limits         limits.Limits `json:"limits"`
+	// the measurements with the most series, with their tags
+	Measurements []seriesindex.MeasurementCardinality `json:"measurements"`
+	// the tag keys with the most distinct values, across measurements
+	TagKeys []seriesindex.TagCardinality `json:"tag_keys"`
+	Errors  []string                     `json:"errors,omitempty"`
+}
+
+func NewCardinalityServer(catalog *database.Catalog, logger *slog.Logger) *CardinalityServer {
+	return &CardinalityServer{catalog: catalog, logger: logger}
+}
+
+func (c *CardinalityServer) SetupHandlers(r gin.IRouter) {
+	r.GET("/cardinality", c.handleCardinality)
+}
+
+// handleCardinality lists the top measurements and tag keys by series. The
+// limit query parameter sets how many are listed, measurement restricts the
+// report to one measurement. Measurements the token can't read are left out.
+func (c *CardinalityServer) handleCardinality(ctx *gin.Context) {
+	db, err := c.catalog.Resolve(ctx.Request.Context(), "")
+	if err != nil {
+		ctx.JSON(databaseStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := defaultCardinalityLimit
+	if v := ctx.Query("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit value"})
+			return
+		}
+	}
+	only := ctx.Query("measurement")
+	allow := func(m string) bool {
+		return (only == "" || m == only) && auth.AllowsMeasurement(ctx.Request.Context(), m)
+	}
+
+	resp := CardinalityResponse{Database: db.Name, Limits: db.Limits()}
+	measurements := db.Series.Measurements(allow)
+	for _, m := range measurements {
+		resp.Series += m.Series
+	}
+	resp.Measurements = measurements[:min(limit, len(measurements))]
+	tags := db.Series.TagKeys(allow)
+	resp.TagKeys = tags[:min(limit, len(tags))]
+
+	resp.MemTableSeries = db.MemTable.CountSeries(func(key string) bool { return allow(seriesMeasurement(key)) })
+	keys, err := db.SSTables.SeriesKeys()
+	if err != nil {
+		resp.Errors = append(resp.Errors, "sstable : "+err.Error())
+	}
+	for _, key := range keys {
+		if allow(seriesMeasurement(key)) {
+			resp.SSTableSeries++
+		}
+	}
+
+	if len(resp.Errors) > 0 {
+		c.logger.WarnContext(ctx.Request.Context(), "Cardinality is incomplete", "errors", resp.Errors)
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// seriesMeasurement returns the measurement of a series key
+func seriesMeasurement(key string) string {
+	name, _, _ := strings.Cut(key, "|")
+	return name
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/heyyakash/tickdb/internal/auth"
+	"github.com/heyyakash/tickdb/internal/database"
+	"github.com/heyyakash/tickdb/internal/limits"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// time given to a dropped database to stop its pipeline
+const dropTimeout = 10 * time.Second
+
+// SelectDatabase puts the database named by the :database path parameter or
+// the X-TickDB-Database header into the request context. The path wins.
+func SelectDatabase() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		name := ctx.Param("database")
+		if name == "" {
+			name = ctx.GetHeader(database.Header)
+		}
+		if name != "" {
+			ctx.Request = ctx.Request.WithContext(database.WithName(ctx.Request.Context(), name))
+		}
+		ctx.Next()
+	}
+}
+
+// grpcDatabase puts the database named in the x-tickdb-database metadata into
+// the context. A database field in the request takes precedence over it.
+func grpcDatabase(ctx context.Context) context.Context {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(strings.ToLower(database.Header)); len(values) > 0 && values[0] != "" {
+			return database.WithName(ctx, values[0])
+		}
+	}
+	return ctx
+}
+
+// DatabaseUnaryInterceptor is the grpc counterpart of SelectDatabase.
+func DatabaseUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(grpcDatabase(ctx), req)
+}
+
+// DatabaseStreamInterceptor is the grpc counterpart of SelectDatabase for streams.
+func DatabaseStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &contextStream{ServerStream: ss, ctx: grpcDatabase(ss.Context())})
+}
+
+// databaseStatus is the http status for an error resolving a database.
+func databaseStatus(err error) int {
+	if errors.Is(err, database.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// databaseError maps an error resolving a database to a grpc status.
+func databaseError(err error) error {
+	if errors.Is(err, database.ErrNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// DatabaseAdminServer serves the endpoints for creating, listing and dropping
+// databases.
+type DatabaseAdminServer struct {
+	catalog *database.Catalog
+	store   *auth.Store
+}
+
+type CreateDatabaseRequest struct {
+	Name string `json:"name"`
+	// Go duration like 720h, empty or 0s keeps data forever
+	Retention string `json:"retention"`
+	// rate limits and quotas, the configured defaults when not set
+	Limits *limits.Limits `json:"limits"`
+}
+
+type DatabaseInfo struct {
+	Name      string        `json:"name"`
+	Retention string        `json:"retention"`
+	Limits    limits.Limits `json:"limits"`
+	// not set for the default database
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// NewDatabaseAdminServer returns the database endpoints. With a token store
+// creating and dropping databases needs the admin scope.
+func NewDatabaseAdminServer(catalog *database.Catalog, store *auth.Store) *DatabaseAdminServer {
+	return &DatabaseAdminServer{catalog: catalog, store: store}
+}
+
+func (d *DatabaseAdminServer) SetupHandlers(r gin.IRouter) {
+	r.GET("databases", d.handleList)
+	admin := r.Group("databases")
+	if d.store != nil {
+		admin.Use(RequireScope(d.store, auth.ScopeAdmin))
+	}
+	admin.POST("", d.handleCreate)
+	admin.DELETE(":name", d.handleDrop)
+}
+
+func databaseInfo(db *database.Database) DatabaseInfo {
+	info := DatabaseInfo{Name: db.Name, Retention: db.Retention.String(), Limits: db.Limits()}
+	if !db.CreatedAt.IsZero() {
+		info.CreatedAt = &db.CreatedAt
+	}
+	return info
+}
+
+func (d *DatabaseAdminServer) handleList(ctx *gin.Context) {
+	dbs := d.catalog.List()
+	infos := make([]DatabaseInfo, 0, len(dbs))
+	for _, db := range dbs {
+		infos = append(infos, databaseInfo(db))
+	}
+	ctx.JSON(http.StatusOK, gin.H{"databases": infos})
+}
+
+func (d *DatabaseAdminServer) handleCreate(ctx *gin.Context) {
+	var body CreateDatabaseRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Request Body"})
+		return
+	}
+
+	var retention time.Duration
+	if body.Retention != "" {
+		var err error
+		if retention, err = time.ParseDuration(body.Retention); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid retention value"})
+			return
+		}
+	}
+
+	db, err := d.catalog.Create(body.Name, retention, body.Limits)
+	if errors.Is(err, database.ErrExists) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusCreated, databaseInfo(db))
+}
+
+func (d *DatabaseAdminServer) handleDrop(ctx *gin.Context) {
+	dropCtx, cancel := context.WithTimeout(ctx.Request.Context(), dropTimeout)
+	defer cancel()
+
+	err := d.catalog.Drop(dropCtx, ctx.Param("name"))
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrDropDefault):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		ctx.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/heyyakash/tickdb/internal/database"
+	"github.com/heyyakash/tickdb/internal/export"
+	"github.com/heyyakash/tickdb/internal/query"
+)
+
+// NextCursorHeader carries the cursor of the next page of an exported table
+const NextCursorHeader = "X-TickDB-Next-Cursor"
+
+// exportRow receives a row of a result being exported, values holds the value
+// of each of columns
+type exportRow func(ts int64, tags map[string]string, columns []string, values []any) error
+
+// exportSource reads a page of a result and returns the cursor of the next
+// one. It is called twice, once to find the columns of the table and once to
+// write them.
+type exportSource func(emit exportRow) (string, error)
+
+// exportSchema reads src to find the columns of the table and their types
+func exportSchema(src exportSource) (*export.Schema, string, error) {
+	schema := export.NewSchema()
+	next, err := src(func(_ int64, tags map[string]string, columns []string, values []any) error {
+		schema.Add(tags, columns, values)
+		return nil
+	})
+	return schema, next, err
+}
+
+// writeExport reads src again and writes it as a table in format. When that
+// fails the connection is closed, so the client doesn't take the part of the
+// table it got for all of it.
+func writeExport(ctx *gin.Context, format string, schema *export.Schema, next string, src exportSource) error {
+	ctx.Header("Content-Type", export.ContentType(format))
+	if next != "" {
+		ctx.Header(NextCursorHeader, next)
+	}
+	ctx.Status(http.StatusOK)
+
+	w, err := export.NewWriter(format, ctx.Writer, schema)
+	if err == nil {
+		rows := 0
+		_, err = src(func(ts int64, tags map[string]string, columns []string, values []any) error {
+			if err := w.Write(ts, tags, columns, values); err != nil {
+				return err
+			}
+			if rows++; rows%chunkSize == 0 {
+				ctx.Writer.Flush()
+			}
+			return nil
+		})
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		if conn, _, hijackErr := ctx.Writer.Hijack(); hijackErr == nil {
+			conn.Close()
+		}
+	}
+	return err
+}
+
+// pointSource reads the points of a series key, with a column per tag and
+// field
+func pointSource(ctx context.Context, db *database.Database, key string, from, to int64, page query.Page) exportSource {
+	return func(emit exportRow) (string, error) {
+		points, err := db.Query.PointPage(ctx, key, from, to, page)
+		if err != nil {
+			return "", err
+		}
+		defer points.Close()
+
+		var columns []string
+		var values []any
+		for {
+			point, err := points.Next()
+			if err != nil {
+				return "", err
+			}
+			if point == nil {
+				return points.NextCursor(), nil
+			}
+			columns = columns[:0]
+			for name := range point.Fields {
+				columns = append(columns, name)
+			}
+			sort.Strings(columns)
+			values = values[:0]
+			for _, name := range columns {
+				values = append(values, query.FieldValue(point.Fields[name]))
+			}
+			if err := emit(point.TimestampUnixNano, point.Tag, columns, values); err != nil {
+				return "", err
+			}
+		}
+	}
+}
+
+// sqlSource reads the groups of a plan, with a column per GROUP BY tag and
+// selected field or aggregate
+func sqlSource(ctx context.Context, db *database.Database, plan *query.Plan, page query.Page) exportSource {
+	return func(emit exportRow) (string, error) {
+		cursor, err := db.Query.ExecutePage(ctx, plan, page)
+		if err != nil {
+			return "", err
+		}
+		defer cursor.Close()
+
+		for {
+			g, err := cursor.Next()
+			if err != nil {
+				return "", err
+			}
+			if g == nil {
+				return cursor.NextCursor(), nil
+			}
+			for {
+				row, err := g.Rows.Next()
+				if err != nil {
+					return "", err
+				}
+				if row == nil {
+					break
+				}
+				// the first column of a group is the time
+				if err := emit(row.Time, g.Tags, g.Columns[1:], row.Values); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+}
@@ -2,31 +2,48 @@ package server
 
 import (
 	"context"
-	"log"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
 
+	"github.com/heyyakash/tickdb/internal/database"
 	ingestpipeline "github.com/heyyakash/tickdb/internal/ingest-pipeline"
 	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
 )
 
+const (
+	// a StreamWrite ack is sent after this many points or this much time,
+	// whichever comes first
+	streamAckEvery    = 1000
+	streamAckInterval = time.Second
+)
+
 type IngestService struct {
 	ingestpb.UnimplementedInjestServiceServer
-	pipelineService *ingestpipeline.PipelineService
+	catalog *database.Catalog
+	logger  *slog.Logger
 }
 
-func NewInjestServer(p *ingestpipeline.PipelineService) *IngestService {
-	return &IngestService{pipelineService: p}
+func NewInjestServer(catalog *database.Catalog, logger *slog.Logger) *IngestService {
+	return &IngestService{catalog: catalog, logger: logger}
 }
 
 // Handle Single Data Points
 func (i *IngestService) Write(ctx context.Context, req *ingestpb.WriteRequest) (*ingestpb.WriteResponse, error) {
-	if req.GetPoint() == nil {
-		return &ingestpb.WriteResponse{Rejected: 1, Error: "no measurement provided", Accepted: 0}, nil
+	db, err := i.catalog.Resolve(ctx, req.GetDatabase())
+	if err != nil {
+		return nil, databaseError(err)
 	}
-
-	err := i.pipelineService.AddDataPoint(req.GetPoint())
+	err = db.Pipeline.Ingest(ctx, req.GetPoint(), req.GetPrecision(), time.Now())
 	if err != nil {
-		log.Printf("Pipeline error : %v", err)
-		return &ingestpb.WriteResponse{Rejected: 1, Error: err.Error(), Accepted: 0}, err
+		rejection := ingestpipeline.Rejection(0, err)
+		resp := &ingestpb.WriteResponse{Rejected: 1, Error: err.Error(), Accepted: 0, Rejections: []*ingestpb.Rejection{rejection}}
+		if rejection.Code != ingestpb.RejectCode_REJECT_CODE_PIPELINE_UNAVAILABLE {
+			return resp, nil
+		}
+		i.logger.WarnContext(ctx, "Pipeline error", "error", err)
+		return resp, err
 	}
 
 	return &ingestpb.WriteResponse{Accepted: 1, Rejected: 0}, nil
@@ -35,20 +52,113 @@ func (i *IngestService) Write(ctx context.Context, req *ingestpb.WriteRequest) (
 // Handles batched data points
 func (i *IngestService) BatchWrite(ctx context.Context, req *ingestpb.BatchWriteRequest) (*ingestpb.WriteResponse, error) {
 	var accepted, rejected uint64
+	var rejections []*ingestpb.Rejection
+	receivedAt := time.Now()
 
-	for _, point := range req.GetPoints() {
-		if point == nil {
-			rejected += 1
-		}
-		if err := i.pipelineService.AddDataPoint(point); err != nil {
+	db, err := i.catalog.Resolve(ctx, req.GetDatabase())
+	if err != nil {
+		return nil, databaseError(err)
+	}
+
+	for idx, point := range req.GetPoints() {
+		err := db.Pipeline.Ingest(ctx, point, req.GetPrecision(), receivedAt)
+		if err != nil {
 			rejected += 1
+			rejections = append(rejections, ingestpipeline.Rejection(idx, err))
 		} else {
 			accepted += 1
 		}
 	}
 
 	return &ingestpb.WriteResponse{
-		Accepted: accepted,
-		Rejected: rejected,
+		Accepted:   accepted,
+		Rejected:   rejected,
+		Rejections: rejections,
 	}, nil
 }
+
+// Handles a long lived stream of data points. Points are pushed into the
+// pipeline with AddDataPointWait, so a full pipeline stops this handler from
+// reading the stream and grpc flow control slows the client down. Every
+// request may name its own database.
+func (i *IngestService) StreamWrite(stream ingestpb.InjestService_StreamWriteServer) error {
+	ctx := stream.Context()
+	requests := make(chan *ingestpb.StreamWriteRequest)
+	recvErr := make(chan error, 1)
+
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(streamAckInterval)
+	defer ticker.Stop()
+
+	var accepted, rejected uint64
+	var lastError string
+	sinceAck := 0
+	dirty := false
+
+	sendAck := func() error {
+		sinceAck = 0
+		dirty = false
+		return stream.Send(&ingestpb.StreamWriteAck{Accepted: accepted, Rejected: rejected, LastError: lastError})
+	}
+
+	for {
+		select {
+		case req := <-requests:
+			receivedAt := time.Now()
+			db, err := i.catalog.Resolve(ctx, req.GetDatabase())
+			if err != nil {
+				return databaseError(err)
+			}
+			for _, point := range req.GetPoints() {
+				err := db.Pipeline.IngestWait(ctx, point, req.GetPrecision(), receivedAt)
+				if err != nil {
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					rejected += 1
+					lastError = err.Error()
+				} else {
+					accepted += 1
+				}
+				sinceAck += 1
+				dirty = true
+				if sinceAck >= streamAckEvery {
+					if err := sendAck(); err != nil {
+						return err
+					}
+				}
+			}
+
+		case <-ticker.C:
+			if dirty {
+				if err := sendAck(); err != nil {
+					return err
+				}
+			}
+
+		case err := <-recvErr:
+			if errors.Is(err, io.EOF) {
+				// client closed its side, report the final counts
+				return sendAck()
+			}
+			return err
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
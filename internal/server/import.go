@@ -0,0 +1,98 @@
+package server
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/heyyakash/tickdb/internal/importer"
+	ingestpipeline "github.com/heyyakash/tickdb/internal/ingest-pipeline"
+)
+
+// handleImport streams a line protocol or CSV file from the request body into
+// sorted SSTables, skipping the WAL and the memtable. The format, measurement,
+// columns, precision and max_errors query parameters describe the file, the
+// format defaults to csv for text/csv bodies and to line protocol otherwise.
+// Gzip compressed bodies are accepted with Content-Encoding: gzip. The
+// response is a stream of NDJSON progress reports, the last one has done set.
+func (i *IngestRestService) handleImport(ctx *gin.Context) {
+	receivedAt := time.Now()
+	opts, err := importOptions(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, importer.Report{Error: err.Error(), Done: true})
+		return
+	}
+
+	db, err := i.catalog.Resolve(ctx.Request.Context(), "")
+	if err != nil {
+		ctx.JSON(databaseStatus(err), importer.Report{Error: err.Error(), Done: true})
+		return
+	}
+
+	var body io.Reader = ctx.Request.Body
+	switch ctx.GetHeader("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, importer.Report{Error: "Invalid gzip body", Done: true})
+			return
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		ctx.JSON(http.StatusUnsupportedMediaType, importer.Report{Error: "Unsupported Content-Encoding, use gzip or none", Done: true})
+		return
+	}
+
+	// progress is written while the body is still being read
+	if err := http.NewResponseController(ctx.Writer).EnableFullDuplex(); err != nil {
+		i.logger.WarnContext(ctx.Request.Context(), "Couldn't enable full duplex, progress is only sent at the end of the import", "error", err)
+	}
+	ctx.Header("Content-Type", ndjsonContentType)
+	ctx.Status(http.StatusOK)
+	enc := json.NewEncoder(ctx.Writer)
+	progress := func(report importer.Report) {
+		if err := enc.Encode(report); err == nil {
+			ctx.Writer.Flush()
+		}
+	}
+
+	// timestamps are passed on as read, the bulk writer converts them from
+	// the import precision
+	w := db.Pipeline.NewBulkWriter(opts.Precision, receivedAt)
+	report := importer.Run(ctx.Request.Context(), body, w, opts, progress)
+	i.logger.InfoContext(ctx.Request.Context(), "Import finished", "database", db.Name, "format", opts.Format, "lines", report.Lines, "points", report.Points, "rejected", report.Rejected, "sstables", report.SSTables, "error", report.Error)
+}
+
+// importOptions reads the import options from the query parameters
+func importOptions(ctx *gin.Context) (importer.Options, error) {
+	opts := importer.Options{
+		Format:      ctx.Query("format"),
+		Measurement: ctx.Query("measurement"),
+		Columns:     ctx.Query("columns"),
+	}
+	if opts.Format == "" {
+		opts.Format = importer.LineProtocol
+		if media, _, _ := mime.ParseMediaType(ctx.ContentType()); media == "text/csv" {
+			opts.Format = importer.CSV
+		}
+	}
+
+	var err error
+	if opts.Precision, err = ingestpipeline.ParsePrecision(ctx.Query("precision")); err != nil {
+		return opts, err
+	}
+	if v := ctx.Query("max_errors"); v != "" {
+		if opts.MaxErrors, err = strconv.Atoi(v); err != nil || opts.MaxErrors < 0 {
+			return opts, fmt.Errorf("invalid max_errors %q, must be a non-negative integer", v)
+		}
+	}
+	return opts, importer.Check(opts)
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/heyyakash/tickdb/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestLogger tags every request with a request ID, taken from the
+// X-Request-ID header or generated, echoes it in the response and logs the
+// request once it is done.
+func RequestLogger(logger *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		id := ctx.GetHeader(logging.RequestIDHeader)
+		if id == "" {
+			id = logging.NewRequestID()
+		}
+		ctx.Header(logging.RequestIDHeader, id)
+		reqCtx := logging.WithRequestID(ctx.Request.Context(), id)
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+
+		ctx.Next()
+
+		level := slog.LevelInfo
+		if ctx.Writer.Status() >= 500 {
+			level = slog.LevelError
+		}
+		logger.Log(reqCtx, level, "HTTP request",
+			"method", ctx.Request.Method,
+			"path", ctx.Request.URL.Path,
+			"status", ctx.Writer.Status(),
+			"duration", time.Since(start),
+			"client", ctx.ClientIP(),
+		)
+	}
+}
+
+// grpcRequestID returns the request ID sent in the call metadata or a new one,
+// and sends it back to the client in the response header.
+func grpcRequestID(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(strings.ToLower(logging.RequestIDHeader)); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if id == "" {
+		id = logging.NewRequestID()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(logging.RequestIDHeader), id))
+	return logging.WithRequestID(ctx, id)
+}
+
+func logCall(ctx context.Context, logger *slog.Logger, method string, start time.Time, err error) {
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+	}
+	logger.Log(ctx, level, "gRPC call",
+		"method", method,
+		"code", status.Code(err).String(),
+		"duration", time.Since(start),
+	)
+}
+
+// LoggingUnaryInterceptor is the grpc counterpart of RequestLogger.
+func LoggingUnaryInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		ctx = grpcRequestID(ctx)
+		resp, err := handler(ctx, req)
+		logCall(ctx, logger, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// LoggingStreamInterceptor is the grpc counterpart of RequestLogger for streams.
+func LoggingStreamInterceptor(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := grpcRequestID(ss.Context())
+		err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		logCall(ctx, logger, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// contextStream replaces the context of a server stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
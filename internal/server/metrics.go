@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/heyyakash/tickdb/internal/metrics"
+	"google.golang.org/grpc"
+)
+
+const queryServicePrefix = "/tickdb.query.QueryService/"
+
+// QueryMetrics records the latency of every request by route.
+func QueryMetrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		endpoint := ctx.FullPath()
+		if endpoint == "" {
+			endpoint = "unmatched"
+		}
+		metrics.QueryDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	}
+}
+
+// QueryMetricsUnaryInterceptor records the latency of unary QueryService calls.
+func QueryMetricsUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if !strings.HasPrefix(info.FullMethod, queryServicePrefix) {
+		return handler(ctx, req)
+	}
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	metrics.QueryDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// QueryMetricsStreamInterceptor records the latency of streaming QueryService calls.
+func QueryMetricsStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !strings.HasPrefix(info.FullMethod, queryServicePrefix) {
+		return handler(srv, ss)
+	}
+	start := time.Now()
+	err := handler(srv, ss)
+	metrics.QueryDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+	return err
+}
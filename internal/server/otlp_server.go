@@ -0,0 +1,142 @@
+package server
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/heyyakash/tickdb/internal/database"
+	"github.com/heyyakash/tickdb/internal/metrics"
+	"github.com/heyyakash/tickdb/internal/otlp"
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// OTLPMetricsService receives OpenTelemetry metrics over OTLP/gRPC and
+// OTLP/HTTP and writes them through the ingest pipeline.
+type OTLPMetricsService struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	catalog *database.Catalog
+	logger  *slog.Logger
+}
+
+func NewOTLPMetricsServer(catalog *database.Catalog, logger *slog.Logger) *OTLPMetricsService {
+	return &OTLPMetricsService{catalog: catalog, logger: logger}
+}
+
+// Export implements the OTLP/gRPC metrics service. The database is picked
+// with the x-tickdb-database metadata.
+func (o *OTLPMetricsService) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	db, err := o.catalog.Resolve(ctx, "")
+	if err != nil {
+		return nil, databaseError(err)
+	}
+	return o.export(ctx, db, req), nil
+}
+
+func (o *OTLPMetricsService) export(ctx context.Context, db *database.Database, req *colmetricspb.ExportMetricsServiceRequest) *colmetricspb.ExportMetricsServiceResponse {
+	var rejected int64
+	var lastErr error
+	receivedAt := time.Now()
+	// a data point is rejected when any of the points it became is
+	for _, dp := range otlp.Convert(req) {
+		if dp.Err != nil {
+			metrics.IngestRejected.WithLabelValues("invalid_value").Inc()
+			rejected++
+			lastErr = dp.Err
+			continue
+		}
+		failed := false
+		for _, point := range dp.Points {
+			if err := db.Pipeline.Ingest(ctx, point, ingestpb.Precision_PRECISION_NANOSECONDS, receivedAt); err != nil {
+				failed = true
+				lastErr = err
+			}
+		}
+		if failed {
+			rejected++
+		}
+	}
+
+	resp := &colmetricspb.ExportMetricsServiceResponse{}
+	if rejected > 0 {
+		o.logger.WarnContext(ctx, "OTLP export rejected data points", "rejected", rejected, "error", lastErr)
+		resp.PartialSuccess = &colmetricspb.ExportMetricsPartialSuccess{
+			RejectedDataPoints: rejected,
+			ErrorMessage:       lastErr.Error(),
+		}
+	}
+	return resp
+}
+
+func (o *OTLPMetricsService) SetupHandlers(r gin.IRouter) {
+	r.POST("/v1/metrics", o.handleHTTPExport)
+}
+
+// handleHTTPExport implements OTLP/HTTP with binary protobuf or JSON payloads
+func (o *OTLPMetricsService) handleHTTPExport(ctx *gin.Context) {
+	contentType := ctx.ContentType()
+	if contentType != "application/x-protobuf" && contentType != "application/json" {
+		ctx.String(http.StatusUnsupportedMediaType, "unsupported content type %q", contentType)
+		return
+	}
+
+	db, err := o.catalog.Resolve(ctx.Request.Context(), "")
+	if err != nil {
+		ctx.String(databaseStatus(err), "%v", err)
+		return
+	}
+
+	body, err := readOTLPBody(ctx.Request)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, "couldn't read request body : %v", err)
+		return
+	}
+
+	var req colmetricspb.ExportMetricsServiceRequest
+	if contentType == "application/json" {
+		err = protojson.Unmarshal(body, &req)
+	} else {
+		err = proto.Unmarshal(body, &req)
+	}
+	if err != nil {
+		ctx.String(http.StatusBadRequest, "invalid export request : %v", err)
+		return
+	}
+
+	resp := o.export(ctx.Request.Context(), db, &req)
+	var out []byte
+	if contentType == "application/json" {
+		out, err = protojson.Marshal(resp)
+	} else {
+		out, err = proto.Marshal(resp)
+	}
+	if err != nil {
+		ctx.String(http.StatusInternalServerError, "%v", err)
+		return
+	}
+	ctx.Data(http.StatusOK, contentType, out)
+}
+
+func readOTLPBody(r *http.Request) ([]byte, error) {
+	switch r.Header.Get("Content-Encoding") {
+	case "", "identity":
+		return io.ReadAll(r.Body)
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		return io.ReadAll(gz)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", r.Header.Get("Content-Encoding"))
+	}
+}
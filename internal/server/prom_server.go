@@ -0,0 +1,321 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/snappy"
+	"github.com/heyyakash/tickdb/internal/database"
+	"github.com/heyyakash/tickdb/internal/promql"
+	"github.com/heyyakash/tickdb/proto/gen/prompb"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxRangePoints is the most samples a range query returns per series, the
+// same limit as Prometheus
+const maxRangePoints = 11000
+
+// PromServer implements Prometheus remote_read and the subset of the
+// Prometheus HTTP API used by Grafana's Prometheus data source.
+type PromServer struct {
+	catalog *database.Catalog
+	logger  *slog.Logger
+}
+
+type promResponse struct {
+	Status    string `json:"status"`
+	Data      any    `json:"data,omitempty"`
+	ErrorType string `json:"errorType,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type promMatrix struct {
+	ResultType string            `json:"resultType"`
+	Result     []promMatrixEntry `json:"result"`
+}
+
+type promMatrixEntry struct {
+	Metric promql.Labels `json:"metric"`
+	Values [][2]any      `json:"values"`
+}
+
+func NewPromServer(catalog *database.Catalog, logger *slog.Logger) *PromServer {
+	return &PromServer{
+		catalog: catalog,
+		logger:  logger,
+	}
+}
+
+func (p *PromServer) SetupHandlers(r gin.IRouter) {
+	api := r.Group("api/v1")
+	api.POST("/read", p.handleRemoteRead)
+	api.GET("/query_range", p.handleQueryRange)
+	api.POST("/query_range", p.handleQueryRange)
+	api.GET("/series", p.handleSeries)
+	api.POST("/series", p.handleSeries)
+	api.GET("/labels", p.handleLabels)
+	api.POST("/labels", p.handleLabels)
+}
+
+func promError(ctx *gin.Context, status int, errorType string, err error) {
+	ctx.AbortWithStatusJSON(status, promResponse{Status: "error", ErrorType: errorType, Error: err.Error()})
+}
+
+// engine returns the PromQL engine of the database the request is for. It
+// writes the error response itself.
+func (p *PromServer) engine(ctx *gin.Context) (*promql.Engine, bool) {
+	db, err := p.catalog.Resolve(ctx.Request.Context(), "")
+	if err != nil {
+		promError(ctx, databaseStatus(err), "bad_data", err)
+		return nil, false
+	}
+	return db.PromQL, true
+}
+
+func (p *PromServer) handleQueryRange(ctx *gin.Context) {
+	start, err := parsePromTime(ctx.Request.FormValue("start"), time.Time{})
+	if err != nil {
+		promError(ctx, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid parameter \"start\" : %w", err))
+		return
+	}
+	end, err := parsePromTime(ctx.Request.FormValue("end"), time.Time{})
+	if err != nil {
+		promError(ctx, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid parameter \"end\" : %w", err))
+		return
+	}
+	step, err := parsePromDuration(ctx.Request.FormValue("step"))
+	if err != nil {
+		promError(ctx, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid parameter \"step\" : %w", err))
+		return
+	}
+	if end.Before(start) {
+		promError(ctx, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid parameter \"end\" : end timestamp must not be before start time"))
+		return
+	}
+	// stops a tiny step from exploding the result
+	if end.Sub(start)/step > maxRangePoints {
+		promError(ctx, http.StatusBadRequest, "bad_data", fmt.Errorf("exceeded maximum resolution of 11,000 points per timeseries. Try decreasing the query resolution (?step=XX)"))
+		return
+	}
+
+	expr, err := promql.Parse(ctx.Request.FormValue("query"))
+	if err != nil {
+		promError(ctx, http.StatusBadRequest, "bad_data", err)
+		return
+	}
+
+	engine, ok := p.engine(ctx)
+	if !ok {
+		return
+	}
+	series, err := engine.QueryRange(ctx.Request.Context(), expr, start, end, step)
+	if err != nil {
+		p.logger.ErrorContext(ctx.Request.Context(), "PromQL query failed", "error", err)
+		promError(ctx, http.StatusUnprocessableEntity, "execution", err)
+		return
+	}
+
+	matrix := promMatrix{ResultType: "matrix", Result: []promMatrixEntry{}}
+	for _, s := range series {
+		entry := promMatrixEntry{Metric: s.Labels, Values: make([][2]any, 0, len(s.Samples))}
+		for _, sample := range s.Samples {
+			entry.Values = append(entry.Values, [2]any{float64(sample.T) / 1000, promql.FormatValue(sample.V)})
+		}
+		matrix.Result = append(matrix.Result, entry)
+	}
+
+	ctx.JSON(http.StatusOK, promResponse{Status: "success", Data: matrix})
+}
+
+func (p *PromServer) handleSeries(ctx *gin.Context) {
+	if err := ctx.Request.ParseForm(); err != nil {
+		promError(ctx, http.StatusBadRequest, "bad_data", err)
+		return
+	}
+	selectors, ok := parseSelectors(ctx)
+	if !ok {
+		return
+	}
+	if len(selectors) == 0 {
+		promError(ctx, http.StatusBadRequest, "bad_data", fmt.Errorf("no match[] parameter provided"))
+		return
+	}
+	start, err := parsePromTime(ctx.Request.FormValue("start"), time.Unix(0, 0))
+	if err != nil {
+		promError(ctx, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid parameter \"start\" : %w", err))
+		return
+	}
+	end, err := parsePromTime(ctx.Request.FormValue("end"), time.Unix(0, math.MaxInt64))
+	if err != nil {
+		promError(ctx, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid parameter \"end\" : %w", err))
+		return
+	}
+
+	engine, ok := p.engine(ctx)
+	if !ok {
+		return
+	}
+	seen := make(map[string]bool)
+	data := []promql.Labels{}
+	for _, matchers := range selectors {
+		series, err := engine.SeriesLabels(ctx.Request.Context(), matchers, start, end)
+		if err != nil {
+			p.logger.ErrorContext(ctx.Request.Context(), "Series lookup failed", "error", err)
+			promError(ctx, http.StatusInternalServerError, "internal", err)
+			return
+		}
+		for _, labels := range series {
+			if key := labels.String(); !seen[key] {
+				seen[key] = true
+				data = append(data, labels)
+			}
+		}
+	}
+	ctx.JSON(http.StatusOK, promResponse{Status: "success", Data: data})
+}
+
+// handleLabels answers from the series index, start and end are ignored
+func (p *PromServer) handleLabels(ctx *gin.Context) {
+	if err := ctx.Request.ParseForm(); err != nil {
+		promError(ctx, http.StatusBadRequest, "bad_data", err)
+		return
+	}
+	selectors, ok := parseSelectors(ctx)
+	if !ok {
+		return
+	}
+	engine, ok := p.engine(ctx)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, promResponse{Status: "success", Data: engine.LabelNames(ctx.Request.Context(), selectors)})
+}
+
+// parseSelectors parses the match[] parameters. It writes the error response
+// itself.
+func parseSelectors(ctx *gin.Context) ([][]*promql.LabelMatcher, bool) {
+	var selectors [][]*promql.LabelMatcher
+	for _, selector := range ctx.Request.Form["match[]"] {
+		matchers, err := promql.ParseSelector(selector)
+		if err != nil {
+			promError(ctx, http.StatusBadRequest, "bad_data", err)
+			return nil, false
+		}
+		selectors = append(selectors, matchers)
+	}
+	return selectors, true
+}
+
+func (p *PromServer) handleRemoteRead(ctx *gin.Context) {
+	compressed, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, "invalid snappy payload : %v", err)
+		return
+	}
+	var req prompb.ReadRequest
+	if err := proto.Unmarshal(data, &req); err != nil {
+		ctx.String(http.StatusBadRequest, "invalid read request : %v", err)
+		return
+	}
+
+	db, err := p.catalog.Resolve(ctx.Request.Context(), "")
+	if err != nil {
+		ctx.String(databaseStatus(err), "%v", err)
+		return
+	}
+
+	resp := &prompb.ReadResponse{}
+	for _, q := range req.GetQueries() {
+		matchers := make([]*promql.LabelMatcher, 0, len(q.GetMatchers()))
+		for _, m := range q.GetMatchers() {
+			matcher, err := promql.NewLabelMatcher(promql.MatchType(m.GetType()), m.GetName(), m.GetValue())
+			if err != nil {
+				ctx.String(http.StatusBadRequest, "%v", err)
+				return
+			}
+			matchers = append(matchers, matcher)
+		}
+
+		start := time.UnixMilli(q.GetStartTimestampMs())
+		end := time.UnixMilli(q.GetEndTimestampMs())
+		series, err := db.PromQL.Select(ctx.Request.Context(), matchers, start, end)
+		if err != nil {
+			p.logger.ErrorContext(ctx.Request.Context(), "Remote read failed", "error", err)
+			ctx.String(http.StatusInternalServerError, "%v", err)
+			return
+		}
+
+		result := &prompb.QueryResult{}
+		for _, s := range series {
+			ts := &prompb.TimeSeries{}
+			for _, name := range s.Labels.Names() {
+				ts.Labels = append(ts.Labels, &prompb.Label{Name: name, Value: s.Labels[name]})
+			}
+			for _, sample := range s.Samples {
+				ts.Samples = append(ts.Samples, &prompb.Sample{Timestamp: sample.T, Value: sample.V})
+			}
+			result.Timeseries = append(result.Timeseries, ts)
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	out, err := proto.Marshal(resp)
+	if err != nil {
+		ctx.String(http.StatusInternalServerError, "%v", err)
+		return
+	}
+	ctx.Header("Content-Encoding", "snappy")
+	ctx.Data(http.StatusOK, "application/x-protobuf", snappy.Encode(nil, out))
+}
+
+// parsePromTime accepts unix seconds with an optional fraction or RFC3339.
+func parsePromTime(s string, def time.Time) (time.Time, error) {
+	if s == "" {
+		if def.IsZero() {
+			return def, fmt.Errorf("missing value")
+		}
+		return def, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse %q to a valid timestamp", s)
+	}
+	return t, nil
+}
+
+// parsePromDuration accepts seconds with an optional fraction or a duration like 1m.
+func parsePromDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("missing value")
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		if math.IsNaN(f) {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		if f*float64(time.Second) >= math.MaxInt64 {
+			return 0, fmt.Errorf("duration %s is too long", s)
+		}
+		d := time.Duration(f * float64(time.Second))
+		// anything under a nanosecond rounds down to 0
+		if d <= 0 {
+			return 0, fmt.Errorf("zero or negative duration is not accepted")
+		}
+		return d, nil
+	}
+	return promql.ParseDuration(s)
+}
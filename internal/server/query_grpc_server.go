@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/heyyakash/tickdb/internal/database"
+	"github.com/heyyakash/tickdb/internal/query"
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+	querypb "github.com/heyyakash/tickdb/proto/gen/query"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// points per QueryStream message
+const queryStreamChunkSize = 500
+
+var aggregateFuncs = map[querypb.AggregateFunction]query.AggregateFunc{
+	querypb.AggregateFunction_AGGREGATE_FUNCTION_COUNT: query.Count,
+	querypb.AggregateFunction_AGGREGATE_FUNCTION_SUM:   query.Sum,
+	querypb.AggregateFunction_AGGREGATE_FUNCTION_MEAN:  query.Mean,
+	querypb.AggregateFunction_AGGREGATE_FUNCTION_MIN:   query.Min,
+	querypb.AggregateFunction_AGGREGATE_FUNCTION_MAX:   query.Max,
+	querypb.AggregateFunction_AGGREGATE_FUNCTION_FIRST: query.First,
+	querypb.AggregateFunction_AGGREGATE_FUNCTION_LAST:  query.Last,
+}
+
+type QueryService struct {
+	querypb.UnimplementedQueryServiceServer
+	catalog *database.Catalog
+	logger  *slog.Logger
+}
+
+func NewQueryService(catalog *database.Catalog, logger *slog.Logger) *QueryService {
+	return &QueryService{catalog: catalog, logger: logger}
+}
+
+func (q *QueryService) Query(ctx context.Context, req *querypb.QueryRequest) (*querypb.QueryResponse, error) {
+	if err := validateQueryRequest(req.GetKey(), req.GetFromUnixNano(), req.GetToUnixNano()); err != nil {
+		return nil, err
+	}
+
+	db, err := q.catalog.Resolve(ctx, req.GetDatabase())
+	if err != nil {
+		return nil, databaseError(err)
+	}
+
+	page, err := pointPage(PageRequest{PageSize: int(req.GetPageSize()), Cursor: req.GetCursor()}, req.GetKey(), req.GetFromUnixNano(), req.GetToUnixNano())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	it, err := db.Query.PointPage(ctx, req.GetKey(), req.GetFromUnixNano(), req.GetToUnixNano(), page)
+	if err != nil {
+		return nil, q.queryError(ctx, err)
+	}
+	points, err := query.ReadPage(it)
+	if err != nil {
+		return nil, q.queryError(ctx, err)
+	}
+	return &querypb.QueryResponse{Points: points, NextCursor: it.NextCursor()}, nil
+}
+
+func (q *QueryService) Aggregate(ctx context.Context, req *querypb.AggregateRequest) (*querypb.AggregateResponse, error) {
+	if err := validateQueryRequest(req.GetKey(), req.GetFromUnixNano(), req.GetToUnixNano()); err != nil {
+		return nil, err
+	}
+	fn, ok := aggregateFuncs[req.GetFunction()]
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "unknown aggregate function")
+	}
+	if req.GetField() == "" {
+		return nil, status.Error(codes.InvalidArgument, "no field provided")
+	}
+	if req.GetWindowNano() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "window_nano must not be negative")
+	}
+
+	db, err := q.catalog.Resolve(ctx, req.GetDatabase())
+	if err != nil {
+		return nil, databaseError(err)
+	}
+
+	buckets, err := db.Query.Aggregate(ctx, req.GetKey(), req.GetField(), fn, req.GetFromUnixNano(), req.GetToUnixNano(), req.GetWindowNano())
+	if err != nil {
+		return nil, q.queryError(ctx, err)
+	}
+
+	resp := &querypb.AggregateResponse{Buckets: make([]*querypb.AggregateBucket, 0, len(buckets))}
+	for _, b := range buckets {
+		resp.Buckets = append(resp.Buckets, &querypb.AggregateBucket{StartUnixNano: b.Start, Value: b.Value, Count: b.Count})
+	}
+	return resp, nil
+}
+
+// Sends the points of a range query in chunks as they are read, so large
+// results don't have to fit in memory or in a single message.
+func (q *QueryService) QueryStream(req *querypb.QueryRequest, stream querypb.QueryService_QueryStreamServer) error {
+	if err := validateQueryRequest(req.GetKey(), req.GetFromUnixNano(), req.GetToUnixNano()); err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	db, err := q.catalog.Resolve(ctx, req.GetDatabase())
+	if err != nil {
+		return databaseError(err)
+	}
+
+	page, err := pointPage(PageRequest{PageSize: int(req.GetPageSize()), Cursor: req.GetCursor()}, req.GetKey(), req.GetFromUnixNano(), req.GetToUnixNano())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	it, err := db.Query.PointPage(ctx, req.GetKey(), req.GetFromUnixNano(), req.GetToUnixNano(), page)
+	if err != nil {
+		return q.queryError(ctx, err)
+	}
+	defer it.Close()
+
+	chunk := make([]*ingestpb.Point, 0, queryStreamChunkSize)
+	for {
+		point, err := it.Next()
+		if err != nil {
+			return q.queryError(ctx, err)
+		}
+		if point != nil {
+			chunk = append(chunk, point)
+		}
+		if len(chunk) == queryStreamChunkSize || (point == nil && (len(chunk) > 0 || it.NextCursor() != "")) {
+			if err := ctx.Err(); err != nil {
+				return status.FromContextError(err).Err()
+			}
+			resp := &querypb.QueryResponse{Points: chunk}
+			if point == nil {
+				resp.NextCursor = it.NextCursor()
+			}
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+			chunk = make([]*ingestpb.Point, 0, queryStreamChunkSize)
+		}
+		if point == nil {
+			return nil
+		}
+	}
+}
+
+// Execute runs a tickql statement.
+func (q *QueryService) Execute(ctx context.Context, req *querypb.ExecuteRequest) (*querypb.ExecuteResponse, error) {
+	if req.GetQuery() == "" {
+		return nil, status.Error(codes.InvalidArgument, "no query provided")
+	}
+	page, err := sqlPage(PageRequest{PageSize: int(req.GetPageSize()), Cursor: req.GetCursor()}, req.GetQuery())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	plan, err := planSQL(req.GetQuery(), time.Unix(0, page.Now))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	db, err := q.catalog.Resolve(ctx, req.GetDatabase())
+	if err != nil {
+		return nil, databaseError(err)
+	}
+
+	cursor, err := db.Query.ExecutePage(ctx, plan, page)
+	if err != nil {
+		return nil, q.queryError(ctx, err)
+	}
+	results, err := query.Collect(cursor)
+	if err != nil {
+		return nil, q.queryError(ctx, err)
+	}
+	resp := &querypb.ExecuteResponse{Results: make([]*querypb.Result, 0, len(results)), NextCursor: cursor.NextCursor()}
+	for _, result := range results {
+		resp.Results = append(resp.Results, resultProto(result))
+	}
+	return resp, nil
+}
+
+func resultProto(result *query.Result) *querypb.Result {
+	pb := &querypb.Result{Name: result.Name, Tags: result.Tags, Columns: result.Columns, Rows: make([]*querypb.Row, 0, len(result.Values))}
+	for _, values := range result.Values {
+		row := &querypb.Row{Values: make([]*querypb.Value, 0, len(values))}
+		for _, v := range values {
+			value := &querypb.Value{}
+			switch v := v.(type) {
+			case int64:
+				value.Value = &querypb.Value_TimeUnixNano{TimeUnixNano: v}
+			case float64:
+				value.Value = &querypb.Value_Number{Number: v}
+			case string:
+				value.Value = &querypb.Value_Text{Text: v}
+			}
+			row.Values = append(row.Values, value)
+		}
+		pb.Rows = append(pb.Rows, row)
+	}
+	return pb
+}
+
+func validateQueryRequest(key string, from, to int64) error {
+	if key == "" {
+		return status.Error(codes.InvalidArgument, "no key provided")
+	}
+	if to < from {
+		return status.Error(codes.InvalidArgument, "to_unix_nano must not be before from_unix_nano")
+	}
+	return nil
+}
+
+// queryError maps engine errors to grpc status codes, keeping deadline and
+// cancellation errors recognisable to the client.
+func (q *QueryService) queryError(ctx context.Context, err error) error {
+	if s := status.FromContextError(err); s.Code() != codes.Unknown {
+		return s.Err()
+	}
+	var tooMany *query.TooManyPointsError
+	if errors.As(err, &tooMany) {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	q.logger.ErrorContext(ctx, "Query failed", "error", err)
+	return status.Error(codes.Internal, err.Error())
+}
@@ -1,19 +1,23 @@
 package server
 
 import (
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	memtable "github.com/heyyakash/tickdb/internal/mem-table"
-	"github.com/heyyakash/tickdb/internal/sstable"
+	"github.com/heyyakash/tickdb/internal/database"
+	"github.com/heyyakash/tickdb/internal/export"
+	"github.com/heyyakash/tickdb/internal/query"
+	"github.com/heyyakash/tickdb/internal/tickql"
 	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
 )
 
 type QueryServer struct {
-	m *memtable.MemTableService
-	s *sstable.SSTableService
+	catalog *database.Catalog
+	logger  *slog.Logger
 }
 
 type QueryRequest struct {
@@ -22,59 +26,309 @@ type QueryRequest struct {
 	ToUnixTimeStampNano   string `json:"to_unix_timestamp_nano"`
 }
 
+// PointsRequest is the body of a range query, read a page at a time when
+// page_size is set.
+type PointsRequest struct {
+	QueryRequest
+	PageRequest
+}
+
 type QueryResponse struct {
-	Success bool              `json:"success"`
-	Error   string            `json:"error"`
-	Points  []*ingestpb.Point `json:"points"`
+	Success    bool              `json:"success"`
+	Error      string            `json:"error"`
+	Points     []*ingestpb.Point `json:"points"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+type AggregateRequest struct {
+	QueryRequest
+	Field      string `json:"field"`
+	Function   string `json:"function"`
+	WindowNano string `json:"window_nano"`
+}
+
+type AggregateResponse struct {
+	Success bool           `json:"success"`
+	Error   string         `json:"error"`
+	Buckets []query.Bucket `json:"buckets"`
+}
+
+type SQLRequest struct {
+	Query string `json:"query"`
+	PageRequest
+}
+
+type SQLResponse struct {
+	Success    bool            `json:"success"`
+	Error      string          `json:"error"`
+	Results    []*query.Result `json:"results"`
+	NextCursor string          `json:"next_cursor,omitempty"`
 }
 
-func NewQueryServer(m *memtable.MemTableService, s *sstable.SSTableService) *QueryServer {
+func NewQueryServer(catalog *database.Catalog, logger *slog.Logger) *QueryServer {
 	return &QueryServer{
-		m: m,
-		s: s,
+		catalog: catalog,
+		logger:  logger,
 	}
 }
 
-func (q *QueryServer) SetupHandlers(r *gin.Engine) {
+func (q *QueryServer) SetupHandlers(r gin.IRouter) {
 	api := r.Group("query")
 	api.POST("/", q.HandleQuery)
+	api.POST("/aggregate", q.HandleAggregate)
+	api.GET("/sql", q.HandleSQL)
+	api.POST("/sql", q.HandleSQL)
+}
+
+// parseRange reads the from/to timestamps of a query body
+func parseRange(body QueryRequest) (int64, int64, string) {
+	startTimeStamp, err := strconv.ParseInt(body.FromUnixTimeStampNano, 10, 64)
+	if err != nil {
+		return 0, 0, "Invalid from_unix_timestamp_nano value"
+	}
+
+	endTimeStamp, err := strconv.ParseInt(body.ToUnixTimeStampNano, 10, 64)
+	if err != nil {
+		return 0, 0, "Invalid to_unix_timestamp_nano value"
+	}
+	return startTimeStamp, endTimeStamp, ""
 }
 
+// HandleQuery streams the points of a series key in the format picked by
+// responseFormat.
 func (q *QueryServer) HandleQuery(ctx *gin.Context) {
-	var body QueryRequest
+	format, err := responseFormat(ctx)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, QueryResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	var body PointsRequest
 	if err := ctx.BindJSON(&body); err != nil {
-		log.Printf("Couldn't extract query body : %v", err)
+		q.logger.DebugContext(ctx.Request.Context(), "Couldn't extract query body", "error", err)
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, QueryResponse{Success: false, Error: "Invalid Request Body"})
 		return
 	}
 
-	var Points []*ingestpb.Point
+	startTimeStamp, endTimeStamp, errMsg := parseRange(body.QueryRequest)
+	if errMsg != "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, QueryResponse{Success: false, Error: errMsg})
+		return
+	}
 
-	dataPoints, ok := q.m.MemTable[body.Key]
-	if !ok {
-		//todo implement SSTTable lookup
-		ctx.JSON(http.StatusNotFound, QueryResponse{Success: true, Points: Points})
+	page, err := pointPage(body.PageRequest, body.Key, startTimeStamp, endTimeStamp)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, QueryResponse{Success: false, Error: err.Error()})
 		return
 	}
 
-	startTimeStamp, err := strconv.ParseInt(body.FromUnixTimeStampNano, 10, 64)
+	db, err := q.catalog.Resolve(ctx.Request.Context(), "")
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, QueryResponse{Success: false, Error: "Invalid from_unix_timestamp_nano value"})
+		ctx.AbortWithStatusJSON(databaseStatus(err), QueryResponse{Success: false, Error: err.Error()})
 		return
 	}
 
-	endTimeStamp, err := strconv.ParseInt(body.ToUnixTimeStampNano, 10, 64)
+	if export.ContentType(format) != "" {
+		src := pointSource(ctx.Request.Context(), db, body.Key, startTimeStamp, endTimeStamp, page)
+		schema, next, err := exportSchema(src)
+		if err != nil {
+			q.logger.ErrorContext(ctx.Request.Context(), "Query failed", "error", err)
+			ctx.AbortWithStatusJSON(queryStatus(err), QueryResponse{Success: false, Error: err.Error()})
+			return
+		}
+		if schema.Rows() == 0 {
+			ctx.JSON(http.StatusNotFound, QueryResponse{Success: true, Points: []*ingestpb.Point{}})
+			return
+		}
+		if err := writeExport(ctx, format, schema, next, src); err != nil {
+			q.logger.ErrorContext(ctx.Request.Context(), "Export failed", "format", format, "error", err)
+		}
+		return
+	}
+
+	points, err := db.Query.PointPage(ctx.Request.Context(), body.Key, startTimeStamp, endTimeStamp, page)
+	if err != nil {
+		q.logger.ErrorContext(ctx.Request.Context(), "Query failed", "error", err)
+		ctx.AbortWithStatusJSON(queryStatus(err), QueryResponse{Success: false, Error: err.Error()})
+		return
+	}
+	defer points.Close()
+
+	first, err := points.Next()
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, QueryResponse{Success: false, Error: "Invalid to_unix_timestamp_nano value"})
+		q.logger.ErrorContext(ctx.Request.Context(), "Query failed", "error", err)
+		ctx.AbortWithStatusJSON(queryStatus(err), QueryResponse{Success: false, Error: err.Error()})
 		return
 	}
+	if first == nil {
+		ctx.JSON(http.StatusNotFound, QueryResponse{Success: true, Points: []*ingestpb.Point{}})
+		return
+	}
+
+	if err := streamPoints(ctx, format, points, first); err != nil {
+		q.logger.ErrorContext(ctx.Request.Context(), "Query failed while streaming", "error", err)
+	}
+}
 
-	for _, point := range dataPoints {
-		if point.TimestampUnixNano >= startTimeStamp && point.TimestampUnixNano <= endTimeStamp {
-			Points = append(Points, point)
+// pointPage reads the page of a range query from req
+func pointPage(req PageRequest, key string, from, to int64) (query.Page, error) {
+	if req.PageSize < 0 {
+		return query.Page{}, errors.New("Invalid page_size value")
+	}
+	page := query.Page{Size: req.PageSize, Query: query.Fingerprint(key, strconv.FormatInt(from, 10), strconv.FormatInt(to, 10))}
+	if req.Cursor != "" {
+		var err error
+		if page.Resume, err = query.ParsePosition(req.Cursor, page.Query); err != nil {
+			return query.Page{}, err
 		}
 	}
+	return page, nil
+}
+
+func (q *QueryServer) HandleAggregate(ctx *gin.Context) {
+	var body AggregateRequest
+	if err := ctx.BindJSON(&body); err != nil {
+		q.logger.DebugContext(ctx.Request.Context(), "Couldn't extract aggregate body", "error", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, AggregateResponse{Success: false, Error: "Invalid Request Body"})
+		return
+	}
 
-	ctx.JSON(http.StatusOK, QueryResponse{Success: true, Points: Points})
+	startTimeStamp, endTimeStamp, errMsg := parseRange(body.QueryRequest)
+	if errMsg != "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, AggregateResponse{Success: false, Error: errMsg})
+		return
+	}
 
+	var window int64
+	if body.WindowNano != "" {
+		var err error
+		if window, err = strconv.ParseInt(body.WindowNano, 10, 64); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, AggregateResponse{Success: false, Error: "Invalid window_nano value"})
+			return
+		}
+	}
+
+	db, err := q.catalog.Resolve(ctx.Request.Context(), "")
+	if err != nil {
+		ctx.AbortWithStatusJSON(databaseStatus(err), AggregateResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	buckets, err := db.Query.Aggregate(ctx.Request.Context(), body.Key, body.Field, query.AggregateFunc(body.Function), startTimeStamp, endTimeStamp, window)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, AggregateResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, AggregateResponse{Success: true, Buckets: buckets})
+}
+
+// HandleSQL runs a tickql statement, read from the q parameter of a GET or
+// the body of a POST, and streams the result in the format picked by
+// responseFormat.
+func (q *QueryServer) HandleSQL(ctx *gin.Context) {
+	format, err := responseFormat(ctx)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, SQLResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	body := SQLRequest{Query: ctx.Query("q"), PageRequest: PageRequest{Cursor: ctx.Query("cursor")}}
+	if size := ctx.Query("page_size"); size != "" {
+		if body.PageSize, err = strconv.Atoi(size); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, SQLResponse{Success: false, Error: "Invalid page_size value"})
+			return
+		}
+	}
+	if ctx.Request.Method == http.MethodPost {
+		if err := ctx.BindJSON(&body); err != nil {
+			q.logger.DebugContext(ctx.Request.Context(), "Couldn't extract sql body", "error", err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, SQLResponse{Success: false, Error: "Invalid Request Body"})
+			return
+		}
+	}
+	if body.Query == "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, SQLResponse{Success: false, Error: "No query provided"})
+		return
+	}
+
+	page, err := sqlPage(body.PageRequest, body.Query)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, SQLResponse{Success: false, Error: err.Error()})
+		return
+	}
+	plan, err := planSQL(body.Query, time.Unix(0, page.Now))
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, SQLResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	db, err := q.catalog.Resolve(ctx.Request.Context(), "")
+	if err != nil {
+		ctx.AbortWithStatusJSON(databaseStatus(err), SQLResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	if export.ContentType(format) != "" {
+		src := sqlSource(ctx.Request.Context(), db, plan, page)
+		schema, next, err := exportSchema(src)
+		if err != nil {
+			q.logger.ErrorContext(ctx.Request.Context(), "Query failed", "query", body.Query, "error", err)
+			ctx.AbortWithStatusJSON(queryStatus(err), SQLResponse{Success: false, Error: err.Error()})
+			return
+		}
+		if err := writeExport(ctx, format, schema, next, src); err != nil {
+			q.logger.ErrorContext(ctx.Request.Context(), "Export failed", "query", body.Query, "format", format, "error", err)
+		}
+		return
+	}
+
+	cursor, err := db.Query.ExecutePage(ctx.Request.Context(), plan, page)
+	if err != nil {
+		q.logger.ErrorContext(ctx.Request.Context(), "Query failed", "query", body.Query, "error", err)
+		ctx.AbortWithStatusJSON(queryStatus(err), SQLResponse{Success: false, Error: err.Error()})
+		return
+	}
+	defer cursor.Close()
+
+	first, err := cursor.Next()
+	if err != nil {
+		q.logger.ErrorContext(ctx.Request.Context(), "Query failed", "query", body.Query, "error", err)
+		ctx.AbortWithStatusJSON(queryStatus(err), SQLResponse{Success: false, Error: err.Error()})
+		return
+	}
+	if first == nil {
+		ctx.JSON(http.StatusOK, SQLResponse{Success: true, Results: []*query.Result{}, NextCursor: cursor.NextCursor()})
+		return
+	}
+
+	if err := streamResults(ctx, format, cursor, first); err != nil {
+		q.logger.ErrorContext(ctx.Request.Context(), "Query failed while streaming", "query", body.Query, "error", err)
+	}
+}
+
+// sqlPage reads the page of a statement from req. now() resolves to the time
+// of the first page on every page.
+func sqlPage(req PageRequest, text string) (query.Page, error) {
+	if req.PageSize < 0 {
+		return query.Page{}, errors.New("Invalid page_size value")
+	}
+	page := query.Page{Size: req.PageSize, Query: query.Fingerprint(text), Now: time.Now().UnixNano()}
+	if req.Cursor != "" {
+		var err error
+		if page.Resume, err = query.ParsePosition(req.Cursor, page.Query); err != nil {
+			return query.Page{}, err
+		}
+		page.Now = page.Resume.Now
+	}
+	return page, nil
+}
+
+// planSQL parses and plans a tickql statement, now() resolves to now
+func planSQL(text string, now time.Time) (*query.Plan, error) {
+	stmt, err := tickql.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return tickql.Plan(stmt, now)
 }
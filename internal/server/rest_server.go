@@ -1,40 +1,66 @@
 package server
 
 import (
+	"errors"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/heyyakash/tickdb/internal/database"
 	ingestpipeline "github.com/heyyakash/tickdb/internal/ingest-pipeline"
 	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
 )
 
 type IngestRestService struct {
-	pipelineService *ingestpipeline.PipelineService
+	catalog *database.Catalog
+	logger  *slog.Logger
 }
 
-func NewIngestRestServer(pipelineService *ingestpipeline.PipelineService) *IngestRestService {
+func NewIngestRestServer(catalog *database.Catalog, logger *slog.Logger) *IngestRestService {
 	restService := IngestRestService{
-		pipelineService: pipelineService,
+		catalog: catalog,
+		logger:  logger,
 	}
 	return &restService
 }
 
-func (i *IngestRestService) SetupHandlers(r *gin.Engine) {
+func (i *IngestRestService) SetupHandlers(r gin.IRouter) {
 	api := r.Group("ingest")
 	api.POST("single", i.handleDataPoint)
 	api.POST("batch", i.handleBatchDataPoints)
+	api.POST("import", i.handleImport)
 }
 
+// Timestamps are nanoseconds unless the precision query parameter says
+// otherwise (s, ms, us or ns). Points without a timestamp get the time the
+// request was received.
 func (i *IngestRestService) handleDataPoint(ctx *gin.Context) {
 	var body ingestpb.Point
+	receivedAt := time.Now()
+
+	db, err := i.catalog.Resolve(ctx.Request.Context(), "")
+	if err != nil {
+		ctx.JSON(databaseStatus(err), ingestpb.WriteResponse{Accepted: 0, Rejected: 1, Error: err.Error()})
+		return
+	}
+
+	precision, err := ingestpipeline.ParsePrecision(ctx.Query("precision"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ingestpb.WriteResponse{Accepted: 0, Rejected: 1, Error: err.Error()})
+		return
+	}
 
 	if err := ctx.ShouldBindJSON(&body); err != nil {
 		ctx.JSON(http.StatusBadRequest, ingestpb.WriteResponse{Accepted: 0, Rejected: 1, Error: "Invalid data scheme"})
 		return
 	}
 
-	if err := i.pipelineService.AddDataPoint(&body); err != nil {
-		ctx.JSON(http.StatusBadRequest, ingestpb.WriteResponse{Accepted: 0, Rejected: 1, Error: err.Error()})
+	err = db.Pipeline.Ingest(ctx.Request.Context(), &body, precision, receivedAt)
+	if err != nil {
+		ctx.JSON(writeStatus(ctx, err), ingestpb.WriteResponse{Accepted: 0, Rejected: 1, Error: err.Error(), Rejections: []*ingestpb.Rejection{ingestpipeline.Rejection(0, err)}})
 		return
 	}
 
@@ -45,21 +71,56 @@ func (i *IngestRestService) handleBatchDataPoints(ctx *gin.Context) {
 	var body []ingestpb.Point
 	accepted := 0
 	rejected := 0
+	var rejections []*ingestpb.Rejection
+	receivedAt := time.Now()
+
+	db, err := i.catalog.Resolve(ctx.Request.Context(), "")
+	if err != nil {
+		ctx.JSON(databaseStatus(err), ingestpb.WriteResponse{Error: err.Error()})
+		return
+	}
+
+	precision, err := ingestpipeline.ParsePrecision(ctx.Query("precision"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ingestpb.WriteResponse{Accepted: 0, Rejected: 1, Error: err.Error()})
+		return
+	}
 
 	if err := ctx.ShouldBindJSON(&body); err != nil {
 		ctx.JSON(http.StatusBadRequest, ingestpb.WriteResponse{Accepted: 0, Rejected: 1, Error: "Invalid data scheme"})
 		return
 	}
 
-	for _, point := range body {
-		if err := i.pipelineService.AddDataPoint(&point); err != nil {
+	for idx := range body {
+		err := db.Pipeline.Ingest(ctx.Request.Context(), &body[idx], precision, receivedAt)
+		if err != nil {
 			rejected += 1
+			rejections = append(rejections, ingestpipeline.Rejection(idx, err))
 		} else {
 			accepted += 1
 		}
 	}
 
-	ctx.JSON(http.StatusOK, ingestpb.WriteResponse{Accepted: uint64(accepted), Rejected: uint64(rejected)})
+	ctx.JSON(http.StatusOK, ingestpb.WriteResponse{Accepted: uint64(accepted), Rejected: uint64(rejected), Rejections: rejections})
+}
+
+// writeStatus picks the http status for a rejected single point write. Rate
+// limited writes get a Retry-After header.
+func writeStatus(ctx *gin.Context, err error) int {
+	if errors.Is(err, ingestpipeline.ErrReadOnly) {
+		return http.StatusServiceUnavailable
+	}
+	var pe *ingestpipeline.PointError
+	if errors.As(err, &pe) {
+		switch pe.Code {
+		case ingestpb.RejectCode_REJECT_CODE_RATE_LIMITED:
+			ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(max(pe.RetryAfter.Seconds(), 1)))))
+			return http.StatusTooManyRequests
+		case ingestpb.RejectCode_REJECT_CODE_QUOTA_EXCEEDED:
+			return http.StatusForbidden
+		}
+	}
+	return http.StatusBadRequest
 }
 
 func (i *IngestRestService) GetDataPoints(ctx *gin.Context) {
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/heyyakash/tickdb/internal/database"
+	"github.com/heyyakash/tickdb/internal/health"
+	"github.com/heyyakash/tickdb/internal/sstable"
+	"github.com/heyyakash/tickdb/internal/wal"
+)
+
+// StatusServer serves the liveness, readiness and status endpoints.
+type StatusServer struct {
+	health  *health.Status
+	version string
+	dataDir string
+	catalog *database.Catalog
+	logger  *slog.Logger
+}
+
+type ReadyResponse struct {
+	Ready   bool     `json:"ready"`
+	Pending []string `json:"pending,omitempty"`
+}
+
+type StatusResponse struct {
+	Version       string            `json:"version"`
+	Ready         bool              `json:"ready"`
+	Degraded      map[string]string `json:"degraded,omitempty"`
+	StartedAt     time.Time         `json:"started_at"`
+	UptimeSeconds float64           `json:"uptime_seconds"`
+	DataDir       string            `json:"data_dir"`
+	DataDirBytes  int64             `json:"data_dir_bytes"`
+	Databases     []string          `json:"databases"`
+	// the storage details below are for this database
+	Database    string              `json:"database"`
+	MemTable    MemTableStatus      `json:"memtable"`
+	WALSegments []wal.Segment       `json:"wal_segments"`
+	SSTables    []sstable.TableInfo `json:"sstables"`
+	Errors      []string            `json:"errors,omitempty"`
+}
+
+type MemTableStatus struct {
+	Points int `json:"points"`
+	Series int `json:"series"`
+}
+
+func NewStatusServer(h *health.Status, version, dataDir string, catalog *database.Catalog, logger *slog.Logger) *StatusServer {
+	return &StatusServer{
+		health:  h,
+		version: version,
+		dataDir: dataDir,
+		catalog: catalog,
+		logger:  logger,
+	}
+}
+
+// SetupProbeHandlers registers /health and /ready. They are meant for
+// orchestrators and load balancers and are registered ahead of authentication.
+func (st *StatusServer) SetupProbeHandlers(r gin.IRouter) {
+	r.GET("/health", st.handleHealth)
+	r.GET("/ready", st.handleReady)
+}
+
+func (st *StatusServer) SetupHandlers(r gin.IRouter) {
+	r.GET("/status", st.handleStatus)
+}
+
+// liveness, the process is up and serving http. A storage failure is reported
+// as degraded but doesn't fail the check, reads are still served.
+func (st *StatusServer) handleHealth(ctx *gin.Context) {
+	if degraded := st.health.Degraded(); len(degraded) > 0 {
+		ctx.JSON(http.StatusOK, gin.H{"status": "degraded", "degraded": degraded})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (st *StatusServer) handleReady(ctx *gin.Context) {
+	ready, pending := st.health.Ready()
+	if !ready {
+		ctx.JSON(http.StatusServiceUnavailable, ReadyResponse{Ready: false, Pending: pending})
+		return
+	}
+	ctx.JSON(http.StatusOK, ReadyResponse{Ready: true})
+}
+
+// handleStatus reports the server and the storage of the selected database,
+// the default one unless the request names another.
+func (st *StatusServer) handleStatus(ctx *gin.Context) {
+	db, err := st.catalog.Resolve(ctx.Request.Context(), "")
+	if err != nil {
+		ctx.JSON(databaseStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ready, _ := st.health.Ready()
+	points, series := db.MemTable.Stats()
+	resp := StatusResponse{
+		Version:       st.version,
+		Ready:         ready,
+		Degraded:      st.health.Degraded(),
+		StartedAt:     st.health.Started(),
+		UptimeSeconds: time.Since(st.health.Started()).Seconds(),
+		DataDir:       st.dataDir,
+		Database:      db.Name,
+		MemTable:      MemTableStatus{Points: points, Series: series},
+	}
+	for _, d := range st.catalog.List() {
+		resp.Databases = append(resp.Databases, d.Name)
+	}
+
+	size, err := dirSize(st.dataDir)
+	if err != nil {
+		resp.Errors = append(resp.Errors, "data dir : "+err.Error())
+	}
+	resp.DataDirBytes = size
+
+	if resp.WALSegments, err = db.WAL.Segments(); err != nil {
+		resp.Errors = append(resp.Errors, "wal : "+err.Error())
+	}
+	if resp.SSTables, err = db.SSTables.Inventory(); err != nil {
+		resp.Errors = append(resp.Errors, "sstable : "+err.Error())
+	}
+
+	if len(resp.Errors) > 0 {
+		st.logger.WarnContext(ctx.Request.Context(), "Status is incomplete", "errors", resp.Errors)
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size, err
+}
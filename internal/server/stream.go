@@ -0,0 +1,250 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/heyyakash/tickdb/internal/export"
+	"github.com/heyyakash/tickdb/internal/query"
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+const (
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+
+	ndjsonContentType = "application/x-ndjson"
+)
+
+// chunkSize is how many points or rows are written between flushes of a
+// streamed response
+const chunkSize = 500
+
+// PageRequest asks for at most PageSize points or rows, resuming where the
+// page with the NextCursor given as Cursor stopped.
+type PageRequest struct {
+	PageSize int    `json:"page_size"`
+	Cursor   string `json:"cursor"`
+}
+
+// streamEnd closes a streamed response. Errors after the first point was
+// written can't change the status code and are reported here instead.
+type streamEnd struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	Success    bool   `json:"success"`
+	Error      string `json:"error"`
+}
+
+// acceptFormats maps the media types of the Accept header to formats
+var acceptFormats = []struct{ mediaType, format string }{
+	{ndjsonContentType, formatNDJSON},
+	{"text/csv", export.CSV},
+	{"application/vnd.apache.arrow.stream", export.Arrow},
+	{"application/vnd.apache.parquet", export.Parquet},
+}
+
+// responseFormat reads the format of a query response from the format
+// parameter or the Accept header: json, the default, ndjson, or one of the
+// export formats csv, arrow and parquet.
+func responseFormat(ctx *gin.Context) (string, error) {
+	format := ctx.Query("format")
+	if format == "" {
+		accept := ctx.GetHeader("Accept")
+		for _, f := range acceptFormats {
+			if strings.Contains(accept, f.mediaType) {
+				return f.format, nil
+			}
+		}
+		return formatJSON, nil
+	}
+	if format != formatJSON && format != formatNDJSON && export.ContentType(format) == "" {
+		return "", fmt.Errorf("Unsupported format %q, use json, ndjson, csv, arrow or parquet", format)
+	}
+	return format, nil
+}
+
+// queryStatus maps a query error to an HTTP status code
+func queryStatus(err error) int {
+	var tooMany *query.TooManyPointsError
+	switch {
+	case errors.As(err, &tooMany):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, query.ErrInvalidCursor):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// stream writes a response in chunks as it is read. JSON responses are a
+// single object whose arrays are written item by item, NDJSON responses are
+// an object per line.
+type stream struct {
+	w       gin.ResponseWriter
+	ndjson  bool
+	pending int
+}
+
+func newStream(ctx *gin.Context, format string) *stream {
+	s := &stream{w: ctx.Writer, ndjson: format == formatNDJSON}
+	if s.ndjson {
+		ctx.Header("Content-Type", ndjsonContentType)
+	} else {
+		ctx.Header("Content-Type", "application/json; charset=utf-8")
+	}
+	ctx.Status(http.StatusOK)
+	return s
+}
+
+func (s *stream) write(data string) {
+	s.w.WriteString(data)
+}
+
+// item writes a JSON value, an NDJSON line of its own, and flushes every
+// chunkSize items
+func (s *stream) item(v any, first bool) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	switch {
+	case s.ndjson:
+		data = append(data, '\n')
+	case !first:
+		s.write(",")
+	}
+	s.w.Write(data)
+	if s.pending++; s.pending >= chunkSize {
+		s.flush()
+	}
+	return nil
+}
+
+func (s *stream) flush() {
+	s.pending = 0
+	s.w.Flush()
+}
+
+// end writes closer, which closes the arrays of a JSON response, followed by
+// the cursor of the next page or the error. NDJSON responses only get a last
+// line when there is a next page or an error.
+func (s *stream) end(closer, cursor string, err error) {
+	end := streamEnd{NextCursor: cursor, Success: err == nil}
+	if err != nil {
+		end.NextCursor, end.Error = "", err.Error()
+	}
+	data, _ := json.Marshal(end)
+	switch {
+	case !s.ndjson:
+		// the fields of end are added to the object the response started
+		s.write(closer + "," + string(data[1:]))
+	case end.NextCursor != "" || err != nil:
+		s.write(string(data) + "\n")
+	}
+	s.flush()
+}
+
+// streamPoints writes first and the rest of page as a QueryResponse
+func streamPoints(ctx *gin.Context, format string, page *query.PointPage, first *ingestpb.Point) error {
+	s := newStream(ctx, format)
+	if !s.ndjson {
+		s.write(`{"points":[`)
+	}
+	var err error
+	for point, n := first, 0; point != nil && err == nil; n++ {
+		if err = s.item(point, n == 0); err == nil {
+			point, err = page.Next()
+		}
+	}
+	s.end("]", page.NextCursor(), err)
+	return err
+}
+
+// groupHeader is a Result without its values
+type groupHeader struct {
+	Name    string            `json:"name"`
+	Tags    map[string]string `json:"tags,omitempty"`
+	Columns []string          `json:"columns"`
+}
+
+// streamResults writes first and the rest of the groups of cursor as a
+// SQLResponse. NDJSON responses have a Result per line holding up to
+// chunkSize rows of a group.
+func streamResults(ctx *gin.Context, format string, cursor *query.Cursor, first *query.Group) error {
+	s := newStream(ctx, format)
+	if !s.ndjson {
+		s.write(`{"results":[`)
+	}
+	var err error
+	open := false
+	for g, n := first, 0; g != nil && err == nil; n++ {
+		if s.ndjson {
+			err = streamGroupLines(s, g)
+		} else {
+			err = streamGroup(s, g, n == 0)
+			open = err != nil
+		}
+		if err == nil {
+			g, err = cursor.Next()
+		}
+	}
+	closer := "]"
+	if open {
+		// the values of the group that failed to be read are still open
+		closer = "]}]"
+	}
+	s.end(closer, cursor.NextCursor(), err)
+	return err
+}
+
+// streamGroup writes g as a JSON Result, an error leaves it open
+func streamGroup(s *stream, g *query.Group, first bool) error {
+	header, err := json.Marshal(groupHeader{Name: g.Name, Tags: g.Tags, Columns: g.Columns})
+	if err != nil {
+		return err
+	}
+	if !first {
+		s.write(",")
+	}
+	s.write(strings.TrimSuffix(string(header), "}") + `,"values":[`)
+	for n := 0; ; n++ {
+		row, err := g.Rows.Next()
+		if err != nil {
+			return err
+		}
+		if row == nil {
+			break
+		}
+		if err := s.item(append([]any{row.Time}, row.Values...), n == 0); err != nil {
+			return err
+		}
+	}
+	s.write("]}")
+	return nil
+}
+
+// streamGroupLines writes g as NDJSON Results of up to chunkSize rows
+func streamGroupLines(s *stream, g *query.Group) error {
+	result := &query.Result{Name: g.Name, Tags: g.Tags, Columns: g.Columns}
+	for {
+		row, err := g.Rows.Next()
+		if err != nil {
+			return err
+		}
+		if row != nil {
+			result.Values = append(result.Values, append([]any{row.Time}, row.Values...))
+		}
+		if len(result.Values) > 0 && (row == nil || len(result.Values) == chunkSize) {
+			if err := s.item(result, false); err != nil {
+				return err
+			}
+			result.Values = nil
+		}
+		if row == nil {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package sstable
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	memtable "github.com/heyyakash/tickdb/internal/mem-table"
+)
+
+// Reader gives read access to a single SSTable file written by Flush.
+type Reader struct {
+	f     *os.File
+	Path  string
+	Index map[string]int64
+}
+
+// OpenReader opens an SSTable and loads its index block using the footer.
+func OpenReader(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := readIndex(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &Reader{f: f, Path: path, Index: index}, nil
+}
+
+func readIndex(f *os.File) (map[string]int64, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if stat.Size() < 8 {
+		return nil, errors.New("sstable is too small to contain a footer")
+	}
+
+	var indexOffset uint64
+	if _, err := f.Seek(-8, io.SeekEnd); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(f, binary.LittleEndian, &indexOffset); err != nil {
+		return nil, err
+	}
+	if int64(indexOffset) >= stat.Size()-8 {
+		return nil, errors.New("sstable footer points past the end of the file")
+	}
+
+	indexInBytes, err := readBlock(f, int64(indexOffset))
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int64)
+	if err := json.Unmarshal(indexInBytes, &index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// readBlock reads a [len][bytes] block starting at offset.
+func readBlock(f *os.File, offset int64) ([]byte, error) {
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var length int32
+	if err := binary.Read(f, binary.LittleEndian, &length); err != nil {
+		return nil, err
+	}
+	if length < 0 {
+		return nil, errors.New("negative block length in sstable")
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Keys returns the series keys stored in the table in sorted order.
+func (r *Reader) Keys() []string {
+	keys := make([]string, 0, len(r.Index))
+	for k := range r.Index {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Get reads the entry stored for key. ok is false if the key is not in the table.
+func (r *Reader) Get(key string) (entry *SSTableEntry, ok bool, err error) {
+	offset, ok := r.Index[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	data, err := readBlock(r.f, offset)
+	if err != nil {
+		return nil, false, err
+	}
+
+	entry = &SSTableEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, false, err
+	}
+	return entry, true, nil
+}
+
+// TimeRange scans every entry and returns the oldest and newest point
+// timestamps in the table. ok is false for a table without points.
+func (r *Reader) TimeRange() (minTS, maxTS int64, ok bool, err error) {
+	for _, key := range r.Keys() {
+		entry, found, err := r.Get(key)
+		if err != nil {
+			return 0, 0, false, err
+		}
+		if !found {
+			continue
+		}
+		for _, point := range entry.Value {
+			if !ok || point.TimestampUnixNano < minTS {
+				minTS = point.TimestampUnixNano
+			}
+			if !ok || point.TimestampUnixNano > maxTS {
+				maxTS = point.TimestampUnixNano
+			}
+			ok = true
+		}
+	}
+	return minTS, maxTS, ok, nil
+}
+
+func (r *Reader) Close() error {
+	return r.f.Close()
+}
+
+// Dir returns the directory SSTables are flushed to.
+func (s *SSTableService) Dir() string {
+	return s.dir
+}
+
+// SeriesKeys returns the series keys stored in the SSTables on disk, each
+// once and with sorted tags.
+func (s *SSTableService) SeriesKeys() ([]string, error) {
+	tables, err := s.ListTables()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var keys []string
+	for _, path := range tables {
+		reader, err := OpenReader(path)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't open %s : %w", filepath.Base(path), err)
+		}
+		for key := range reader.Index {
+			// older SSTables were keyed with unsorted tags
+			key = memtable.NormalizeKey(key)
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+		reader.Close()
+	}
+	return keys, nil
+}
+
+// ListTables returns the paths of all SSTables on disk, oldest first.
+func (s *SSTableService) ListTables() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var tables []string
+	for _, v := range entries {
+		if !v.IsDir() && strings.HasSuffix(v.Name(), ".sst") {
+			tables = append(tables, filepath.Join(s.Dir(), v.Name()))
+		}
+	}
+	sort.Strings(tables)
+	return tables, nil
+}
+
+// DeleteExpired removes every SSTable whose newest point is older than cutoff
+// (unix nanoseconds) and returns how many tables were removed.
+func (s *SSTableService) DeleteExpired(cutoff int64) (int, error) {
+	tables, err := s.ListTables()
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	defer s.UpdateStats()
+	for _, path := range tables {
+		reader, err := OpenReader(path)
+		if err != nil {
+			return deleted, err
+		}
+		_, maxTS, ok, err := reader.TimeRange()
+		reader.Close()
+		if err != nil {
+			return deleted, err
+		}
+		if ok && maxTS >= cutoff {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return deleted, err
+		}
+		deleted += 1
+	}
+	return deleted, nil
+}
+
+// TableInfo describes an SSTable on disk.
+type TableInfo struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+	Keys  int    `json:"keys"`
+	Error string `json:"error,omitempty"`
+}
+
+// Inventory lists the SSTables with their size and number of series keys.
+func (s *SSTableService) Inventory() ([]TableInfo, error) {
+	tables, err := s.ListTables()
+	if err != nil {
+		return nil, err
+	}
+
+	inventory := make([]TableInfo, 0, len(tables))
+	for _, path := range tables {
+		info := TableInfo{Name: filepath.Base(path)}
+		if stat, err := os.Stat(path); err == nil {
+			info.Bytes = stat.Size()
+		}
+		reader, err := OpenReader(path)
+		if err != nil {
+			info.Error = err.Error()
+		} else {
+			info.Keys = len(reader.Index)
+			reader.Close()
+		}
+		inventory = append(inventory, info)
+	}
+	return inventory, nil
+}
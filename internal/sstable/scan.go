@@ -0,0 +1,99 @@
+package sstable
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// footerSize is the size of the footer holding the index offset
+const footerSize = 8
+
+// Footer describes the end of an SSTable: the footer and the index block it
+// points to.
+type Footer struct {
+	Size        int64 `json:"size"`
+	IndexOffset int64 `json:"index_offset"`
+	// length of the index block, without its length prefix
+	IndexLength int32 `json:"index_length"`
+}
+
+// ReadFooter reads the footer of the SSTable in r, which is size bytes long,
+// and the length prefix of the index block. The index block has to end where
+// the footer starts.
+func ReadFooter(r io.ReaderAt, size int64) (*Footer, error) {
+	if size < footerSize+4 {
+		return nil, errors.New("sstable is too small to contain a footer")
+	}
+	var buf [footerSize]byte
+	if _, err := r.ReadAt(buf[:], size-footerSize); err != nil {
+		return nil, err
+	}
+	footer := &Footer{Size: size, IndexOffset: int64(binary.LittleEndian.Uint64(buf[:]))}
+	if footer.IndexOffset < 0 || footer.IndexOffset > size-footerSize-4 {
+		return nil, fmt.Errorf("sstable footer points to %d, past the end of the data", footer.IndexOffset)
+	}
+	if _, err := r.ReadAt(buf[:4], footer.IndexOffset); err != nil {
+		return nil, err
+	}
+	footer.IndexLength = int32(binary.LittleEndian.Uint32(buf[:4]))
+	if end := footer.IndexOffset + 4 + int64(footer.IndexLength); footer.IndexLength < 0 || end != size-footerSize {
+		return nil, fmt.Errorf("sstable index block at %d has length %d, it doesn't end at the footer", footer.IndexOffset, footer.IndexLength)
+	}
+	return footer, nil
+}
+
+// ReadIndexBlock reads the index block a footer points to.
+func ReadIndexBlock(r io.ReaderAt, footer *Footer) (map[string]int64, error) {
+	data := make([]byte, footer.IndexLength)
+	if _, err := r.ReadAt(data, footer.IndexOffset+4); err != nil {
+		return nil, err
+	}
+	index := make(map[string]int64)
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("invalid sstable index : %w", err)
+	}
+	return index, nil
+}
+
+// ScanEntries reads the length prefixed entries of the data block in r from
+// the start up to end and calls fn with the offset, length and content of
+// each. It stops at the first entry that can't be read, doesn't fit before
+// end or isn't an entry, and returns the offset after the last good entry
+// along with the reason it stopped. A nil error means the entries fill the
+// data block exactly.
+func ScanEntries(r io.ReaderAt, end int64, fn func(offset int64, length int32, entry *SSTableEntry) error) (int64, error) {
+	var offset int64
+	var prefix [4]byte
+	for offset < end {
+		if end-offset < 4 {
+			return offset, fmt.Errorf("%d stray bytes at %d", end-offset, offset)
+		}
+		if _, err := r.ReadAt(prefix[:], offset); err != nil {
+			return offset, fmt.Errorf("couldn't read the length at %d : %w", offset, err)
+		}
+		length := int32(binary.LittleEndian.Uint32(prefix[:]))
+		if length < 0 || offset+4+int64(length) > end {
+			return offset, fmt.Errorf("entry at %d has length %d, past the end of the data block at %d", offset, length, end)
+		}
+		data := make([]byte, length)
+		if _, err := r.ReadAt(data, offset+4); err != nil {
+			return offset, fmt.Errorf("couldn't read the entry at %d : %w", offset, err)
+		}
+		var entry SSTableEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return offset, fmt.Errorf("entry at %d isn't valid : %w", offset, err)
+		}
+		// the index block parses as an entry without a key
+		if entry.Key == "" {
+			return offset, fmt.Errorf("block at %d isn't an entry", offset)
+		}
+		if err := fn(offset, length, &entry); err != nil {
+			return offset, err
+		}
+		offset += 4 + int64(length)
+	}
+	return offset, nil
+}
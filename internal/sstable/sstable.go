@@ -3,15 +3,18 @@ package sstable
 import (
 	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	memtable "github.com/heyyakash/tickdb/internal/mem-table"
+	"github.com/heyyakash/tickdb/internal/metrics"
 	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
 )
 
@@ -21,48 +24,102 @@ type SSTableEntry struct {
 }
 
 type SSTableService struct {
-	m *memtable.MemTableService
+	m      *memtable.MemTableService
+	dir    string
+	logger *slog.Logger
+
+	// last reported count and size, the gauges are shared by every database
+	statsMu sync.Mutex
+	count   int
+	bytes   int64
 }
 
-func NewSSTableService(m *memtable.MemTableService) *SSTableService {
-	return &SSTableService{
-		m: m,
+func NewSSTableService(m *memtable.MemTableService, dir string, logger *slog.Logger) *SSTableService {
+	s := &SSTableService{
+		m:      m,
+		dir:    dir,
+		logger: logger,
 	}
+	s.UpdateStats()
+	return s
 }
 
-func (s *SSTableService) Flush(walStartTime int64) {
+// UpdateStats refreshes the SSTable count and size metrics
+func (s *SSTableService) UpdateStats() {
+	tables, err := s.ListTables()
+	if err != nil {
+		return
+	}
+	var size int64
+	for _, path := range tables {
+		if info, err := os.Stat(path); err == nil {
+			size += info.Size()
+		}
+	}
+
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	metrics.SSTableCount.Add(float64(len(tables) - s.count))
+	metrics.SSTableBytes.Add(float64(size - s.bytes))
+	s.count, s.bytes = len(tables), size
+}
+
+// Bytes returns the size of the SSTables on disk as of the last UpdateStats.
+func (s *SSTableService) Bytes() int64 {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	return s.bytes
+}
+
+// Flush writes the memtable to a new SSTable and returns its path. On error
+// the partly written file is removed and the memtable is left untouched.
+func (s *SSTableService) Flush(walStartTime int64) (string, error) {
 	s.m.Lock()
 	defer s.m.Unlock()
+	start := time.Now()
 	// structure of sstable
 	// DATA BLOCK -> [len(bytes)][data json bytes]
 	// INDEX BLOCK -> [len(index)][index json bytes]
 	// FOOTER -> index offset
 
-	// get the current working dir
-	cwd, err := os.Getwd()
-	if err != nil {
-		log.Fatal("Error in flushing Memtable, couldn't get working directory : ", err)
-	}
 	walStartTimeString := strconv.FormatInt(walStartTime, 10)
 	currentTimeStampString := strconv.FormatInt(time.Now().Unix(), 10)
 	sstableName := walStartTimeString + "-" + currentTimeStampString + ".sst"
-	sstablePath := filepath.Join(cwd, "sstable", sstableName)
+	sstablePath := filepath.Join(s.dir, sstableName)
 
 	//create dirs if not there
-	if err = os.MkdirAll(filepath.Dir(sstablePath), 0755); err != nil {
-		log.Fatal("Couldn't create subdirectort for sstable")
+	if err := os.MkdirAll(filepath.Dir(sstablePath), 0755); err != nil {
+		return "", fmt.Errorf("couldn't create directory for sstable : %w", err)
 	}
 
 	// create the sstable file
 	f, err := os.Create(sstablePath)
 	if err != nil {
-		log.Fatal("Error in flushing Memtable, couldn't create sstable", err)
+		return "", fmt.Errorf("couldn't create sstable : %w", err)
+	}
+
+	if err := writeEntries(f, s.m.MemTable); err != nil {
+		f.Close()
+		os.Remove(sstablePath)
+		return "", fmt.Errorf("couldn't write sstable %s : %w", sstableName, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(sstablePath)
+		return "", fmt.Errorf("couldn't close sstable %s : %w", sstableName, err)
 	}
-	defer f.Close()
 
+	metrics.FlushDuration.Observe(time.Since(start).Seconds())
+	s.UpdateStats()
+	s.logger.Info("Flushed the memtable", "sstable", sstableName, "series", len(s.m.MemTable), "points", s.m.PointCount, "duration", time.Since(start))
+	return sstablePath, nil
+}
+
+// writeEntries writes the series entries sorted by key, the index and the
+// footer to f and syncs it. Flush holds the memtable lock while it runs.
+func writeEntries(f *os.File, series map[string][]*ingestpb.Point) error {
 	// sort the keys
-	keys := make([]string, 0, len(s.m.MemTable))
-	for k, _ := range s.m.MemTable {
+	keys := make([]string, 0, len(series))
+	for k := range series {
 		keys = append(keys, k)
 	}
 
@@ -70,42 +127,96 @@ func (s *SSTableService) Flush(walStartTime int64) {
 	index := make(map[string]int64)
 
 	for _, v := range keys {
-		offset, _ := f.Seek(0, io.SeekCurrent)
+		offset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
 		entry := SSTableEntry{
 			Key:   v,
-			Value: s.m.MemTable[v],
+			Value: series[v],
 		}
 		entryInBytes, err := json.Marshal(entry)
 		if err != nil {
-			log.Fatal("Error in flushing Memtable, couldn't marshal entry json to bytes", err)
+			return err
 		}
 		if err := binary.Write(f, binary.LittleEndian, int32(len(entryInBytes))); err != nil {
-			log.Fatal("Error writing length to SSTable :", err)
+			return err
 		}
-		_, err = f.Write(entryInBytes)
-		if err != nil {
-			log.Fatal("Error writing to SSTable : ", err)
+		if _, err := f.Write(entryInBytes); err != nil {
+			return err
 		}
 		index[v] = offset
 	}
 
-	indexOffset, _ := f.Seek(0, io.SeekCurrent)
+	if err := WriteIndex(f, index); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// WriteIndex writes the index block and the footer pointing to it at the
+// current offset of f, which is the end of the data block.
+func WriteIndex(f *os.File, index map[string]int64) error {
+	indexOffset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
 	indexInBytes, err := json.Marshal(index)
 	if err != nil {
-		log.Fatal("Error Marshaling index JSON to bytes :", err)
+		return err
 	}
 	if err := binary.Write(f, binary.LittleEndian, int32(len(indexInBytes))); err != nil {
-		log.Fatal("Error writing length of Index to SSTable :", err)
+		return err
 	}
-	_, err = f.Write(indexInBytes)
-	if err != nil {
-		log.Fatal("Error writing index to SSTable : ", err)
+	if _, err := f.Write(indexInBytes); err != nil {
+		return err
 	}
 
-	if err := binary.Write(f, binary.LittleEndian, uint64(indexOffset)); err != nil {
-		log.Fatal("Error writing length of Index to SSTable :", err)
+	return binary.Write(f, binary.LittleEndian, uint64(indexOffset))
+}
+
+// WriteTable writes series straight to a new SSTable, without the WAL and the
+// memtable, and returns its path. The points of every series are sorted by
+// timestamp. The table is written under a temporary name and only shows up in
+// ListTables once it is complete.
+func (s *SSTableService) WriteTable(series map[string][]*ingestpb.Point) (string, error) {
+	start := time.Now()
+	for _, points := range series {
+		sort.SliceStable(points, func(i, j int) bool { return points[i].TimestampUnixNano < points[j].TimestampUnixNano })
 	}
 
-	log.Print("Successfully flushed the Memtable")
+	// tables written in the same second are told apart by the nanoseconds
+	now := time.Now()
+	sstableName := fmt.Sprintf("%d-%d-import-%d.sst", now.Unix(), now.Unix(), now.UnixNano())
+	sstablePath := filepath.Join(s.dir, sstableName)
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return "", fmt.Errorf("couldn't create directory for sstable : %w", err)
+	}
+
+	tmpPath := sstablePath + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return "", fmt.Errorf("couldn't create sstable : %w", err)
+	}
+	if err := writeEntries(f, series); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("couldn't write sstable %s : %w", sstableName, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("couldn't close sstable %s : %w", sstableName, err)
+	}
+	if err := os.Rename(tmpPath, sstablePath); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("couldn't rename sstable %s : %w", sstableName, err)
+	}
 
+	s.UpdateStats()
+	points := 0
+	for _, p := range series {
+		points += len(p)
+	}
+	s.logger.Info("Wrote an imported sstable", "sstable", sstableName, "series", len(series), "points", points, "duration", time.Since(start))
+	return sstablePath, nil
 }
@@ -0,0 +1,261 @@
+// Package tickql parses and plans the SQL-like query language of TickDB:
+//
+//	SELECT mean(usage) FROM cpu WHERE host = 'a' AND time > now() - 1h GROUP BY time(1m), dc
+//
+// Statements select raw fields or aggregates from one measurement, filter
+// on tags, numeric fields and time, group by time windows and tags, fill
+// empty windows, and order, limit and offset the rows of every group.
+package tickql
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expr is a node of a WHERE clause or a selected field.
+type Expr interface {
+	String() string
+}
+
+// VarRef names a field or a tag.
+type VarRef struct {
+	Name string
+}
+
+// Wildcard is * in a field list or GROUP BY clause.
+type Wildcard struct{}
+
+type StringLiteral struct {
+	Val string
+}
+
+// NumberLiteral is a number. Literals without a decimal point also keep
+// their exact value in Int, float64 can't hold every nanosecond timestamp.
+type NumberLiteral struct {
+	Val   float64
+	Int   int64
+	IsInt bool
+}
+
+type DurationLiteral struct {
+	Val time.Duration
+}
+
+type RegexLiteral struct {
+	Val *regexp.Regexp
+}
+
+// Call is a function call such as mean(usage), now() or time(1m).
+type Call struct {
+	Name string
+	Args []Expr
+}
+
+// BinaryExpr is a comparison, AND, OR or time arithmetic.
+type BinaryExpr struct {
+	Op  string
+	LHS Expr
+	RHS Expr
+}
+
+// ParenExpr keeps the parentheses of a condition so String round trips.
+type ParenExpr struct {
+	Expr Expr
+}
+
+// Field is a selected expression with an optional alias.
+type Field struct {
+	Expr  Expr
+	Alias string
+}
+
+// SelectStatement is a parsed query.
+type SelectStatement struct {
+	Fields      []*Field
+	Measurement string
+	// nil when there is no WHERE clause
+	Condition Expr
+	// time(interval) calls, tag names and wildcards
+	Dimensions []Expr
+	// FILL(...) mode: none, null, previous, linear or a number in FillValue,
+	// empty when not set
+	Fill       string
+	FillValue  float64
+	Descending bool
+	// 0 when not set
+	Limit  int
+	Offset int
+}
+
+// quoteIdent quotes names that wouldn't lex as an identifier
+func quoteIdent(name string) string {
+	if name == "" || keywords[strings.ToUpper(name)] || !isIdentStart(name[0]) {
+		return strconv.Quote(name)
+	}
+	for i := 0; i < len(name); i++ {
+		if !isIdentChar(name[i]) {
+			return strconv.Quote(name)
+		}
+	}
+	return name
+}
+
+func (v *VarRef) String() string { return quoteIdent(v.Name) }
+
+func (w *Wildcard) String() string { return "*" }
+
+func (s *StringLiteral) String() string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s.Val) + "'"
+}
+
+func (n *NumberLiteral) String() string {
+	if n.IsInt {
+		return strconv.FormatInt(n.Int, 10)
+	}
+	return strconv.FormatFloat(n.Val, 'g', -1, 64)
+}
+
+func (d *DurationLiteral) String() string { return formatDuration(d.Val) }
+
+func (r *RegexLiteral) String() string {
+	return "/" + strings.ReplaceAll(r.Val.String(), "/", `\/`) + "/"
+}
+
+func (c *Call) String() string {
+	args := make([]string, 0, len(c.Args))
+	for _, arg := range c.Args {
+		args = append(args, arg.String())
+	}
+	return c.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (b *BinaryExpr) String() string {
+	return b.LHS.String() + " " + b.Op + " " + b.RHS.String()
+}
+
+func (p *ParenExpr) String() string { return "(" + p.Expr.String() + ")" }
+
+func (f *Field) String() string {
+	if f.Alias != "" {
+		return f.Expr.String() + " AS " + quoteIdent(f.Alias)
+	}
+	return f.Expr.String()
+}
+
+func (s *SelectStatement) String() string {
+	var b strings.Builder
+	b.WriteString("SELECT ")
+	for i, f := range s.Fields {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(f.String())
+	}
+	b.WriteString(" FROM " + quoteIdent(s.Measurement))
+	if s.Condition != nil {
+		b.WriteString(" WHERE " + s.Condition.String())
+	}
+	if len(s.Dimensions) > 0 {
+		b.WriteString(" GROUP BY ")
+		for i, d := range s.Dimensions {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(d.String())
+		}
+	}
+	switch s.Fill {
+	case "":
+	case "value":
+		b.WriteString(" FILL(" + strconv.FormatFloat(s.FillValue, 'g', -1, 64) + ")")
+	default:
+		b.WriteString(" FILL(" + s.Fill + ")")
+	}
+	if s.Descending {
+		b.WriteString(" ORDER BY time DESC")
+	}
+	if s.Limit > 0 {
+		b.WriteString(" LIMIT " + strconv.Itoa(s.Limit))
+	}
+	if s.Offset > 0 {
+		b.WriteString(" OFFSET " + strconv.Itoa(s.Offset))
+	}
+	return b.String()
+}
+
+var durationUnits = []struct {
+	suffix string
+	unit   time.Duration
+}{
+	{"w", 7 * 24 * time.Hour},
+	{"d", 24 * time.Hour},
+	{"h", time.Hour},
+	{"m", time.Minute},
+	{"s", time.Second},
+	{"ms", time.Millisecond},
+	{"us", time.Microsecond},
+	{"ns", time.Nanosecond},
+}
+
+// formatDuration writes d with the largest unit dividing it, such as 90s or 1h
+func formatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	for _, u := range durationUnits {
+		if d%u.unit == 0 {
+			return sign + strconv.FormatInt(int64(d/u.unit), 10) + u.suffix
+		}
+	}
+	return sign + strconv.FormatInt(int64(d), 10) + "ns"
+}
+
+// ParseDuration parses durations such as 30s, 5m, 1h30m or 500ms. The units
+// are ns, us, ms, s, m, h, d and w.
+func ParseDuration(s string) (time.Duration, error) {
+	var total time.Duration
+	rest := s
+	for rest != "" {
+		i := 0
+		for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		n, err := strconv.ParseInt(rest[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("duration is too long : %q", s)
+		}
+		rest = rest[i:]
+
+		j := 0
+		for j < len(rest) && (rest[j] < '0' || rest[j] > '9') {
+			j++
+		}
+		matched := false
+		for _, u := range durationUnits {
+			if rest[:j] == u.suffix {
+				if n > int64(math.MaxInt64/u.unit) || total > math.MaxInt64-time.Duration(n)*u.unit {
+					return 0, fmt.Errorf("duration is too long : %q", s)
+				}
+				total += time.Duration(n) * u.unit
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		rest = rest[j:]
+	}
+	if total <= 0 {
+		return 0, fmt.Errorf("duration must be positive : %q", s)
+	}
+	return total, nil
+}
@@ -0,0 +1,609 @@
+package tickql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var keywords = map[string]bool{
+	"SELECT": true,
+	"FROM":   true,
+	"WHERE":  true,
+	"AND":    true,
+	"OR":     true,
+	"GROUP":  true,
+	"BY":     true,
+	"ORDER":  true,
+	"ASC":    true,
+	"DESC":   true,
+	"LIMIT":  true,
+	"OFFSET": true,
+	"AS":     true,
+	"FILL":   true,
+}
+
+var comparisonOps = map[string]bool{
+	"=":  true,
+	"!=": true,
+	"<>": true,
+	"<":  true,
+	"<=": true,
+	">":  true,
+	">=": true,
+	"=~": true,
+	"!~": true,
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokDuration
+	tokRegex
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+	pos  int
+	// a double quoted identifier, never a keyword
+	quoted bool
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of input"
+	}
+	return strconv.Quote(t.val)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse parses a single SELECT statement, an optional trailing ; is allowed.
+func Parse(input string) (*SelectStatement, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	stmt, err := p.parseSelect()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind == tokPunct && t.val == ";" {
+		p.next()
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos)
+	}
+	return stmt, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (t token) isKeyword(kw string) bool {
+	return t.kind == tokIdent && !t.quoted && strings.EqualFold(t.val, kw)
+}
+
+func (t token) isPunct(val string) bool {
+	return t.kind == tokPunct && t.val == val
+}
+
+// unexpected describes t when something else was expected
+func unexpected(t token, want string) error {
+	if t.kind == tokEOF {
+		return fmt.Errorf("unexpected end of input, expected %s", want)
+	}
+	return fmt.Errorf("unexpected %s at position %d, expected %s", t, t.pos, want)
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	if t := p.next(); !t.isKeyword(kw) {
+		return unexpected(t, kw)
+	}
+	return nil
+}
+
+func (p *parser) expectPunct(val string) error {
+	if t := p.next(); !t.isPunct(val) {
+		return unexpected(t, val)
+	}
+	return nil
+}
+
+// parseIdent reads a name that isn't a keyword
+func (p *parser) parseIdent(what string) (string, error) {
+	t := p.next()
+	if t.kind != tokIdent || (!t.quoted && keywords[strings.ToUpper(t.val)]) {
+		return "", unexpected(t, what)
+	}
+	return t.val, nil
+}
+
+// parseInt reads a non negative integer
+func (p *parser) parseInt(what string) (int, error) {
+	t := p.next()
+	if t.kind != tokNumber {
+		return 0, unexpected(t, what)
+	}
+	n, err := strconv.Atoi(t.val)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %s at position %d", what, t, t.pos)
+	}
+	return n, nil
+}
+
+func (p *parser) parseSelect() (*SelectStatement, error) {
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	stmt := &SelectStatement{}
+	for {
+		field, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Fields = append(stmt.Fields, field)
+		if !p.peek().isPunct(",") {
+			break
+		}
+		p.next()
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	measurement, err := p.parseIdent("measurement")
+	if err != nil {
+		return nil, err
+	}
+	stmt.Measurement = measurement
+
+	if p.peek().isKeyword("WHERE") {
+		p.next()
+		if stmt.Condition, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek().isKeyword("GROUP") {
+		p.next()
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			dim, err := p.parseDimension()
+			if err != nil {
+				return nil, err
+			}
+			stmt.Dimensions = append(stmt.Dimensions, dim)
+			if !p.peek().isPunct(",") {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if p.peek().isKeyword("FILL") {
+		p.next()
+		if err := p.parseFill(stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek().isKeyword("ORDER") {
+		p.next()
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokIdent || !strings.EqualFold(t.val, "time") {
+			return nil, fmt.Errorf("only ORDER BY time is supported")
+		}
+		if t := p.peek(); t.isKeyword("ASC") || t.isKeyword("DESC") {
+			stmt.Descending = p.next().isKeyword("DESC")
+		}
+	}
+
+	if p.peek().isKeyword("LIMIT") {
+		p.next()
+		if stmt.Limit, err = p.parseInt("LIMIT"); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek().isKeyword("OFFSET") {
+		p.next()
+		if stmt.Offset, err = p.parseInt("OFFSET"); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+// parseFill reads the (mode) following FILL
+func (p *parser) parseFill(stmt *SelectStatement) error {
+	if err := p.expectPunct("("); err != nil {
+		return err
+	}
+	t := p.next()
+	negative := false
+	if t.isPunct("-") {
+		negative = true
+		t = p.next()
+	}
+	switch {
+	case t.kind == tokNumber:
+		v, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %s at position %d", t, t.pos)
+		}
+		if negative {
+			v = -v
+		}
+		stmt.Fill, stmt.FillValue = "value", v
+	case t.kind == tokIdent && !negative:
+		mode := strings.ToLower(t.val)
+		switch mode {
+		case "none", "null", "previous", "linear":
+			stmt.Fill = mode
+		default:
+			return fmt.Errorf("unknown fill mode %s at position %d, use none, null, previous, linear or a number", t, t.pos)
+		}
+	default:
+		return unexpected(t, "fill mode")
+	}
+	return p.expectPunct(")")
+}
+
+// parseField reads *, a field name or a call, with an optional alias
+func (p *parser) parseField() (*Field, error) {
+	field := &Field{}
+	if p.peek().isPunct("*") {
+		p.next()
+		field.Expr = &Wildcard{}
+		return field, nil
+	}
+
+	name, err := p.parseIdent("field")
+	if err != nil {
+		return nil, err
+	}
+	field.Expr = &VarRef{Name: name}
+	if p.peek().isPunct("(") {
+		p.next()
+		call := &Call{Name: strings.ToLower(name)}
+		for !p.peek().isPunct(")") {
+			if len(call.Args) > 0 {
+				if err := p.expectPunct(","); err != nil {
+					return nil, err
+				}
+			}
+			if p.peek().isPunct("*") {
+				p.next()
+				call.Args = append(call.Args, &Wildcard{})
+				continue
+			}
+			arg, err := p.parseIdent("field")
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, &VarRef{Name: arg})
+		}
+		p.next()
+		field.Expr = call
+	}
+
+	if p.peek().isKeyword("AS") {
+		p.next()
+		if field.Alias, err = p.parseIdent("alias"); err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+// parseDimension reads time(interval), * or a tag name
+func (p *parser) parseDimension() (Expr, error) {
+	if p.peek().isPunct("*") {
+		p.next()
+		return &Wildcard{}, nil
+	}
+	name, err := p.parseIdent("tag or time(interval)")
+	if err != nil {
+		return nil, err
+	}
+	if !p.peek().isPunct("(") {
+		return &VarRef{Name: name}, nil
+	}
+	if !strings.EqualFold(name, "time") {
+		return nil, fmt.Errorf("only time() can be called in GROUP BY")
+	}
+	p.next()
+	t := p.next()
+	if t.kind != tokDuration {
+		return nil, unexpected(t, "duration")
+	}
+	d, err := ParseDuration(t.val)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectPunct(")"); err != nil {
+		return nil, err
+	}
+	return &Call{Name: "time", Args: []Expr{&DurationLiteral{Val: d}}}, nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	lhs, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("OR") {
+		p.next()
+		rhs, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{Op: "OR", LHS: lhs, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	lhs, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("AND") {
+		p.next()
+		rhs, err := p.parseCondition()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{Op: "AND", LHS: lhs, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+// parseCondition reads a parenthesized condition or a comparison
+func (p *parser) parseCondition() (Expr, error) {
+	if p.peek().isPunct("(") {
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		return &ParenExpr{Expr: expr}, nil
+	}
+
+	lhs, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+	if op.kind != tokPunct || !comparisonOps[op.val] {
+		return nil, unexpected(op, "comparison operator")
+	}
+	rhs, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if op.val == "<>" {
+		op.val = "!="
+	}
+	return &BinaryExpr{Op: op.val, LHS: lhs, RHS: rhs}, nil
+}
+
+// parseAdditive reads operands joined by + or -, such as now() - 1h
+func (p *parser) parseAdditive() (Expr, error) {
+	lhs, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.isPunct("+") || t.isPunct("-"); t = p.peek() {
+		p.next()
+		rhs, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{Op: t.val, LHS: lhs, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseOperand() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokIdent:
+		if !t.quoted && keywords[strings.ToUpper(t.val)] {
+			return nil, unexpected(t, "operand")
+		}
+		if !p.peek().isPunct("(") {
+			return &VarRef{Name: t.val}, nil
+		}
+		p.next()
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		return &Call{Name: strings.ToLower(t.val)}, nil
+	case tokString:
+		return &StringLiteral{Val: t.val}, nil
+	case tokNumber:
+		return parseNumber(t.val, t.pos)
+	case tokDuration:
+		d, err := ParseDuration(t.val)
+		if err != nil {
+			return nil, err
+		}
+		return &DurationLiteral{Val: d}, nil
+	case tokRegex:
+		re, err := regexp.Compile(t.val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex at position %d : %w", t.pos, err)
+		}
+		return &RegexLiteral{Val: re}, nil
+	case tokPunct:
+		if t.val == "-" && p.peek().kind == tokNumber {
+			return parseNumber("-"+p.next().val, t.pos)
+		}
+	}
+	return nil, unexpected(t, "operand")
+}
+
+// parseNumber reads a number literal, integers are kept exact unless they
+// don't fit an int64
+func parseNumber(text string, pos int) (*NumberLiteral, error) {
+	if !strings.ContainsAny(text, ".eE") {
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return &NumberLiteral{Val: float64(n), Int: n, IsInt: true}, nil
+		}
+	}
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %s at position %d", text, pos)
+	}
+	return &NumberLiteral{Val: v}, nil
+}
+
+// exponentLen returns the length of the exponent of a number such as e5 or
+// E-3 at the start of s, 0 when there is none
+func exponentLen(s string) int {
+	if len(s) < 2 || s[0] != 'e' && s[0] != 'E' {
+		return 0
+	}
+	i := 1
+	if s[i] == '+' || s[i] == '-' {
+		i++
+	}
+	start := i
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i == start {
+		return 0
+	}
+	return i
+}
+
+func lex(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(input) && (input[i] >= '0' && input[i] <= '9' || input[i] == '.') {
+				i++
+			}
+			kind := tokNumber
+			if n := exponentLen(input[i:]); n > 0 {
+				// a number such as 1e5 or 2.5E-3, no duration unit starts with e
+				i += n
+			} else if i < len(input) && isIdentStart(input[i]) {
+				// a duration such as 1h30m
+				for i < len(input) && isIdentChar(input[i]) {
+					i++
+				}
+				kind = tokDuration
+			}
+			tokens = append(tokens, token{kind: kind, val: input[start:i], pos: start})
+		case isIdentStart(c):
+			start := i
+			for i < len(input) && isIdentChar(input[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, val: input[start:i], pos: start})
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			var b strings.Builder
+			for i < len(input) && input[i] != c {
+				if input[i] == '\\' && i+1 < len(input) {
+					i++
+				}
+				b.WriteByte(input[i])
+				i++
+			}
+			if i >= len(input) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++
+			if c == '"' {
+				tokens = append(tokens, token{kind: tokIdent, val: b.String(), pos: start, quoted: true})
+			} else {
+				tokens = append(tokens, token{kind: tokString, val: b.String(), pos: start})
+			}
+		case c == '/':
+			// regexes only follow =~ and !~
+			if len(tokens) == 0 || !(tokens[len(tokens)-1].isPunct("=~") || tokens[len(tokens)-1].isPunct("!~")) {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+			start := i
+			i++
+			var b strings.Builder
+			for i < len(input) && input[i] != '/' {
+				if input[i] == '\\' && i+1 < len(input) && input[i+1] == '/' {
+					i++
+				}
+				b.WriteByte(input[i])
+				i++
+			}
+			if i >= len(input) {
+				return nil, fmt.Errorf("unterminated regex at position %d", start)
+			}
+			i++
+			tokens = append(tokens, token{kind: tokRegex, val: b.String(), pos: start})
+		case strings.IndexByte("=!<>", c) >= 0:
+			start := i
+			i++
+			if i < len(input) && strings.IndexByte("=~>", input[i]) >= 0 {
+				i++
+			}
+			op := input[start:i]
+			if !comparisonOps[op] {
+				return nil, fmt.Errorf("unexpected %q at position %d", op, start)
+			}
+			tokens = append(tokens, token{kind: tokPunct, val: op, pos: start})
+		case strings.IndexByte("*,()+-;", c) >= 0:
+			tokens = append(tokens, token{kind: tokPunct, val: string(c), pos: i})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+		}
+	}
+	tokens = append(tokens, token{kind: tokEOF, pos: len(input)})
+	return tokens, nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,135 @@
+package tickql
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		// the statement printed back, the query itself when empty
+		want string
+	}{
+		{query: "SELECT * FROM cpu"},
+		{
+			query: `select mean(usage) as m, max("usage") from cpu where host = 'a' and time >= now() - 1h group by time(5m), host fill(0) order by time desc limit 10 offset 2`,
+			want:  "SELECT mean(usage) AS m, max(usage) FROM cpu WHERE host = 'a' AND time >= now() - 1h GROUP BY time(5m), host FILL(0) ORDER BY time DESC LIMIT 10 OFFSET 2",
+		},
+		{query: `SELECT * FROM "my cpu" WHERE region =~ /eu-.*/ OR region !~ /us\/west/`},
+		{query: "SELECT v FROM cpu WHERE time = 1700000000123456789"},
+		{query: "SELECT v FROM cpu WHERE time > -9223372036854775808"},
+		{query: "SELECT v FROM cpu WHERE v > 1e5 AND v < -2.5E-3", want: "SELECT v FROM cpu WHERE v > 100000 AND v < -0.0025"},
+		{query: "SELECT v FROM cpu WHERE v >= 1.5e+2 AND time < now() + 1h30m", want: "SELECT v FROM cpu WHERE v >= 150 AND time < now() + 90m"},
+		{query: "SELECT v FROM cpu WHERE (host = 'a' OR host = 'b') AND v != 0"},
+		{query: `SELECT v FROM cpu WHERE msg = 'it\'s'`},
+	}
+	for _, tt := range tests {
+		stmt, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.query, err)
+			continue
+		}
+		want := tt.want
+		if want == "" {
+			want = tt.query
+		}
+		if got := stmt.String(); got != want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.query, got, want)
+		}
+		// the printed statement parses to itself
+		again, err := Parse(stmt.String())
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", stmt.String(), err)
+		} else if again.String() != want {
+			t.Errorf("Parse(%q) = %s", stmt.String(), again.String())
+		}
+	}
+}
+
+func TestParseNumbers(t *testing.T) {
+	tests := []struct {
+		text  string
+		isInt bool
+		i     int64
+		f     float64
+	}{
+		{"1700000000123456789", true, 1700000000123456789, 0},
+		{"-1700000000123456789", true, -1700000000123456789, 0},
+		{"-9223372036854775808", true, -9223372036854775808, 0},
+		// too large for an int64, kept as a float
+		{"9223372036854775808", false, 0, 9223372036854775808},
+		{"1.5", false, 0, 1.5},
+		{"1e5", false, 0, 1e5},
+		{"-2.5E-3", false, 0, -2.5e-3},
+	}
+	for _, tt := range tests {
+		stmt, err := Parse("SELECT v FROM cpu WHERE v = " + tt.text)
+		if err != nil {
+			t.Errorf("%s: %v", tt.text, err)
+			continue
+		}
+		n, ok := stmt.Condition.(*BinaryExpr).RHS.(*NumberLiteral)
+		switch {
+		case !ok:
+			t.Errorf("%s is parsed as %T", tt.text, stmt.Condition.(*BinaryExpr).RHS)
+		case n.IsInt != tt.isInt:
+			t.Errorf("%s: IsInt = %v, want %v", tt.text, n.IsInt, tt.isInt)
+		case tt.isInt && n.Int != tt.i:
+			t.Errorf("%s is %d, want %d", tt.text, n.Int, tt.i)
+		case !tt.isInt && n.Val != tt.f:
+			t.Errorf("%s is %g, want %g", tt.text, n.Val, tt.f)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"SELECT FROM cpu", "expected"},
+		{"SELECT v cpu", "expected"},
+		{"SELECT v FROM cpu WHERE msg = 'open", "unterminated string"},
+		{"SELECT v FROM cpu WHERE host = /a/", "unexpected character"},
+		{"SELECT v FROM cpu WHERE host =~ /a", "unterminated regex"},
+		{"SELECT v FROM cpu WHERE host =~ /(/", "invalid regex"},
+		{"SELECT v FROM cpu WHERE v > 1e", "invalid duration"},
+		{"SELECT v FROM cpu WHERE time > now() - 5y", "invalid duration"},
+		{"SELECT v FROM cpu WHERE time > now() - 99999999999h", "duration is too long"},
+		{"SELECT v FROM cpu WHERE time > now() - 99999999999999999999s", "duration is too long"},
+		{"SELECT mean(v) FROM cpu GROUP BY time(0s)", "duration must be positive"},
+		{"SELECT v FROM cpu LIMIT -1", "LIMIT"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.query)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.query, err, tt.err)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		s    string
+		want time.Duration
+	}{
+		{"500ms", 500 * time.Millisecond},
+		{"1h30m", 90 * time.Minute},
+		{"2d", 48 * time.Hour},
+		{"1w", 7 * 24 * time.Hour},
+		{"2562047h", 2562047 * time.Hour},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.s)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, %v, want %v", tt.s, got, err, tt.want)
+		}
+	}
+	for _, s := range []string{"2562048h", "2562047h48m", "9223372036854775807ns1ns"} {
+		if _, err := ParseDuration(s); err == nil || !strings.Contains(err.Error(), "too long") {
+			t.Errorf("ParseDuration(%q) error = %v, want too long", s, err)
+		}
+	}
+}
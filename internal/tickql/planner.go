@@ -0,0 +1,375 @@
+package tickql
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heyyakash/tickdb/internal/query"
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// timeColumn is the name that refers to the timestamp of a point
+const timeColumn = "time"
+
+var aggregateFuncs = map[string]query.AggregateFunc{
+	"count": query.Count,
+	"sum":   query.Sum,
+	"mean":  query.Mean,
+	"min":   query.Min,
+	"max":   query.Max,
+	"first": query.First,
+	"last":  query.Last,
+}
+
+// condition evaluates a WHERE clause against the tags and fields of a point.
+// fields is nil when only the tags of a series are known.
+type condition func(tags, fields map[string]string) bool
+
+// Plan turns stmt into a query plan. Relative times such as now() - 1h are
+// resolved against now.
+func Plan(stmt *SelectStatement, now time.Time) (*query.Plan, error) {
+	plan := &query.Plan{
+		Measurement: stmt.Measurement,
+		From:        math.MinInt64,
+		To:          math.MaxInt64,
+		Descending:  stmt.Descending,
+		Limit:       stmt.Limit,
+		Offset:      stmt.Offset,
+	}
+
+	if err := planFields(plan, stmt.Fields); err != nil {
+		return nil, err
+	}
+	if err := planDimensions(plan, stmt.Dimensions); err != nil {
+		return nil, err
+	}
+	if stmt.Condition != nil {
+		if err := planCondition(plan, stmt.Condition, now); err != nil {
+			return nil, err
+		}
+	}
+	if plan.From > plan.To {
+		return nil, fmt.Errorf("the time range of the query is empty")
+	}
+	// windows are aligned to the epoch, an aggregate over the whole range is
+	// reported at its start
+	if plan.Window == 0 && plan.From != math.MinInt64 {
+		plan.Origin = plan.From
+	}
+
+	if stmt.Fill != "" {
+		if plan.Window == 0 {
+			return nil, fmt.Errorf("FILL needs GROUP BY time()")
+		}
+		plan.Fill = query.Fill{Mode: query.FillMode(stmt.Fill), Value: stmt.FillValue}
+	}
+	return plan, nil
+}
+
+func planFields(plan *query.Plan, fields []*Field) error {
+	names := make(map[string]bool)
+	raw := false
+	for _, f := range fields {
+		var name string
+		switch expr := f.Expr.(type) {
+		case *Wildcard:
+			if len(fields) > 1 {
+				return fmt.Errorf("* can't be combined with other fields")
+			}
+			return nil
+		case *VarRef:
+			if expr.Name == timeColumn {
+				return fmt.Errorf("time is always returned as the first column")
+			}
+			raw = true
+			name = expr.Name
+			plan.Fields = append(plan.Fields, expr.Name)
+		case *Call:
+			fn, ok := aggregateFuncs[expr.Name]
+			if !ok {
+				return fmt.Errorf("unknown aggregate function %q", expr.Name)
+			}
+			if len(expr.Args) != 1 {
+				return fmt.Errorf("%s expects one field", expr.Name)
+			}
+			arg, ok := expr.Args[0].(*VarRef)
+			if !ok {
+				return fmt.Errorf("%s expects a field name", expr.Name)
+			}
+			name = expr.Name + "_" + arg.Name
+			plan.Calls = append(plan.Calls, query.Call{Func: fn, Field: arg.Name})
+		default:
+			return fmt.Errorf("%s can't be selected", f.Expr)
+		}
+
+		if f.Alias != "" {
+			name = f.Alias
+		}
+		if names[name] || name == timeColumn {
+			return fmt.Errorf("duplicate column %q, use AS to rename it", name)
+		}
+		names[name] = true
+		plan.Columns = append(plan.Columns, name)
+	}
+	if raw && len(plan.Calls) > 0 {
+		return fmt.Errorf("raw fields and aggregates can't be mixed")
+	}
+	return nil
+}
+
+func planDimensions(plan *query.Plan, dims []Expr) error {
+	hasWindow := false
+	for _, dim := range dims {
+		switch d := dim.(type) {
+		case *Call:
+			if hasWindow {
+				return fmt.Errorf("GROUP BY time() can only be used once")
+			}
+			hasWindow = true
+			plan.Window = int64(d.Args[0].(*DurationLiteral).Val)
+		case *VarRef:
+			plan.GroupBy = append(plan.GroupBy, d.Name)
+		case *Wildcard:
+			return fmt.Errorf("GROUP BY * is not supported, name the tags")
+		}
+	}
+	if hasWindow && len(plan.Calls) == 0 {
+		return fmt.Errorf("GROUP BY time() needs an aggregate function")
+	}
+	return nil
+}
+
+// planCondition takes the time bounds out of the top level AND terms of expr
+// and turns the rest into series and point filters.
+func planCondition(plan *query.Plan, expr Expr, now time.Time) error {
+	var rest []Expr
+	for _, term := range andTerms(expr) {
+		b, ok := term.(*BinaryExpr)
+		if ok && isTimeRef(b.LHS) {
+			if err := planTime(plan, b, now); err != nil {
+				return err
+			}
+			continue
+		}
+		rest = append(rest, term)
+	}
+
+	var conds []condition
+	usesFields := false
+	for _, term := range rest {
+		cond, fields, err := compile(term)
+		if err != nil {
+			return err
+		}
+		conds = append(conds, cond)
+		usesFields = usesFields || fields
+	}
+	if len(conds) == 0 {
+		return nil
+	}
+	all := func(tags, fields map[string]string) bool {
+		for _, cond := range conds {
+			if !cond(tags, fields) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// tag only conditions select series, the rest is checked on every point
+	if usesFields {
+		plan.Filter = func(point *ingestpb.Point) bool { return all(point.Tag, point.Fields) }
+	} else {
+		plan.Match = func(measurement string, tags map[string]string) bool { return all(tags, nil) }
+	}
+	return nil
+}
+
+// andTerms flattens the top level AND terms of expr
+func andTerms(expr Expr) []Expr {
+	if p, ok := expr.(*ParenExpr); ok {
+		return andTerms(p.Expr)
+	}
+	if b, ok := expr.(*BinaryExpr); ok && b.Op == "AND" {
+		return append(andTerms(b.LHS), andTerms(b.RHS)...)
+	}
+	return []Expr{expr}
+}
+
+func isTimeRef(expr Expr) bool {
+	v, ok := expr.(*VarRef)
+	return ok && v.Name == timeColumn
+}
+
+// planTime narrows the time range of plan by a comparison on time
+func planTime(plan *query.Plan, b *BinaryExpr, now time.Time) error {
+	ts, err := evalTime(b.RHS, now)
+	if err != nil {
+		return err
+	}
+	switch b.Op {
+	case ">":
+		plan.From = max(plan.From, addTime(ts, 1))
+	case ">=":
+		plan.From = max(plan.From, ts)
+	case "<":
+		plan.To = min(plan.To, addTime(ts, -1))
+	case "<=":
+		plan.To = min(plan.To, ts)
+	case "=":
+		plan.From = max(plan.From, ts)
+		plan.To = min(plan.To, ts)
+	default:
+		return fmt.Errorf("operator %s can't be used with time", b.Op)
+	}
+	return nil
+}
+
+// evalTime resolves now(), RFC3339 strings and nanosecond timestamps, with
+// durations added or subtracted, to unix nanoseconds.
+func evalTime(expr Expr, now time.Time) (int64, error) {
+	switch e := expr.(type) {
+	case *Call:
+		if e.Name != "now" {
+			return 0, fmt.Errorf("unknown function %s in time condition", e.Name)
+		}
+		return now.UnixNano(), nil
+	case *StringLiteral:
+		t, err := time.Parse(time.RFC3339Nano, e.Val)
+		if err != nil {
+			return 0, fmt.Errorf("invalid time %s, use RFC3339 such as '2024-01-02T15:04:05Z'", e)
+		}
+		return t.UnixNano(), nil
+	case *NumberLiteral:
+		if e.IsInt {
+			return e.Int, nil
+		}
+		if e.Val != math.Trunc(e.Val) || e.Val < math.MinInt64 || e.Val >= math.MaxInt64 {
+			return 0, fmt.Errorf("invalid timestamp %s, use unix nanoseconds", e)
+		}
+		return int64(e.Val), nil
+	case *BinaryExpr:
+		if e.Op != "+" && e.Op != "-" {
+			break
+		}
+		base, err := evalTime(e.LHS, now)
+		if err != nil {
+			return 0, err
+		}
+		d, ok := e.RHS.(*DurationLiteral)
+		if !ok {
+			return 0, fmt.Errorf("only durations can be added to or subtracted from a time, got %s", e.RHS)
+		}
+		if e.Op == "-" {
+			return addTime(base, -int64(d.Val)), nil
+		}
+		return addTime(base, int64(d.Val)), nil
+	}
+	return 0, fmt.Errorf("%s is not a time", expr)
+}
+
+// addTime adds d nanoseconds to ts, stopping at the ends of int64 instead of
+// wrapping around
+func addTime(ts, d int64) int64 {
+	switch {
+	case d > 0 && ts > math.MaxInt64-d:
+		return math.MaxInt64
+	case d < 0 && ts < math.MinInt64-d:
+		return math.MinInt64
+	}
+	return ts + d
+}
+
+// compile turns a condition without time terms into a function. fields
+// reports whether it reads point fields.
+func compile(expr Expr) (cond condition, fields bool, err error) {
+	switch e := expr.(type) {
+	case *ParenExpr:
+		return compile(e.Expr)
+	case *BinaryExpr:
+		switch e.Op {
+		case "AND", "OR":
+			lhs, lf, err := compile(e.LHS)
+			if err != nil {
+				return nil, false, err
+			}
+			rhs, rf, err := compile(e.RHS)
+			if err != nil {
+				return nil, false, err
+			}
+			if e.Op == "AND" {
+				return func(t, f map[string]string) bool { return lhs(t, f) && rhs(t, f) }, lf || rf, nil
+			}
+			return func(t, f map[string]string) bool { return lhs(t, f) || rhs(t, f) }, lf || rf, nil
+		}
+		return compileComparison(e)
+	}
+	return nil, false, fmt.Errorf("%s is not a condition", expr)
+}
+
+func compileComparison(b *BinaryExpr) (condition, bool, error) {
+	ref, ok := b.LHS.(*VarRef)
+	if !ok {
+		return nil, false, fmt.Errorf("the left side of %s must be a tag or field name", b)
+	}
+	if ref.Name == timeColumn {
+		return nil, false, fmt.Errorf("time conditions must be combined with AND at the top level")
+	}
+	name := ref.Name
+
+	switch rhs := b.RHS.(type) {
+	case *StringLiteral:
+		// strings compare tags, a missing tag is the empty string
+		switch b.Op {
+		case "=":
+			return func(tags, _ map[string]string) bool { return tags[name] == rhs.Val }, false, nil
+		case "!=":
+			return func(tags, _ map[string]string) bool { return tags[name] != rhs.Val }, false, nil
+		}
+		return nil, false, fmt.Errorf("operator %s can't compare tag %s, use =, !=, =~ or !~", b.Op, ref)
+	case *RegexLiteral:
+		switch b.Op {
+		case "=~":
+			return func(tags, _ map[string]string) bool { return rhs.Val.MatchString(tags[name]) }, false, nil
+		case "!~":
+			return func(tags, _ map[string]string) bool { return !rhs.Val.MatchString(tags[name]) }, false, nil
+		}
+		return nil, false, fmt.Errorf("regexes can only be used with =~ and !~")
+	case *NumberLiteral:
+		// numbers compare fields, points without a numeric value don't match
+		compare, err := numberComparison(b.Op)
+		if err != nil {
+			return nil, false, err
+		}
+		return func(_, fields map[string]string) bool {
+			raw, ok := fields[name]
+			if !ok {
+				return false
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+			return err == nil && compare(v, rhs.Val)
+		}, true, nil
+	}
+	return nil, false, fmt.Errorf("%s can't be compared with %s, use a string for tags or a number for fields", ref, b.RHS)
+}
+
+func numberComparison(op string) (func(a, b float64) bool, error) {
+	switch op {
+	case "=":
+		return func(a, b float64) bool { return a == b }, nil
+	case "!=":
+		return func(a, b float64) bool { return a != b }, nil
+	case "<":
+		return func(a, b float64) bool { return a < b }, nil
+	case "<=":
+		return func(a, b float64) bool { return a <= b }, nil
+	case ">":
+		return func(a, b float64) bool { return a > b }, nil
+	case ">=":
+		return func(a, b float64) bool { return a >= b }, nil
+	}
+	return nil, fmt.Errorf("operator %s can't compare numbers", op)
+}
@@ -0,0 +1,151 @@
+package tickql
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heyyakash/tickdb/internal/query"
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+var now = time.Unix(1700000000, 0)
+
+func plan(t *testing.T, q string) *query.Plan {
+	t.Helper()
+	stmt, err := Parse(q)
+	if err != nil {
+		t.Fatalf("Parse(%q) failed: %v", q, err)
+	}
+	p, err := Plan(stmt, now)
+	if err != nil {
+		t.Fatalf("Plan(%q) failed: %v", q, err)
+	}
+	return p
+}
+
+func TestPlanTime(t *testing.T) {
+	const ts = 1700000000123456789
+	tests := []struct {
+		where    string
+		from, to int64
+	}{
+		{"time > 1700000000123456789", ts + 1, math.MaxInt64},
+		{"time >= 1700000000123456789", ts, math.MaxInt64},
+		{"time < 1700000000123456789", math.MinInt64, ts - 1},
+		{"time <= 1700000000123456789", math.MinInt64, ts},
+		{"time = 1700000000123456789", ts, ts},
+		{"time >= 10 AND time < 20 AND time > 12", 13, 19},
+		{"time >= now() - 1h", now.Add(-time.Hour).UnixNano(), math.MaxInt64},
+		{"time < now() + 30m AND time > now() - 1d", now.Add(-24*time.Hour).UnixNano() + 1, now.Add(30*time.Minute).UnixNano() - 1},
+		{"time >= '2023-11-14T22:13:20.5Z'", now.UnixNano() + 5e8, math.MaxInt64},
+		{"time >= 1.7e18", 1.7e18, math.MaxInt64},
+		// bounds at the ends of int64 saturate instead of wrapping around
+		{"time < -9223372036854775808", math.MinInt64, math.MinInt64},
+		{"time >= -9223372036854775808 - 1h", math.MinInt64, math.MaxInt64},
+		{"time <= 9223372036854775807 + 1h", math.MinInt64, math.MaxInt64},
+	}
+	for _, tt := range tests {
+		p := plan(t, "SELECT v FROM cpu WHERE "+tt.where)
+		if p.From != tt.from || p.To != tt.to {
+			t.Errorf("%s: range is [%d, %d], want [%d, %d]", tt.where, p.From, p.To, tt.from, tt.to)
+		}
+		if p.Match != nil || p.Filter != nil {
+			t.Errorf("%s: time bounds left a filter behind", tt.where)
+		}
+	}
+}
+
+func TestAddTime(t *testing.T) {
+	tests := []struct {
+		ts, d, want int64
+	}{
+		{10, 5, 15},
+		{10, -5, 5},
+		{math.MaxInt64, 1, math.MaxInt64},
+		{math.MaxInt64 - 1, 1, math.MaxInt64},
+		{math.MinInt64, -1, math.MinInt64},
+		{math.MinInt64 + 1, -1, math.MinInt64},
+		{math.MaxInt64, math.MinInt64, -1},
+		{-10, math.MaxInt64, math.MaxInt64 - 10},
+	}
+	for _, tt := range tests {
+		if got := addTime(tt.ts, tt.d); got != tt.want {
+			t.Errorf("addTime(%d, %d) = %d, want %d", tt.ts, tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestPlanConditions(t *testing.T) {
+	point := func(host string, v string) *ingestpb.Point {
+		return &ingestpb.Point{Measurement: "cpu", Tag: map[string]string{"host": host}, Fields: map[string]string{"v": v}}
+	}
+
+	// tag only conditions select series
+	p := plan(t, "SELECT v FROM cpu WHERE (host = 'a' OR host =~ /^b/) AND host != 'bb'")
+	if p.Match == nil || p.Filter != nil {
+		t.Fatal("tag conditions should only match series")
+	}
+	for host, want := range map[string]bool{"a": true, "b": true, "bb": false, "c": false} {
+		if got := p.Match("cpu", map[string]string{"host": host}); got != want {
+			t.Errorf("host %s matched = %v, want %v", host, got, want)
+		}
+	}
+
+	// conditions on fields are checked on every point
+	p = plan(t, "SELECT v FROM cpu WHERE host = 'a' AND v > 1e3 AND time > 0")
+	if p.Filter == nil || p.Match != nil {
+		t.Fatal("field conditions should filter points")
+	}
+	if p.From != 1 {
+		t.Errorf("From = %d, want 1", p.From)
+	}
+	tests := []struct {
+		point *ingestpb.Point
+		want  bool
+	}{
+		{point("a", "1500"), true},
+		{point("a", " 1500 "), true},
+		{point("a", "1000"), false},
+		{point("b", "1500"), false},
+		{point("a", "high"), false},
+		{&ingestpb.Point{Tag: map[string]string{"host": "a"}, Fields: map[string]string{"w": "1500"}}, false},
+	}
+	for _, tt := range tests {
+		if got := p.Filter(tt.point); got != tt.want {
+			t.Errorf("Filter(%v) = %v, want %v", tt.point, got, tt.want)
+		}
+	}
+}
+
+func TestPlanErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"SELECT v FROM cpu WHERE time > 10 AND time < 5", "time range of the query is empty"},
+		{"SELECT v FROM cpu WHERE time != 10", "can't be used with time"},
+		{"SELECT v FROM cpu WHERE time > 1.5", "invalid timestamp"},
+		{"SELECT v FROM cpu WHERE time > 1e19", "invalid timestamp"},
+		{"SELECT v FROM cpu WHERE time > 'yesterday'", "invalid time"},
+		{"SELECT v FROM cpu WHERE time > now() - 5", "only durations"},
+		{"SELECT v FROM cpu WHERE time > v", "is not a time"},
+		{"SELECT v FROM cpu WHERE host > 'a'", "can't compare tag"},
+		{"SELECT v FROM cpu WHERE v =~ 1", "can't compare numbers"},
+		{"SELECT v, mean(v) FROM cpu", "can't be mixed"},
+		{"SELECT median(v) FROM cpu", "unknown aggregate function"},
+		{"SELECT v FROM cpu GROUP BY time(1m)", "needs an aggregate function"},
+		{"SELECT mean(v) FROM cpu FILL(0)", "FILL needs GROUP BY time()"},
+		{"SELECT v, v FROM cpu", "duplicate column"},
+	}
+	for _, tt := range tests {
+		stmt, err := Parse(tt.query)
+		if err == nil {
+			_, err = Plan(stmt, now)
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error = %v, want %q", tt.query, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// certificate is a generated certificate with its key
+type certificate struct {
+	cert *x509.Certificate
+	der  []byte
+	key  *ecdsa.PrivateKey
+}
+
+var serial int64
+
+func newCertificate(t *testing.T, cn string, parent *certificate, isCA bool, usage x509.ExtKeyUsage) *certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial++
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if isCA {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+		tmpl.DNSNames = []string{"localhost"}
+		tmpl.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &certificate{cert: cert, der: der, key: key}
+}
+
+// write saves the certificate and key as PEM files, with a modification time
+// of at so reloads notice them
+func (c *certificate) write(t *testing.T, certFile, keyFile string, at time.Time) {
+	t.Helper()
+	writePEM(t, certFile, "CERTIFICATE", c.der, at)
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER, at)
+}
+
+func (c *certificate) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func writePEM(t *testing.T, path, kind string, der []byte, at time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, at, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serve serves HTTPS with the configuration of r until the test ends and
+// returns its address
+func serve(t *testing.T, r *Reloader) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, "ok")
+	})}
+	go srv.Serve(tls.NewListener(lis, r.Config()))
+	t.Cleanup(func() { srv.Close() })
+	return "https://" + lis.Addr().String()
+}
+
+// get makes a request on a new connection and returns the common name of
+// the server certificate
+func get(url string, ca *certificate, client *certificate) (string, error) {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	cfg := &tls.Config{RootCAs: roots}
+	if client != nil {
+		cfg.Certificates = []tls.Certificate{client.tlsCertificate()}
+	}
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}, Timeout: 5 * time.Second}
+	resp, err := c.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		return "", err
+	}
+	return resp.TLS.PeerCertificates[0].Subject.CommonName, nil
+}
+
+func discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestServesTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCertificate(t, "ca", nil, true, 0)
+	server := newCertificate(t, "server", ca, false, x509.ExtKeyUsageServerAuth)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	server.write(t, certFile, keyFile, time.Now())
+
+	r, err := New(certFile, keyFile, "", discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := serve(t, r)
+	cn, err := get(url, ca, nil)
+	if err != nil {
+		t.Fatalf("request without a client certificate failed: %v", err)
+	}
+	if cn != "server" {
+		t.Errorf("server certificate is %q, want server", cn)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCertificate(t, "ca", nil, true, 0)
+	server := newCertificate(t, "server", ca, false, x509.ExtKeyUsageServerAuth)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	server.write(t, certFile, keyFile, time.Now())
+	caFile := filepath.Join(dir, "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", ca.der, time.Now())
+
+	r, err := New(certFile, keyFile, caFile, discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := serve(t, r)
+
+	if _, err := get(url, ca, nil); err == nil {
+		t.Error("request without a client certificate succeeded")
+	}
+	otherCA := newCertificate(t, "other ca", nil, true, 0)
+	stranger := newCertificate(t, "stranger", otherCA, false, x509.ExtKeyUsageClientAuth)
+	if _, err := get(url, ca, stranger); err == nil {
+		t.Error("request with a client certificate of another CA succeeded")
+	}
+	client := newCertificate(t, "client", ca, false, x509.ExtKeyUsageClientAuth)
+	if _, err := get(url, ca, client); err != nil {
+		t.Errorf("request with a client certificate failed: %v", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCertificate(t, "ca", nil, true, 0)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	start := time.Now().Add(-time.Minute)
+	newCertificate(t, "first", ca, false, x509.ExtKeyUsageServerAuth).write(t, certFile, keyFile, start)
+
+	r, err := New(certFile, keyFile, "", discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := serve(t, r)
+	if cn, err := get(url, ca, nil); err != nil || cn != "first" {
+		t.Fatalf("got certificate %q, %v, want first", cn, err)
+	}
+
+	// a broken certificate is rejected and the previous one stays in use
+	writePEM(t, certFile, "CERTIFICATE", []byte("not a certificate"), start.Add(time.Second))
+	if err := r.Reload(); err == nil {
+		t.Error("reloading a broken certificate succeeded")
+	}
+	if cn, err := get(url, ca, nil); err != nil || cn != "first" {
+		t.Fatalf("after a failed reload got certificate %q, %v, want first", cn, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+	newCertificate(t, "second", ca, false, x509.ExtKeyUsageServerAuth).write(t, certFile, keyFile, start.Add(2*time.Second))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		cn, err := get(url, ca, nil)
+		if err == nil && cn == "second" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("certificate wasn't reloaded, got %q, %v", cn, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,48 @@
+package wal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
+)
+
+// maxRecordSize bounds the length of a line ScanSegment reads
+const maxRecordSize = 16 << 20
+
+// ScanSegment reads the records of a WAL segment and calls fn with the line
+// number, byte offset and point of each. It stops at the first line that
+// isn't a record and returns the offset after the last good record along with
+// the reason it stopped. A nil error means every line is a record.
+func ScanSegment(r io.Reader, fn func(line int, offset int64, p *ingestpb.Point) error) (int64, error) {
+	br := bufio.NewReader(r)
+	var offset int64
+	for line := 1; ; line++ {
+		data, err := br.ReadSlice('\n')
+		for err == bufio.ErrBufferFull && len(data) < maxRecordSize {
+			var more []byte
+			more, err = br.ReadSlice('\n')
+			data = append(bytes.Clone(data), more...)
+		}
+		if err == io.EOF && len(data) == 0 {
+			return offset, nil
+		}
+		if err != nil && err != io.EOF {
+			if err == bufio.ErrBufferFull {
+				err = fmt.Errorf("record is longer than %d bytes", maxRecordSize)
+			}
+			return offset, fmt.Errorf("couldn't read line %d at %d : %w", line, offset, err)
+		}
+		var p ingestpb.Point
+		if err := json.Unmarshal(bytes.TrimSpace(data), &p); err != nil {
+			return offset, fmt.Errorf("couldn't parse record on line %d at %d : %w", line, offset, err)
+		}
+		if err := fn(line, offset, &p); err != nil {
+			return offset, err
+		}
+		offset += int64(len(data))
+	}
+}
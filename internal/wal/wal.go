@@ -3,7 +3,8 @@ package wal
 import (
 	"bufio"
 	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -11,6 +12,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/heyyakash/tickdb/internal/metrics"
 	ingestpb "github.com/heyyakash/tickdb/proto/gen/ingest"
 )
 
@@ -19,11 +21,12 @@ type WAL struct {
 	path              string
 	walStartTimeStamp int64
 	file              *os.File
+	logger            *slog.Logger
 }
 
-func New(path string) (*WAL, error) {
-	cwd, _ := os.Getwd()
-	walPath := filepath.Join(cwd, "wal")
+// New opens the active WAL segment in dir, or starts a new one.
+func New(dir string, logger *slog.Logger) (*WAL, error) {
+	walPath := dir
 
 	if err := os.MkdirAll(walPath, 0755); err != nil {
 		return nil, err
@@ -43,9 +46,11 @@ func New(path string) (*WAL, error) {
 			walStartTSString := strings.Split(v.Name(), ".")[0]
 			walStartTS, err := strconv.ParseInt(walStartTSString, 10, 64)
 			if err != nil {
-				log.Fatal("Couldn't extract Wal Start Timestamp :", err)
+				f.Close()
+				return nil, fmt.Errorf("couldn't extract wal start timestamp from %s : %w", v.Name(), err)
 			}
-			return &WAL{file: f, path: walPath, walStartTimeStamp: walStartTS}, nil
+			logger.Debug("Opened WAL segment", "segment", v.Name())
+			return &WAL{file: f, path: walPath, walStartTimeStamp: walStartTS, logger: logger}, nil
 		}
 	}
 
@@ -55,7 +60,8 @@ func New(path string) (*WAL, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &WAL{file: f, path: walPath, walStartTimeStamp: currentTimeStamp}, nil
+	logger.Debug("Created WAL segment", "segment", filepath.Base(f.Name()))
+	return &WAL{file: f, path: walPath, walStartTimeStamp: currentTimeStamp, logger: logger}, nil
 }
 
 func (w *WAL) GetWalStartTime() int64 {
@@ -64,14 +70,6 @@ func (w *WAL) GetWalStartTime() int64 {
 	return w.walStartTimeStamp
 }
 
-// func New(path string) (*WAL, error) {
-// 	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	return &WAL{file: f}, nil
-// }
-
 func (w *WAL) Append(record any) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -81,60 +79,137 @@ func (w *WAL) Append(record any) error {
 		return err
 	}
 
+	start := time.Now()
 	_, err = w.file.Write(append(data, '\n'))
+	metrics.WALAppendDuration.Observe(time.Since(start).Seconds())
 
 	if err != nil {
 		return err
 	}
 
-	return w.file.Sync()
+	start = time.Now()
+	err = w.file.Sync()
+	metrics.WALFsyncDuration.Observe(time.Since(start).Seconds())
+	return err
 }
 
-func (w *WAL) Flush() {
+// Flush closes the active segment and starts a new one. The new segment is
+// created before the old one is renamed, so on error the WAL keeps appending
+// to the old segment.
+func (w *WAL) Flush() error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	// a flush within the same second as the segment start would reuse its
+	// name, move the new segment to the next free second
+	startTimeStamp := time.Now().Unix()
+	if startTimeStamp <= w.walStartTimeStamp {
+		startTimeStamp = w.walStartTimeStamp + 1
+	}
+	newPath := filepath.Join(w.path, strconv.FormatInt(startTimeStamp, 10)+".new.log")
+	f, err := os.OpenFile(newPath, os.O_APPEND|os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("couldn't create new wal segment : %w", err)
+	}
+
 	fileName := w.file.Name()
 	newFileName := strings.ReplaceAll(fileName, "new.log", "closed.log")
-	// newFilePath := filepath.Join(w.path, newFileName)
 	if err := os.Rename(fileName, newFileName); err != nil {
-		log.Fatal("Error renaming Wal file :", err)
+		f.Close()
+		os.Remove(newPath)
+		return fmt.Errorf("couldn't close wal segment %s : %w", filepath.Base(fileName), err)
 	}
 	w.file.Close()
-	currentTimeStamp := time.Now().Unix()
-	newWalName := strconv.FormatInt(currentTimeStamp, 10)
-	f, err := os.Create(filepath.Join(w.path, newWalName+".new.log"))
-	if err != nil {
-		log.Fatal("Couln't create new WAL")
-	}
 	w.file = f
-
+	w.walStartTimeStamp = startTimeStamp
+	w.logger.Debug("Rotated WAL segment", "closed", filepath.Base(newFileName), "segment", filepath.Base(newPath))
+	return nil
 }
-func (w *WAL) Replay() []*ingestpb.Point {
+
+// Replay reads the points in the active segment. If a record can't be read
+// the points before it are returned along with the error.
+func (w *WAL) Replay() ([]*ingestpb.Point, error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
 	f, err := os.Open(w.file.Name())
 	if err != nil {
-		log.Fatalf("Unable to open file for wal replay")
+		return nil, fmt.Errorf("couldn't open wal for replay : %w", err)
 	}
 	defer f.Close()
 
 	points := []*ingestpb.Point{}
 
 	scanner := bufio.NewScanner(f)
+	line := 0
 	for scanner.Scan() {
-		line := scanner.Text()
+		line += 1
 		var p ingestpb.Point
-		if err := json.Unmarshal([]byte(line), &p); err != nil {
-			log.Fatalf("Couldn't unmarhsal line to valid json")
+		if err := json.Unmarshal(scanner.Bytes(), &p); err != nil {
+			return points, fmt.Errorf("couldn't parse record on line %d of %s : %w", line, filepath.Base(f.Name()), err)
 		}
 		points = append(points, &p)
 	}
+	if err := scanner.Err(); err != nil {
+		return points, fmt.Errorf("couldn't read %s after line %d : %w", filepath.Base(f.Name()), line, err)
+	}
+
+	return points, nil
+}
+
+// Tail opens the active segment for reading and returns it with its size.
+// Records are only appended whole, so the first size bytes end with a
+// complete record even while appends go on.
+func (w *WAL) Tail() (*os.File, int64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	f, err := os.Open(w.file.Name())
+	if err != nil {
+		return nil, 0, fmt.Errorf("couldn't open wal segment : %w", err)
+	}
+	info, err := w.file.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, fmt.Errorf("couldn't stat wal segment : %w", err)
+	}
+	return f, info.Size(), nil
+}
 
-	return points
+// Segment describes a WAL file on disk.
+type Segment struct {
+	Name   string `json:"name"`
+	Bytes  int64  `json:"bytes"`
+	Active bool   `json:"active"`
+}
+
+// Segments lists the WAL files in the WAL directory.
+func (w *WAL) Segments() ([]Segment, error) {
+	w.mu.Lock()
+	active := filepath.Base(w.file.Name())
+	w.mu.Unlock()
+
+	entries, err := os.ReadDir(w.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []Segment
+	for _, v := range entries {
+		if v.IsDir() || !strings.HasSuffix(v.Name(), ".log") {
+			continue
+		}
+		info, err := v.Info()
+		if err != nil {
+			continue
+		}
+		segments = append(segments, Segment{Name: v.Name(), Bytes: info.Size(), Active: v.Name() == active})
+	}
+	return segments, nil
 }
 
 func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	return w.file.Close()
 }
@@ -21,6 +21,142 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Unit of timestamp_unix_nano in a write request. Unspecified means nanoseconds.
+type Precision int32
+
+const (
+	Precision_PRECISION_UNSPECIFIED  Precision = 0
+	Precision_PRECISION_SECONDS      Precision = 1
+	Precision_PRECISION_MILLISECONDS Precision = 2
+	Precision_PRECISION_MICROSECONDS Precision = 3
+	Precision_PRECISION_NANOSECONDS  Precision = 4
+)
+
+// Enum value maps for Precision.
+var (
+	Precision_name = map[int32]string{
+		0: "PRECISION_UNSPECIFIED",
+		1: "PRECISION_SECONDS",
+		2: "PRECISION_MILLISECONDS",
+		3: "PRECISION_MICROSECONDS",
+		4: "PRECISION_NANOSECONDS",
+	}
+	Precision_value = map[string]int32{
+		"PRECISION_UNSPECIFIED":  0,
+		"PRECISION_SECONDS":      1,
+		"PRECISION_MILLISECONDS": 2,
+		"PRECISION_MICROSECONDS": 3,
+		"PRECISION_NANOSECONDS":  4,
+	}
+)
+
+func (x Precision) Enum() *Precision {
+	p := new(Precision)
+	*p = x
+	return p
+}
+
+func (x Precision) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Precision) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_ingest_proto_enumTypes[0].Descriptor()
+}
+
+func (Precision) Type() protoreflect.EnumType {
+	return &file_proto_ingest_proto_enumTypes[0]
+}
+
+func (x Precision) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Precision.Descriptor instead.
+func (Precision) EnumDescriptor() ([]byte, []int) {
+	return file_proto_ingest_proto_rawDescGZIP(), []int{0}
+}
+
+type RejectCode int32
+
+const (
+	RejectCode_REJECT_CODE_UNSPECIFIED               RejectCode = 0
+	RejectCode_REJECT_CODE_MISSING_POINT             RejectCode = 1
+	RejectCode_REJECT_CODE_MISSING_MEASUREMENT       RejectCode = 2
+	RejectCode_REJECT_CODE_TIMESTAMP_OUT_OF_RANGE    RejectCode = 3
+	RejectCode_REJECT_CODE_NO_FIELDS                 RejectCode = 4
+	RejectCode_REJECT_CODE_RESERVED_TAG              RejectCode = 5
+	RejectCode_REJECT_CODE_INVALID_CHARACTER         RejectCode = 6
+	RejectCode_REJECT_CODE_PIPELINE_UNAVAILABLE      RejectCode = 7
+	RejectCode_REJECT_CODE_TIMESTAMP_WRONG_PRECISION RejectCode = 8
+	// the API token may not write to the measurement
+	RejectCode_REJECT_CODE_MEASUREMENT_NOT_ALLOWED RejectCode = 9
+	// a points or bytes per second limit of the database or API token was
+	// exceeded, the write can be retried later
+	RejectCode_REJECT_CODE_RATE_LIMITED RejectCode = 10
+	// the database reached its series or stored bytes quota
+	RejectCode_REJECT_CODE_QUOTA_EXCEEDED RejectCode = 11
+)
+
+// Enum value maps for RejectCode.
+var (
+	RejectCode_name = map[int32]string{
+		0:  "REJECT_CODE_UNSPECIFIED",
+		1:  "REJECT_CODE_MISSING_POINT",
+		2:  "REJECT_CODE_MISSING_MEASUREMENT",
+		3:  "REJECT_CODE_TIMESTAMP_OUT_OF_RANGE",
+		4:  "REJECT_CODE_NO_FIELDS",
+		5:  "REJECT_CODE_RESERVED_TAG",
+		6:  "REJECT_CODE_INVALID_CHARACTER",
+		7:  "REJECT_CODE_PIPELINE_UNAVAILABLE",
+		8:  "REJECT_CODE_TIMESTAMP_WRONG_PRECISION",
+		9:  "REJECT_CODE_MEASUREMENT_NOT_ALLOWED",
+		10: "REJECT_CODE_RATE_LIMITED",
+		11: "REJECT_CODE_QUOTA_EXCEEDED",
+	}
+	RejectCode_value = map[string]int32{
+		"REJECT_CODE_UNSPECIFIED":               0,
+		"REJECT_CODE_MISSING_POINT":             1,
+		"REJECT_CODE_MISSING_MEASUREMENT":       2,
+		"REJECT_CODE_TIMESTAMP_OUT_OF_RANGE":    3,
+		"REJECT_CODE_NO_FIELDS":                 4,
+		"REJECT_CODE_RESERVED_TAG":              5,
+		"REJECT_CODE_INVALID_CHARACTER":         6,
+		"REJECT_CODE_PIPELINE_UNAVAILABLE":      7,
+		"REJECT_CODE_TIMESTAMP_WRONG_PRECISION": 8,
+		"REJECT_CODE_MEASUREMENT_NOT_ALLOWED":   9,
+		"REJECT_CODE_RATE_LIMITED":              10,
+		"REJECT_CODE_QUOTA_EXCEEDED":            11,
+	}
+)
+
+func (x RejectCode) Enum() *RejectCode {
+	p := new(RejectCode)
+	*p = x
+	return p
+}
+
+func (x RejectCode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RejectCode) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_ingest_proto_enumTypes[1].Descriptor()
+}
+
+func (RejectCode) Type() protoreflect.EnumType {
+	return &file_proto_ingest_proto_enumTypes[1]
+}
+
+func (x RejectCode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RejectCode.Descriptor instead.
+func (RejectCode) EnumDescriptor() ([]byte, []int) {
+	return file_proto_ingest_proto_rawDescGZIP(), []int{1}
+}
+
 type Point struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
 	Measurement       string                 `protobuf:"bytes,1,opt,name=measurement,proto3" json:"measurement,omitempty"`
@@ -90,8 +226,11 @@ func (x *Point) GetFields() map[string]string {
 }
 
 type WriteRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Point         *Point                 `protobuf:"bytes,1,opt,name=point,proto3" json:"point,omitempty"`
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Point     *Point                 `protobuf:"bytes,1,opt,name=point,proto3" json:"point,omitempty"`
+	Precision Precision              `protobuf:"varint,2,opt,name=precision,proto3,enum=tickdb.ingest.Precision" json:"precision,omitempty"`
+	// database to write to, the default database when empty
+	Database      string `protobuf:"bytes,3,opt,name=database,proto3" json:"database,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -133,9 +272,26 @@ func (x *WriteRequest) GetPoint() *Point {
 	return nil
 }
 
+func (x *WriteRequest) GetPrecision() Precision {
+	if x != nil {
+		return x.Precision
+	}
+	return Precision_PRECISION_UNSPECIFIED
+}
+
+func (x *WriteRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
 type BatchWriteRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Points        []*Point               `protobuf:"bytes,1,rep,name=points,proto3" json:"points,omitempty"`
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Points    []*Point               `protobuf:"bytes,1,rep,name=points,proto3" json:"points,omitempty"`
+	Precision Precision              `protobuf:"varint,2,opt,name=precision,proto3,enum=tickdb.ingest.Precision" json:"precision,omitempty"`
+	// database to write to, the default database when empty
+	Database      string `protobuf:"bytes,3,opt,name=database,proto3" json:"database,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -177,18 +333,95 @@ func (x *BatchWriteRequest) GetPoints() []*Point {
 	return nil
 }
 
+func (x *BatchWriteRequest) GetPrecision() Precision {
+	if x != nil {
+		return x.Precision
+	}
+	return Precision_PRECISION_UNSPECIFIED
+}
+
+func (x *BatchWriteRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+// A point that was not accepted. index is the position of the point in the
+// request, so clients can retry just the failures.
+type Rejection struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         uint32                 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Code          RejectCode             `protobuf:"varint,2,opt,name=code,proto3,enum=tickdb.ingest.RejectCode" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Rejection) Reset() {
+	*x = Rejection{}
+	mi := &file_proto_ingest_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Rejection) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Rejection) ProtoMessage() {}
+
+func (x *Rejection) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_ingest_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Rejection.ProtoReflect.Descriptor instead.
+func (*Rejection) Descriptor() ([]byte, []int) {
+	return file_proto_ingest_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Rejection) GetIndex() uint32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *Rejection) GetCode() RejectCode {
+	if x != nil {
+		return x.Code
+	}
+	return RejectCode_REJECT_CODE_UNSPECIFIED
+}
+
+func (x *Rejection) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
 type WriteResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Accepted      uint64                 `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
 	Rejected      uint64                 `protobuf:"varint,2,opt,name=rejected,proto3" json:"rejected,omitempty"`
 	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	Rejections    []*Rejection           `protobuf:"bytes,4,rep,name=rejections,proto3" json:"rejections,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *WriteResponse) Reset() {
 	*x = WriteResponse{}
-	mi := &file_proto_ingest_proto_msgTypes[3]
+	mi := &file_proto_ingest_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -200,7 +433,7 @@ func (x *WriteResponse) String() string {
 func (*WriteResponse) ProtoMessage() {}
 
 func (x *WriteResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_ingest_proto_msgTypes[3]
+	mi := &file_proto_ingest_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -213,7 +446,7 @@ func (x *WriteResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use WriteResponse.ProtoReflect.Descriptor instead.
 func (*WriteResponse) Descriptor() ([]byte, []int) {
-	return file_proto_ingest_proto_rawDescGZIP(), []int{3}
+	return file_proto_ingest_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *WriteResponse) GetAccepted() uint64 {
@@ -237,6 +470,136 @@ func (x *WriteResponse) GetError() string {
 	return ""
 }
 
+func (x *WriteResponse) GetRejections() []*Rejection {
+	if x != nil {
+		return x.Rejections
+	}
+	return nil
+}
+
+type StreamWriteRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Points    []*Point               `protobuf:"bytes,1,rep,name=points,proto3" json:"points,omitempty"`
+	Precision Precision              `protobuf:"varint,2,opt,name=precision,proto3,enum=tickdb.ingest.Precision" json:"precision,omitempty"`
+	// database to write to, the default database when empty
+	Database      string `protobuf:"bytes,3,opt,name=database,proto3" json:"database,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamWriteRequest) Reset() {
+	*x = StreamWriteRequest{}
+	mi := &file_proto_ingest_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamWriteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamWriteRequest) ProtoMessage() {}
+
+func (x *StreamWriteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_ingest_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamWriteRequest.ProtoReflect.Descriptor instead.
+func (*StreamWriteRequest) Descriptor() ([]byte, []int) {
+	return file_proto_ingest_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *StreamWriteRequest) GetPoints() []*Point {
+	if x != nil {
+		return x.Points
+	}
+	return nil
+}
+
+func (x *StreamWriteRequest) GetPrecision() Precision {
+	if x != nil {
+		return x.Precision
+	}
+	return Precision_PRECISION_UNSPECIFIED
+}
+
+func (x *StreamWriteRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+// Sent periodically on a StreamWrite stream and once more when the client
+// closes its side. Counts are totals since the stream was opened.
+type StreamWriteAck struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Accepted      uint64                 `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	Rejected      uint64                 `protobuf:"varint,2,opt,name=rejected,proto3" json:"rejected,omitempty"`
+	LastError     string                 `protobuf:"bytes,3,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamWriteAck) Reset() {
+	*x = StreamWriteAck{}
+	mi := &file_proto_ingest_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamWriteAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamWriteAck) ProtoMessage() {}
+
+func (x *StreamWriteAck) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_ingest_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamWriteAck.ProtoReflect.Descriptor instead.
+func (*StreamWriteAck) Descriptor() ([]byte, []int) {
+	return file_proto_ingest_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *StreamWriteAck) GetAccepted() uint64 {
+	if x != nil {
+		return x.Accepted
+	}
+	return 0
+}
+
+func (x *StreamWriteAck) GetRejected() uint64 {
+	if x != nil {
+		return x.Rejected
+	}
+	return 0
+}
+
+func (x *StreamWriteAck) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
 var File_proto_ingest_proto protoreflect.FileDescriptor
 
 const file_proto_ingest_proto_rawDesc = "" +
@@ -252,19 +615,61 @@ const file_proto_ingest_proto_rawDesc = "" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a9\n" +
 	"\vFieldsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\":\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x8e\x01\n" +
 	"\fWriteRequest\x12*\n" +
-	"\x05point\x18\x01 \x01(\v2\x14.tickdb.ingest.PointR\x05point\"A\n" +
+	"\x05point\x18\x01 \x01(\v2\x14.tickdb.ingest.PointR\x05point\x126\n" +
+	"\tprecision\x18\x02 \x01(\x0e2\x18.tickdb.ingest.PrecisionR\tprecision\x12\x1a\n" +
+	"\bdatabase\x18\x03 \x01(\tR\bdatabase\"\x95\x01\n" +
 	"\x11BatchWriteRequest\x12,\n" +
-	"\x06points\x18\x01 \x03(\v2\x14.tickdb.ingest.PointR\x06points\"]\n" +
+	"\x06points\x18\x01 \x03(\v2\x14.tickdb.ingest.PointR\x06points\x126\n" +
+	"\tprecision\x18\x02 \x01(\x0e2\x18.tickdb.ingest.PrecisionR\tprecision\x12\x1a\n" +
+	"\bdatabase\x18\x03 \x01(\tR\bdatabase\"j\n" +
+	"\tRejection\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\rR\x05index\x12-\n" +
+	"\x04code\x18\x02 \x01(\x0e2\x19.tickdb.ingest.RejectCodeR\x04code\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"\x97\x01\n" +
 	"\rWriteResponse\x12\x1a\n" +
 	"\baccepted\x18\x01 \x01(\x04R\baccepted\x12\x1a\n" +
 	"\brejected\x18\x02 \x01(\x04R\brejected\x12\x14\n" +
-	"\x05error\x18\x03 \x01(\tR\x05error2\xa1\x01\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\x128\n" +
+	"\n" +
+	"rejections\x18\x04 \x03(\v2\x18.tickdb.ingest.RejectionR\n" +
+	"rejections\"\x96\x01\n" +
+	"\x12StreamWriteRequest\x12,\n" +
+	"\x06points\x18\x01 \x03(\v2\x14.tickdb.ingest.PointR\x06points\x126\n" +
+	"\tprecision\x18\x02 \x01(\x0e2\x18.tickdb.ingest.PrecisionR\tprecision\x12\x1a\n" +
+	"\bdatabase\x18\x03 \x01(\tR\bdatabase\"g\n" +
+	"\x0eStreamWriteAck\x12\x1a\n" +
+	"\baccepted\x18\x01 \x01(\x04R\baccepted\x12\x1a\n" +
+	"\brejected\x18\x02 \x01(\x04R\brejected\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\x03 \x01(\tR\tlastError*\x90\x01\n" +
+	"\tPrecision\x12\x19\n" +
+	"\x15PRECISION_UNSPECIFIED\x10\x00\x12\x15\n" +
+	"\x11PRECISION_SECONDS\x10\x01\x12\x1a\n" +
+	"\x16PRECISION_MILLISECONDS\x10\x02\x12\x1a\n" +
+	"\x16PRECISION_MICROSECONDS\x10\x03\x12\x19\n" +
+	"\x15PRECISION_NANOSECONDS\x10\x04*\xa9\x03\n" +
+	"\n" +
+	"RejectCode\x12\x1b\n" +
+	"\x17REJECT_CODE_UNSPECIFIED\x10\x00\x12\x1d\n" +
+	"\x19REJECT_CODE_MISSING_POINT\x10\x01\x12#\n" +
+	"\x1fREJECT_CODE_MISSING_MEASUREMENT\x10\x02\x12&\n" +
+	"\"REJECT_CODE_TIMESTAMP_OUT_OF_RANGE\x10\x03\x12\x19\n" +
+	"\x15REJECT_CODE_NO_FIELDS\x10\x04\x12\x1c\n" +
+	"\x18REJECT_CODE_RESERVED_TAG\x10\x05\x12!\n" +
+	"\x1dREJECT_CODE_INVALID_CHARACTER\x10\x06\x12$\n" +
+	" REJECT_CODE_PIPELINE_UNAVAILABLE\x10\a\x12)\n" +
+	"%REJECT_CODE_TIMESTAMP_WRONG_PRECISION\x10\b\x12'\n" +
+	"#REJECT_CODE_MEASUREMENT_NOT_ALLOWED\x10\t\x12\x1c\n" +
+	"\x18REJECT_CODE_RATE_LIMITED\x10\n" +
+	"\x12\x1e\n" +
+	"\x1aREJECT_CODE_QUOTA_EXCEEDED\x10\v2\xf6\x01\n" +
 	"\rInjestService\x12B\n" +
 	"\x05Write\x12\x1b.tickdb.ingest.WriteRequest\x1a\x1c.tickdb.ingest.WriteResponse\x12L\n" +
 	"\n" +
-	"BatchWrite\x12 .tickdb.ingest.BatchWriteRequest\x1a\x1c.tickdb.ingest.WriteResponseB\x1bZ\x19proto/gen/ingest;ingestpbb\x06proto3"
+	"BatchWrite\x12 .tickdb.ingest.BatchWriteRequest\x1a\x1c.tickdb.ingest.WriteResponse\x12S\n" +
+	"\vStreamWrite\x12!.tickdb.ingest.StreamWriteRequest\x1a\x1d.tickdb.ingest.StreamWriteAck(\x010\x01B\x1bZ\x19proto/gen/ingest;ingestpbb\x06proto3"
 
 var (
 	file_proto_ingest_proto_rawDescOnce sync.Once
@@ -278,29 +683,43 @@ func file_proto_ingest_proto_rawDescGZIP() []byte {
 	return file_proto_ingest_proto_rawDescData
 }
 
-var file_proto_ingest_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_proto_ingest_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_proto_ingest_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
 var file_proto_ingest_proto_goTypes = []any{
-	(*Point)(nil),             // 0: tickdb.ingest.Point
-	(*WriteRequest)(nil),      // 1: tickdb.ingest.WriteRequest
-	(*BatchWriteRequest)(nil), // 2: tickdb.ingest.BatchWriteRequest
-	(*WriteResponse)(nil),     // 3: tickdb.ingest.WriteResponse
-	nil,                       // 4: tickdb.ingest.Point.TagEntry
-	nil,                       // 5: tickdb.ingest.Point.FieldsEntry
+	(Precision)(0),             // 0: tickdb.ingest.Precision
+	(RejectCode)(0),            // 1: tickdb.ingest.RejectCode
+	(*Point)(nil),              // 2: tickdb.ingest.Point
+	(*WriteRequest)(nil),       // 3: tickdb.ingest.WriteRequest
+	(*BatchWriteRequest)(nil),  // 4: tickdb.ingest.BatchWriteRequest
+	(*Rejection)(nil),          // 5: tickdb.ingest.Rejection
+	(*WriteResponse)(nil),      // 6: tickdb.ingest.WriteResponse
+	(*StreamWriteRequest)(nil), // 7: tickdb.ingest.StreamWriteRequest
+	(*StreamWriteAck)(nil),     // 8: tickdb.ingest.StreamWriteAck
+	nil,                        // 9: tickdb.ingest.Point.TagEntry
+	nil,                        // 10: tickdb.ingest.Point.FieldsEntry
 }
 var file_proto_ingest_proto_depIdxs = []int32{
-	4, // 0: tickdb.ingest.Point.tag:type_name -> tickdb.ingest.Point.TagEntry
-	5, // 1: tickdb.ingest.Point.fields:type_name -> tickdb.ingest.Point.FieldsEntry
-	0, // 2: tickdb.ingest.WriteRequest.point:type_name -> tickdb.ingest.Point
-	0, // 3: tickdb.ingest.BatchWriteRequest.points:type_name -> tickdb.ingest.Point
-	1, // 4: tickdb.ingest.InjestService.Write:input_type -> tickdb.ingest.WriteRequest
-	2, // 5: tickdb.ingest.InjestService.BatchWrite:input_type -> tickdb.ingest.BatchWriteRequest
-	3, // 6: tickdb.ingest.InjestService.Write:output_type -> tickdb.ingest.WriteResponse
-	3, // 7: tickdb.ingest.InjestService.BatchWrite:output_type -> tickdb.ingest.WriteResponse
-	6, // [6:8] is the sub-list for method output_type
-	4, // [4:6] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	9,  // 0: tickdb.ingest.Point.tag:type_name -> tickdb.ingest.Point.TagEntry
+	10, // 1: tickdb.ingest.Point.fields:type_name -> tickdb.ingest.Point.FieldsEntry
+	2,  // 2: tickdb.ingest.WriteRequest.point:type_name -> tickdb.ingest.Point
+	0,  // 3: tickdb.ingest.WriteRequest.precision:type_name -> tickdb.ingest.Precision
+	2,  // 4: tickdb.ingest.BatchWriteRequest.points:type_name -> tickdb.ingest.Point
+	0,  // 5: tickdb.ingest.BatchWriteRequest.precision:type_name -> tickdb.ingest.Precision
+	1,  // 6: tickdb.ingest.Rejection.code:type_name -> tickdb.ingest.RejectCode
+	5,  // 7: tickdb.ingest.WriteResponse.rejections:type_name -> tickdb.ingest.Rejection
+	2,  // 8: tickdb.ingest.StreamWriteRequest.points:type_name -> tickdb.ingest.Point
+	0,  // 9: tickdb.ingest.StreamWriteRequest.precision:type_name -> tickdb.ingest.Precision
+	3,  // 10: tickdb.ingest.InjestService.Write:input_type -> tickdb.ingest.WriteRequest
+	4,  // 11: tickdb.ingest.InjestService.BatchWrite:input_type -> tickdb.ingest.BatchWriteRequest
+	7,  // 12: tickdb.ingest.InjestService.StreamWrite:input_type -> tickdb.ingest.StreamWriteRequest
+	6,  // 13: tickdb.ingest.InjestService.Write:output_type -> tickdb.ingest.WriteResponse
+	6,  // 14: tickdb.ingest.InjestService.BatchWrite:output_type -> tickdb.ingest.WriteResponse
+	8,  // 15: tickdb.ingest.InjestService.StreamWrite:output_type -> tickdb.ingest.StreamWriteAck
+	13, // [13:16] is the sub-list for method output_type
+	10, // [10:13] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
 }
 
 func init() { file_proto_ingest_proto_init() }
@@ -313,13 +732,14 @@ func file_proto_ingest_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_ingest_proto_rawDesc), len(file_proto_ingest_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   6,
+			NumEnums:      2,
+			NumMessages:   9,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_proto_ingest_proto_goTypes,
 		DependencyIndexes: file_proto_ingest_proto_depIdxs,
+		EnumInfos:         file_proto_ingest_proto_enumTypes,
 		MessageInfos:      file_proto_ingest_proto_msgTypes,
 	}.Build()
 	File_proto_ingest_proto = out.File
@@ -19,8 +19,9 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	InjestService_Write_FullMethodName      = "/tickdb.ingest.InjestService/Write"
-	InjestService_BatchWrite_FullMethodName = "/tickdb.ingest.InjestService/BatchWrite"
+	InjestService_Write_FullMethodName       = "/tickdb.ingest.InjestService/Write"
+	InjestService_BatchWrite_FullMethodName  = "/tickdb.ingest.InjestService/BatchWrite"
+	InjestService_StreamWrite_FullMethodName = "/tickdb.ingest.InjestService/StreamWrite"
 )
 
 // InjestServiceClient is the client API for InjestService service.
@@ -29,6 +30,7 @@ const (
 type InjestServiceClient interface {
 	Write(ctx context.Context, in *WriteRequest, opts ...grpc.CallOption) (*WriteResponse, error)
 	BatchWrite(ctx context.Context, in *BatchWriteRequest, opts ...grpc.CallOption) (*WriteResponse, error)
+	StreamWrite(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[StreamWriteRequest, StreamWriteAck], error)
 }
 
 type injestServiceClient struct {
@@ -59,12 +61,26 @@ func (c *injestServiceClient) BatchWrite(ctx context.Context, in *BatchWriteRequ
 	return out, nil
 }
 
+func (c *injestServiceClient) StreamWrite(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[StreamWriteRequest, StreamWriteAck], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &InjestService_ServiceDesc.Streams[0], InjestService_StreamWrite_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamWriteRequest, StreamWriteAck]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type InjestService_StreamWriteClient = grpc.BidiStreamingClient[StreamWriteRequest, StreamWriteAck]
+
 // InjestServiceServer is the server API for InjestService service.
 // All implementations must embed UnimplementedInjestServiceServer
 // for forward compatibility.
 type InjestServiceServer interface {
 	Write(context.Context, *WriteRequest) (*WriteResponse, error)
 	BatchWrite(context.Context, *BatchWriteRequest) (*WriteResponse, error)
+	StreamWrite(grpc.BidiStreamingServer[StreamWriteRequest, StreamWriteAck]) error
 	mustEmbedUnimplementedInjestServiceServer()
 }
 
@@ -81,6 +97,9 @@ func (UnimplementedInjestServiceServer) Write(context.Context, *WriteRequest) (*
 func (UnimplementedInjestServiceServer) BatchWrite(context.Context, *BatchWriteRequest) (*WriteResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method BatchWrite not implemented")
 }
+func (UnimplementedInjestServiceServer) StreamWrite(grpc.BidiStreamingServer[StreamWriteRequest, StreamWriteAck]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamWrite not implemented")
+}
 func (UnimplementedInjestServiceServer) mustEmbedUnimplementedInjestServiceServer() {}
 func (UnimplementedInjestServiceServer) testEmbeddedByValue()                       {}
 
@@ -138,6 +157,13 @@ func _InjestService_BatchWrite_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _InjestService_StreamWrite_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(InjestServiceServer).StreamWrite(&grpc.GenericServerStream[StreamWriteRequest, StreamWriteAck]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type InjestService_StreamWriteServer = grpc.BidiStreamingServer[StreamWriteRequest, StreamWriteAck]
+
 // InjestService_ServiceDesc is the grpc.ServiceDesc for InjestService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -154,6 +180,13 @@ var InjestService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _InjestService_BatchWrite_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamWrite",
+			Handler:       _InjestService_StreamWrite_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
 	Metadata: "proto/ingest.proto",
 }
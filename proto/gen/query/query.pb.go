@@ -0,0 +1,917 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        v3.12.4
+// source: proto/query.proto
+
+package querypb
+
+import (
+	ingest "github.com/heyyakash/tickdb/proto/gen/ingest"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type AggregateFunction int32
+
+const (
+	AggregateFunction_AGGREGATE_FUNCTION_UNSPECIFIED AggregateFunction = 0
+	AggregateFunction_AGGREGATE_FUNCTION_COUNT       AggregateFunction = 1
+	AggregateFunction_AGGREGATE_FUNCTION_SUM         AggregateFunction = 2
+	AggregateFunction_AGGREGATE_FUNCTION_MEAN        AggregateFunction = 3
+	AggregateFunction_AGGREGATE_FUNCTION_MIN         AggregateFunction = 4
+	AggregateFunction_AGGREGATE_FUNCTION_MAX         AggregateFunction = 5
+	AggregateFunction_AGGREGATE_FUNCTION_FIRST       AggregateFunction = 6
+	AggregateFunction_AGGREGATE_FUNCTION_LAST        AggregateFunction = 7
+)
+
+// Enum value maps for AggregateFunction.
+var (
+	AggregateFunction_name = map[int32]string{
+		0: "AGGREGATE_FUNCTION_UNSPECIFIED",
+		1: "AGGREGATE_FUNCTION_COUNT",
+		2: "AGGREGATE_FUNCTION_SUM",
+		3: "AGGREGATE_FUNCTION_MEAN",
+		4: "AGGREGATE_FUNCTION_MIN",
+		5: "AGGREGATE_FUNCTION_MAX",
+		6: "AGGREGATE_FUNCTION_FIRST",
+		7: "AGGREGATE_FUNCTION_LAST",
+	}
+	AggregateFunction_value = map[string]int32{
+		"AGGREGATE_FUNCTION_UNSPECIFIED": 0,
+		"AGGREGATE_FUNCTION_COUNT":       1,
+		"AGGREGATE_FUNCTION_SUM":         2,
+		"AGGREGATE_FUNCTION_MEAN":        3,
+		"AGGREGATE_FUNCTION_MIN":         4,
+		"AGGREGATE_FUNCTION_MAX":         5,
+		"AGGREGATE_FUNCTION_FIRST":       6,
+		"AGGREGATE_FUNCTION_LAST":        7,
+	}
+)
+
+func (x AggregateFunction) Enum() *AggregateFunction {
+	p := new(AggregateFunction)
+	*p = x
+	return p
+}
+
+func (x AggregateFunction) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AggregateFunction) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_query_proto_enumTypes[0].Descriptor()
+}
+
+func (AggregateFunction) Type() protoreflect.EnumType {
+	return &file_proto_query_proto_enumTypes[0]
+}
+
+func (x AggregateFunction) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AggregateFunction.Descriptor instead.
+func (AggregateFunction) EnumDescriptor() ([]byte, []int) {
+	return file_proto_query_proto_rawDescGZIP(), []int{0}
+}
+
+type QueryRequest struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Key          string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	FromUnixNano int64                  `protobuf:"varint,2,opt,name=from_unix_nano,json=fromUnixNano,proto3" json:"from_unix_nano,omitempty"`
+	ToUnixNano   int64                  `protobuf:"varint,3,opt,name=to_unix_nano,json=toUnixNano,proto3" json:"to_unix_nano,omitempty"`
+	// database to read from, the default database when empty
+	Database string `protobuf:"bytes,4,opt,name=database,proto3" json:"database,omitempty"`
+	// most points to return, 0 returns them all
+	PageSize int32 `protobuf:"varint,5,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// next_cursor of the previous page
+	Cursor        string `protobuf:"bytes,6,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryRequest) Reset() {
+	*x = QueryRequest{}
+	mi := &file_proto_query_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryRequest) ProtoMessage() {}
+
+func (x *QueryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_query_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryRequest.ProtoReflect.Descriptor instead.
+func (*QueryRequest) Descriptor() ([]byte, []int) {
+	return file_proto_query_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *QueryRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetFromUnixNano() int64 {
+	if x != nil {
+		return x.FromUnixNano
+	}
+	return 0
+}
+
+func (x *QueryRequest) GetToUnixNano() int64 {
+	if x != nil {
+		return x.ToUnixNano
+	}
+	return 0
+}
+
+func (x *QueryRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *QueryRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+type QueryResponse struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Points []*ingest.Point        `protobuf:"bytes,1,rep,name=points,proto3" json:"points,omitempty"`
+	// set when there are more points, pass it as cursor to read them. Only the
+	// last message of QueryStream carries it.
+	NextCursor    string `protobuf:"bytes,2,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryResponse) Reset() {
+	*x = QueryResponse{}
+	mi := &file_proto_query_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryResponse) ProtoMessage() {}
+
+func (x *QueryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_query_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryResponse.ProtoReflect.Descriptor instead.
+func (*QueryResponse) Descriptor() ([]byte, []int) {
+	return file_proto_query_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *QueryResponse) GetPoints() []*ingest.Point {
+	if x != nil {
+		return x.Points
+	}
+	return nil
+}
+
+func (x *QueryResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+type AggregateRequest struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Key          string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	FromUnixNano int64                  `protobuf:"varint,2,opt,name=from_unix_nano,json=fromUnixNano,proto3" json:"from_unix_nano,omitempty"`
+	ToUnixNano   int64                  `protobuf:"varint,3,opt,name=to_unix_nano,json=toUnixNano,proto3" json:"to_unix_nano,omitempty"`
+	Field        string                 `protobuf:"bytes,4,opt,name=field,proto3" json:"field,omitempty"`
+	Function     AggregateFunction      `protobuf:"varint,5,opt,name=function,proto3,enum=tickdb.query.AggregateFunction" json:"function,omitempty"`
+	// width of each time bucket, 0 aggregates the whole range into one bucket
+	WindowNano int64 `protobuf:"varint,6,opt,name=window_nano,json=windowNano,proto3" json:"window_nano,omitempty"`
+	// database to read from, the default database when empty
+	Database      string `protobuf:"bytes,7,opt,name=database,proto3" json:"database,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AggregateRequest) Reset() {
+	*x = AggregateRequest{}
+	mi := &file_proto_query_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AggregateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AggregateRequest) ProtoMessage() {}
+
+func (x *AggregateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_query_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AggregateRequest.ProtoReflect.Descriptor instead.
+func (*AggregateRequest) Descriptor() ([]byte, []int) {
+	return file_proto_query_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *AggregateRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *AggregateRequest) GetFromUnixNano() int64 {
+	if x != nil {
+		return x.FromUnixNano
+	}
+	return 0
+}
+
+func (x *AggregateRequest) GetToUnixNano() int64 {
+	if x != nil {
+		return x.ToUnixNano
+	}
+	return 0
+}
+
+func (x *AggregateRequest) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *AggregateRequest) GetFunction() AggregateFunction {
+	if x != nil {
+		return x.Function
+	}
+	return AggregateFunction_AGGREGATE_FUNCTION_UNSPECIFIED
+}
+
+func (x *AggregateRequest) GetWindowNano() int64 {
+	if x != nil {
+		return x.WindowNano
+	}
+	return 0
+}
+
+func (x *AggregateRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+type AggregateBucket struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	StartUnixNano int64                  `protobuf:"varint,1,opt,name=start_unix_nano,json=startUnixNano,proto3" json:"start_unix_nano,omitempty"`
+	Value         float64                `protobuf:"fixed64,2,opt,name=value,proto3" json:"value,omitempty"`
+	Count         uint64                 `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AggregateBucket) Reset() {
+	*x = AggregateBucket{}
+	mi := &file_proto_query_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AggregateBucket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AggregateBucket) ProtoMessage() {}
+
+func (x *AggregateBucket) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_query_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AggregateBucket.ProtoReflect.Descriptor instead.
+func (*AggregateBucket) Descriptor() ([]byte, []int) {
+	return file_proto_query_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AggregateBucket) GetStartUnixNano() int64 {
+	if x != nil {
+		return x.StartUnixNano
+	}
+	return 0
+}
+
+func (x *AggregateBucket) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *AggregateBucket) GetCount() uint64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type AggregateResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Buckets       []*AggregateBucket     `protobuf:"bytes,1,rep,name=buckets,proto3" json:"buckets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AggregateResponse) Reset() {
+	*x = AggregateResponse{}
+	mi := &file_proto_query_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AggregateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AggregateResponse) ProtoMessage() {}
+
+func (x *AggregateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_query_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AggregateResponse.ProtoReflect.Descriptor instead.
+func (*AggregateResponse) Descriptor() ([]byte, []int) {
+	return file_proto_query_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *AggregateResponse) GetBuckets() []*AggregateBucket {
+	if x != nil {
+		return x.Buckets
+	}
+	return nil
+}
+
+type ExecuteRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// a tickql statement such as SELECT mean(usage) FROM cpu GROUP BY time(1m)
+	Query string `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	// database to read from, the default database when empty
+	Database string `protobuf:"bytes,2,opt,name=database,proto3" json:"database,omitempty"`
+	// most rows to return, 0 returns them all
+	PageSize int32 `protobuf:"varint,3,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// next_cursor of the previous page
+	Cursor        string `protobuf:"bytes,4,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecuteRequest) Reset() {
+	*x = ExecuteRequest{}
+	mi := &file_proto_query_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecuteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecuteRequest) ProtoMessage() {}
+
+func (x *ExecuteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_query_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecuteRequest.ProtoReflect.Descriptor instead.
+func (*ExecuteRequest) Descriptor() ([]byte, []int) {
+	return file_proto_query_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ExecuteRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *ExecuteRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *ExecuteRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ExecuteRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+// A cell of a result row, unset when the field is missing from the row.
+type Value struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Value:
+	//
+	//	*Value_Number
+	//	*Value_Text
+	//	*Value_TimeUnixNano
+	Value         isValue_Value `protobuf_oneof:"value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Value) Reset() {
+	*x = Value{}
+	mi := &file_proto_query_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Value) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Value) ProtoMessage() {}
+
+func (x *Value) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_query_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Value.ProtoReflect.Descriptor instead.
+func (*Value) Descriptor() ([]byte, []int) {
+	return file_proto_query_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Value) GetValue() isValue_Value {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Value) GetNumber() float64 {
+	if x != nil {
+		if x, ok := x.Value.(*Value_Number); ok {
+			return x.Number
+		}
+	}
+	return 0
+}
+
+func (x *Value) GetText() string {
+	if x != nil {
+		if x, ok := x.Value.(*Value_Text); ok {
+			return x.Text
+		}
+	}
+	return ""
+}
+
+func (x *Value) GetTimeUnixNano() int64 {
+	if x != nil {
+		if x, ok := x.Value.(*Value_TimeUnixNano); ok {
+			return x.TimeUnixNano
+		}
+	}
+	return 0
+}
+
+type isValue_Value interface {
+	isValue_Value()
+}
+
+type Value_Number struct {
+	Number float64 `protobuf:"fixed64,1,opt,name=number,proto3,oneof"`
+}
+
+type Value_Text struct {
+	Text string `protobuf:"bytes,2,opt,name=text,proto3,oneof"`
+}
+
+type Value_TimeUnixNano struct {
+	TimeUnixNano int64 `protobuf:"varint,3,opt,name=time_unix_nano,json=timeUnixNano,proto3,oneof"`
+}
+
+func (*Value_Number) isValue_Value() {}
+
+func (*Value_Text) isValue_Value() {}
+
+func (*Value_TimeUnixNano) isValue_Value() {}
+
+type Row struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []*Value               `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Row) Reset() {
+	*x = Row{}
+	mi := &file_proto_query_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Row) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Row) ProtoMessage() {}
+
+func (x *Row) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_query_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Row.ProtoReflect.Descriptor instead.
+func (*Row) Descriptor() ([]byte, []int) {
+	return file_proto_query_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Row) GetValues() []*Value {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+// The rows of one group, the first column is the time.
+type Result struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Tags          map[string]string      `protobuf:"bytes,2,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Columns       []string               `protobuf:"bytes,3,rep,name=columns,proto3" json:"columns,omitempty"`
+	Rows          []*Row                 `protobuf:"bytes,4,rep,name=rows,proto3" json:"rows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Result) Reset() {
+	*x = Result{}
+	mi := &file_proto_query_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Result) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Result) ProtoMessage() {}
+
+func (x *Result) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_query_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Result.ProtoReflect.Descriptor instead.
+func (*Result) Descriptor() ([]byte, []int) {
+	return file_proto_query_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Result) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Result) GetTags() map[string]string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Result) GetColumns() []string {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+func (x *Result) GetRows() []*Row {
+	if x != nil {
+		return x.Rows
+	}
+	return nil
+}
+
+type ExecuteResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Results []*Result              `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	// set when there are more rows, pass it as cursor to read them
+	NextCursor    string `protobuf:"bytes,2,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecuteResponse) Reset() {
+	*x = ExecuteResponse{}
+	mi := &file_proto_query_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecuteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecuteResponse) ProtoMessage() {}
+
+func (x *ExecuteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_query_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecuteResponse.ProtoReflect.Descriptor instead.
+func (*ExecuteResponse) Descriptor() ([]byte, []int) {
+	return file_proto_query_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ExecuteResponse) GetResults() []*Result {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+func (x *ExecuteResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+var File_proto_query_proto protoreflect.FileDescriptor
+
+const file_proto_query_proto_rawDesc = "" +
+	"\n" +
+	"\x11proto/query.proto\x12\ftickdb.query\x1a\x12proto/ingest.proto\"\xb9\x01\n" +
+	"\fQueryRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12$\n" +
+	"\x0efrom_unix_nano\x18\x02 \x01(\x03R\ffromUnixNano\x12 \n" +
+	"\fto_unix_nano\x18\x03 \x01(\x03R\n" +
+	"toUnixNano\x12\x1a\n" +
+	"\bdatabase\x18\x04 \x01(\tR\bdatabase\x12\x1b\n" +
+	"\tpage_size\x18\x05 \x01(\x05R\bpageSize\x12\x16\n" +
+	"\x06cursor\x18\x06 \x01(\tR\x06cursor\"^\n" +
+	"\rQueryResponse\x12,\n" +
+	"\x06points\x18\x01 \x03(\v2\x14.tickdb.ingest.PointR\x06points\x12\x1f\n" +
+	"\vnext_cursor\x18\x02 \x01(\tR\n" +
+	"nextCursor\"\xfc\x01\n" +
+	"\x10AggregateRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12$\n" +
+	"\x0efrom_unix_nano\x18\x02 \x01(\x03R\ffromUnixNano\x12 \n" +
+	"\fto_unix_nano\x18\x03 \x01(\x03R\n" +
+	"toUnixNano\x12\x14\n" +
+	"\x05field\x18\x04 \x01(\tR\x05field\x12;\n" +
+	"\bfunction\x18\x05 \x01(\x0e2\x1f.tickdb.query.AggregateFunctionR\bfunction\x12\x1f\n" +
+	"\vwindow_nano\x18\x06 \x01(\x03R\n" +
+	"windowNano\x12\x1a\n" +
+	"\bdatabase\x18\a \x01(\tR\bdatabase\"e\n" +
+	"\x0fAggregateBucket\x12&\n" +
+	"\x0fstart_unix_nano\x18\x01 \x01(\x03R\rstartUnixNano\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value\x12\x14\n" +
+	"\x05count\x18\x03 \x01(\x04R\x05count\"L\n" +
+	"\x11AggregateResponse\x127\n" +
+	"\abuckets\x18\x01 \x03(\v2\x1d.tickdb.query.AggregateBucketR\abuckets\"w\n" +
+	"\x0eExecuteRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x1a\n" +
+	"\bdatabase\x18\x02 \x01(\tR\bdatabase\x12\x1b\n" +
+	"\tpage_size\x18\x03 \x01(\x05R\bpageSize\x12\x16\n" +
+	"\x06cursor\x18\x04 \x01(\tR\x06cursor\"h\n" +
+	"\x05Value\x12\x18\n" +
+	"\x06number\x18\x01 \x01(\x01H\x00R\x06number\x12\x14\n" +
+	"\x04text\x18\x02 \x01(\tH\x00R\x04text\x12&\n" +
+	"\x0etime_unix_nano\x18\x03 \x01(\x03H\x00R\ftimeUnixNanoB\a\n" +
+	"\x05value\"2\n" +
+	"\x03Row\x12+\n" +
+	"\x06values\x18\x01 \x03(\v2\x13.tickdb.query.ValueR\x06values\"\xca\x01\n" +
+	"\x06Result\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x122\n" +
+	"\x04tags\x18\x02 \x03(\v2\x1e.tickdb.query.Result.TagsEntryR\x04tags\x12\x18\n" +
+	"\acolumns\x18\x03 \x03(\tR\acolumns\x12%\n" +
+	"\x04rows\x18\x04 \x03(\v2\x11.tickdb.query.RowR\x04rows\x1a7\n" +
+	"\tTagsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"b\n" +
+	"\x0fExecuteResponse\x12.\n" +
+	"\aresults\x18\x01 \x03(\v2\x14.tickdb.query.ResultR\aresults\x12\x1f\n" +
+	"\vnext_cursor\x18\x02 \x01(\tR\n" +
+	"nextCursor*\x81\x02\n" +
+	"\x11AggregateFunction\x12\"\n" +
+	"\x1eAGGREGATE_FUNCTION_UNSPECIFIED\x10\x00\x12\x1c\n" +
+	"\x18AGGREGATE_FUNCTION_COUNT\x10\x01\x12\x1a\n" +
+	"\x16AGGREGATE_FUNCTION_SUM\x10\x02\x12\x1b\n" +
+	"\x17AGGREGATE_FUNCTION_MEAN\x10\x03\x12\x1a\n" +
+	"\x16AGGREGATE_FUNCTION_MIN\x10\x04\x12\x1a\n" +
+	"\x16AGGREGATE_FUNCTION_MAX\x10\x05\x12\x1c\n" +
+	"\x18AGGREGATE_FUNCTION_FIRST\x10\x06\x12\x1b\n" +
+	"\x17AGGREGATE_FUNCTION_LAST\x10\a2\xb0\x02\n" +
+	"\fQueryService\x12@\n" +
+	"\x05Query\x12\x1a.tickdb.query.QueryRequest\x1a\x1b.tickdb.query.QueryResponse\x12L\n" +
+	"\tAggregate\x12\x1e.tickdb.query.AggregateRequest\x1a\x1f.tickdb.query.AggregateResponse\x12H\n" +
+	"\vQueryStream\x12\x1a.tickdb.query.QueryRequest\x1a\x1b.tickdb.query.QueryResponse0\x01\x12F\n" +
+	"\aExecute\x12\x1c.tickdb.query.ExecuteRequest\x1a\x1d.tickdb.query.ExecuteResponseB\x19Z\x17proto/gen/query;querypbb\x06proto3"
+
+var (
+	file_proto_query_proto_rawDescOnce sync.Once
+	file_proto_query_proto_rawDescData []byte
+)
+
+func file_proto_query_proto_rawDescGZIP() []byte {
+	file_proto_query_proto_rawDescOnce.Do(func() {
+		file_proto_query_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_query_proto_rawDesc), len(file_proto_query_proto_rawDesc)))
+	})
+	return file_proto_query_proto_rawDescData
+}
+
+var file_proto_query_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_proto_query_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_proto_query_proto_goTypes = []any{
+	(AggregateFunction)(0),    // 0: tickdb.query.AggregateFunction
+	(*QueryRequest)(nil),      // 1: tickdb.query.QueryRequest
+	(*QueryResponse)(nil),     // 2: tickdb.query.QueryResponse
+	(*AggregateRequest)(nil),  // 3: tickdb.query.AggregateRequest
+	(*AggregateBucket)(nil),   // 4: tickdb.query.AggregateBucket
+	(*AggregateResponse)(nil), // 5: tickdb.query.AggregateResponse
+	(*ExecuteRequest)(nil),    // 6: tickdb.query.ExecuteRequest
+	(*Value)(nil),             // 7: tickdb.query.Value
+	(*Row)(nil),               // 8: tickdb.query.Row
+	(*Result)(nil),            // 9: tickdb.query.Result
+	(*ExecuteResponse)(nil),   // 10: tickdb.query.ExecuteResponse
+	nil,                       // 11: tickdb.query.Result.TagsEntry
+	(*ingest.Point)(nil),      // 12: tickdb.ingest.Point
+}
+var file_proto_query_proto_depIdxs = []int32{
+	12, // 0: tickdb.query.QueryResponse.points:type_name -> tickdb.ingest.Point
+	0,  // 1: tickdb.query.AggregateRequest.function:type_name -> tickdb.query.AggregateFunction
+	4,  // 2: tickdb.query.AggregateResponse.buckets:type_name -> tickdb.query.AggregateBucket
+	7,  // 3: tickdb.query.Row.values:type_name -> tickdb.query.Value
+	11, // 4: tickdb.query.Result.tags:type_name -> tickdb.query.Result.TagsEntry
+	8,  // 5: tickdb.query.Result.rows:type_name -> tickdb.query.Row
+	9,  // 6: tickdb.query.ExecuteResponse.results:type_name -> tickdb.query.Result
+	1,  // 7: tickdb.query.QueryService.Query:input_type -> tickdb.query.QueryRequest
+	3,  // 8: tickdb.query.QueryService.Aggregate:input_type -> tickdb.query.AggregateRequest
+	1,  // 9: tickdb.query.QueryService.QueryStream:input_type -> tickdb.query.QueryRequest
+	6,  // 10: tickdb.query.QueryService.Execute:input_type -> tickdb.query.ExecuteRequest
+	2,  // 11: tickdb.query.QueryService.Query:output_type -> tickdb.query.QueryResponse
+	5,  // 12: tickdb.query.QueryService.Aggregate:output_type -> tickdb.query.AggregateResponse
+	2,  // 13: tickdb.query.QueryService.QueryStream:output_type -> tickdb.query.QueryResponse
+	10, // 14: tickdb.query.QueryService.Execute:output_type -> tickdb.query.ExecuteResponse
+	11, // [11:15] is the sub-list for method output_type
+	7,  // [7:11] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_proto_query_proto_init() }
+func file_proto_query_proto_init() {
+	if File_proto_query_proto != nil {
+		return
+	}
+	file_proto_query_proto_msgTypes[6].OneofWrappers = []any{
+		(*Value_Number)(nil),
+		(*Value_Text)(nil),
+		(*Value_TimeUnixNano)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_query_proto_rawDesc), len(file_proto_query_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_query_proto_goTypes,
+		DependencyIndexes: file_proto_query_proto_depIdxs,
+		EnumInfos:         file_proto_query_proto_enumTypes,
+		MessageInfos:      file_proto_query_proto_msgTypes,
+	}.Build()
+	File_proto_query_proto = out.File
+	file_proto_query_proto_goTypes = nil
+	file_proto_query_proto_depIdxs = nil
+}